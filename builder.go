@@ -2,24 +2,36 @@ package goloquent
 
 import (
 	"bytes"
+	"context"
 	"crypto/sha1"
 	"database/sql"
+	"database/sql/driver"
 	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"reflect"
 	"regexp"
+	"sort"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"cloud.google.com/go/datastore"
 )
 
 const (
-	variable      = "??"
-	jsonDelimeter = ":"
+	variable         = "??"
+	jsonDelimeter    = ":"
+	defaultBatchSize = 500
 )
 
+// retryBackoff is the wait time before retrying the transaction
+var retryBackoff = 50 * time.Millisecond
+
+// conflictAttempts is the number of attempts of `FirstOrCreate` and `UpdateOrCreate` when they're deadlocked
+const conflictAttempts = 3
+
 type index int
 
 const (
@@ -34,57 +46,231 @@ type builder struct {
 
 func newBuilder(query *Query) *builder {
 	clone := query.db.clone()
+	if query.timeout > 0 {
+		clone.client.timeout = query.timeout
+		readers := make([]Client, len(clone.readers))
+		for i, r := range clone.readers {
+			r.timeout = query.timeout
+			readers[i] = r
+		}
+		clone.readers = readers
+	}
 	return &builder{
 		db:    clone,
 		query: query.clone().scope,
 	}
 }
 
+// pk will return the primary key column of the query model, it's the key column of the connection
+// when the model is unknown
+func (b *builder) pk() string {
+	if b.query.pk != "" {
+		return b.query.pk
+	}
+	return b.db.client.keyColumn()
+}
+
+// column will return the column name in the table of the field, `__key__` and `$Key` are the primary key,
+// and `$Deleted` is the soft delete column of the connection
+func (b *builder) column(name string) string {
+	switch name {
+	case keyFieldName, pkColumn:
+		return b.pk()
+	case softDeleteColumn:
+		return b.db.client.deletedColumn()
+	}
+	return name
+}
+
+// newEntity will create the entity of the model using the primary key and soft delete column of the connection
+func (b *builder) newEntity(model interface{}) (*entity, error) {
+	return newEntityWith(model, b.db.client.keyColumn(), b.db.client.deletedColumn())
+}
+
+// readClient will return the next replica client if there is any, unless the query is locked or `UsePrimary`
+func (b *builder) readClient() *Client {
+	if len(b.db.readers) == 0 || b.query.usePrimary || b.query.lockMode > 0 {
+		return &b.db.client
+	}
+	i := atomic.AddUint32(b.db.next, 1)
+	return &b.db.readers[int(i%uint32(len(b.db.readers)))]
+}
+
 func (b *builder) addIndex(fields []string, idx index) error {
+	ss := b.indexStmt(fields, idx)
+	if ss == "" {
+		return nil
+	}
+	return b.db.client.execStmt(&stmt{
+		statement: bytes.NewBufferString(ss),
+	})
+}
+
+// indexStmt will return the statement to create the index, it's empty when the index is exists
+func (b *builder) indexStmt(fields []string, idx index) string {
 	table := b.query.table
 	buf := new(bytes.Buffer)
 	buf.WriteString("CREATE")
-	idxName := fmt.Sprintf("%s_%s_idx", table, strings.Join(fields, "_"))
-	switch idx {
-	case uniqueIdx:
-		idxName = fmt.Sprintf("%s_%s_unique", table, strings.Join(fields, "_"))
+	idxName := indexName(table, fields, idx)
+	if idx == uniqueIdx {
 		buf.WriteString(" UNIQUE")
-	default:
 	}
 	if b.db.dialect.HasIndex(table, idxName) {
-		return nil
+		return ""
+	}
+	cols := make([]string, len(fields))
+	for i, f := range fields {
+		cols[i] = b.db.dialect.Quote(f)
 	}
 	buf.WriteString(fmt.Sprintf(" INDEX %s ON %s (%s);",
 		b.db.dialect.Quote(idxName),
 		b.db.dialect.GetTable(table),
-		b.db.dialect.Quote(strings.Join(fields, ","))))
+		strings.Join(cols, ",")))
+	return buf.String()
+}
+
+// indexName will return the name of the index across the columns, such as `User_Email_unique`
+func indexName(table string, cols []string, idx index) string {
+	if idx == uniqueIdx {
+		return fmt.Sprintf("%s_%s_unique", table, strings.Join(cols, "_"))
+	}
+	return fmt.Sprintf("%s_%s_idx", table, strings.Join(cols, "_"))
+}
+
+func (b *builder) dropTableIfExists(table string) error {
+	defer b.db.columns.reset(table)
+	buf := new(bytes.Buffer)
+	buf.WriteString(fmt.Sprintf("DROP TABLE IF EXISTS %s;", b.db.dialect.GetTable(table)))
 	return b.db.client.execStmt(&stmt{
 		statement: buf,
 	})
 }
 
-func (b *builder) dropTableIfExists(table string) error {
+// isReserved will check whether the column is the primary key or soft delete column
+func (b *builder) isReserved(column string) bool {
+	switch column {
+	case pkColumn, softDeleteColumn, b.db.client.keyColumn(), b.db.client.deletedColumn():
+		return true
+	}
+	return false
+}
+
+func (b *builder) hasColumn(table, column string) (bool, error) {
+	return b.db.dialect.HasColumn(table, column)
+}
+
+// dropColumn will do nothing when the column is not exists, the primary key and soft delete column cannot be dropped
+func (b *builder) dropColumn(table, column string) error {
+	if b.isReserved(column) {
+		return fmt.Errorf("goloquent: column %q is reserved and cannot be dropped", column)
+	}
+	if isExist, err := b.hasColumn(table, column); err != nil || !isExist {
+		return err
+	}
+	defer b.db.columns.reset(table)
 	buf := new(bytes.Buffer)
-	buf.WriteString(fmt.Sprintf("DROP TABLE IF EXISTS %s;", b.db.dialect.GetTable(table)))
+	buf.WriteString(fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s;",
+		b.db.dialect.GetTable(table),
+		b.db.dialect.Quote(column)))
 	return b.db.client.execStmt(&stmt{
 		statement: buf,
 	})
 }
 
+// renameColumn will do nothing when the column is renamed already, the statement is emitted by the dialect
+func (b *builder) renameColumn(table, from, to string) error {
+	switch {
+	case b.isReserved(from):
+		return fmt.Errorf("goloquent: column %q is reserved and cannot be renamed", from)
+	case b.isReserved(to):
+		return fmt.Errorf("goloquent: column %q is reserved", to)
+	case strings.TrimSpace(to) == "":
+		return fmt.Errorf("goloquent: new column name cannot be empty")
+	}
+	hasFrom, err := b.hasColumn(table, from)
+	if err != nil {
+		return err
+	}
+	hasTo, err := b.hasColumn(table, to)
+	if err != nil {
+		return err
+	}
+	if !hasFrom {
+		if hasTo {
+			return nil
+		}
+		return fmt.Errorf("goloquent: table %q has no column %q", table, from)
+	}
+	if hasTo {
+		return fmt.Errorf("goloquent: table %q has column %q already", table, to)
+	}
+	ss, err := b.db.dialect.RenameColumn(table, from, to)
+	if err != nil {
+		return err
+	}
+	defer b.db.columns.reset(table)
+	return b.db.client.execStmt(&stmt{
+		statement: bytes.NewBufferString(ss),
+	})
+}
+
 func (b *builder) quoteIfNecessary(v string) string {
 	if regexp.MustCompile("^[a-zA-Z\\d]+(\\.[a-zA-Z\\d]+)*$").MatchString(v) {
-		return b.db.dialect.Quote(v)
+		return b.quoteColumn(v)
 	}
 	return v
 }
 
+// quoteColumn will qualify the column with table name when the query has join,
+// so `User.Name` become `User`.`Name` if `User` is one of the joined tables
+func (b *builder) quoteColumn(name string) string {
+	query := b.query
+	if len(query.joins) <= 0 {
+		return b.db.dialect.Quote(name)
+	}
+	switch name {
+	case b.pk(), b.db.client.deletedColumn():
+		return b.db.dialect.Quote(query.table) + "." + b.db.dialect.Quote(name)
+	}
+	if paths := strings.SplitN(name, ".", 2); len(paths) > 1 && query.hasTable(paths[0]) {
+		return b.db.dialect.Quote(paths[0]) + "." + b.db.dialect.Quote(paths[1])
+	}
+	return b.db.dialect.Quote(name)
+}
+
+func (b *builder) buildJoin(query scope) *stmt {
+	buf := new(bytes.Buffer)
+	qualify := func(table, col string) string {
+		if paths := strings.SplitN(col, ".", 2); len(paths) > 1 && query.hasTable(paths[0]) {
+			return b.db.dialect.Quote(paths[0]) + "." + b.db.dialect.Quote(paths[1])
+		}
+		return b.db.dialect.Quote(table) + "." + b.db.dialect.Quote(col)
+	}
+	for _, j := range query.joins {
+		buf.WriteString(fmt.Sprintf(" %s %s ON %s = %s",
+			j.kind, b.db.dialect.GetTable(j.table),
+			qualify(query.table, j.localCol),
+			qualify(j.table, j.foreignCol)))
+	}
+	return &stmt{
+		statement: buf,
+	}
+}
+
 func (b *builder) buildSelect(query scope) *stmt {
-	scope := "*"
-	if len(query.projection) > 0 {
-		projection := make([]string, len(query.projection), len(query.projection))
-		copy(projection, query.projection)
-		for i := 0; i < len(query.projection); i++ {
-			projection[i] = b.quoteIfNecessary(projection[i])
+	scope, args := "*", make([]interface{}, 0)
+	if len(query.projection) > 0 || len(query.rawSelects) > 0 {
+		projection := make([]string, 0, len(query.projection)+len(query.rawSelects))
+		j := 0
+		for i := 0; i <= len(query.projection); i++ {
+			// the raw expressions are placed by the order of `Select` and `SelectRaw`
+			for ; j < len(query.rawSelects) && query.rawSelects[j].index == i; j++ {
+				projection = append(projection, query.rawSelects[j].expr)
+				args = append(args, query.rawSelects[j].args...)
+			}
+			if i < len(query.projection) {
+				projection = append(projection, b.quoteIfNecessary(query.projection[i]))
+			}
 		}
 		scope = strings.Join(projection, ",")
 	}
@@ -94,35 +280,81 @@ func (b *builder) buildSelect(query scope) *stmt {
 		for i := 0; i < len(query.distinctOn); i++ {
 			distinctOn[i] = b.quoteIfNecessary(distinctOn[i])
 		}
-		scope = "DISTINCT " + strings.Join(distinctOn, ",")
+		scope, args = "DISTINCT "+strings.Join(distinctOn, ","), nil
 	}
 	buf := new(bytes.Buffer)
 	buf.WriteString("SELECT ")
 	buf.WriteString(scope)
 	return &stmt{
 		statement: buf,
+		arguments: args,
 	}
 }
 
-func (b *builder) buildWhere(query scope) (*stmt, error) {
-	buf := new(bytes.Buffer)
+// buildFilters will translate the filters into sql conditions, the field name is quoted using `quote`
+func (b *builder) buildFilters(filters []Filter, quote func(string) string) ([]string, []interface{}, error) {
 	wheres := make([]string, 0)
 	args := make([]interface{}, 0)
+	ors := make([]bool, 0)
+
+	for _, f := range filters {
+		ors = append(ors, f.or)
+		if f.group != nil {
+			conds, vv, err := b.buildFilters(f.group, quote)
+			if err != nil {
+				return nil, nil, err
+			}
+			if len(conds) <= 0 {
+				ors = ors[:len(ors)-1]
+				continue
+			}
+			cond := strings.Join(conds, " AND ")
+			if len(conds) > 1 {
+				cond = "(" + cond + ")"
+			}
+			wheres = append(wheres, cond)
+			args = append(args, vv...)
+			continue
+		}
+
+		if f.operator == Match {
+			names := make([]string, len(f.fields))
+			for i, field := range f.fields {
+				names[i] = quote(field)
+			}
+			str, err := b.db.dialect.FilterMatch(names)
+			if err != nil {
+				return nil, nil, err
+			}
+			wheres = append(wheres, str)
+			args = append(args, f.value)
+			continue
+		}
+
+		if f.column != "" {
+			names := make([]string, 0, 2)
+			for _, field := range []string{f.field, f.column} {
+				names = append(names, quote(b.column(field)))
+			}
+			wheres = append(wheres, fmt.Sprintf("%s %s %s", names[0], columnOperators[f.operator], names[1]))
+			continue
+		}
 
-	for _, f := range query.filters {
-		name := b.db.dialect.Quote(f.Field())
+		name := quote(b.column(f.Field()))
 
 		var v interface{}
 		switch vi := f.value.(type) {
 		case *Query:
 			var subQuery strings.Builder
 			subQuery.WriteString("(")
-			subQuery.WriteString(b.buildSelect(vi.scope).string())
+			ss := b.buildSelect(vi.scope)
+			subQuery.WriteString(ss.string())
+			args = append(args, ss.arguments...)
 			subQuery.WriteString(" FROM ")
 			subQuery.WriteString(b.db.dialect.GetTable(vi.scope.table))
 			stmt, err := b.buildStmt(vi.scope)
 			if err != nil {
-				return nil, fmt.Errorf("goloquent: %v", err)
+				return nil, nil, fmt.Errorf("goloquent: %w", err)
 			}
 			subQuery.WriteString(stmt.string())
 			subQuery.WriteString(")")
@@ -132,13 +364,13 @@ func (b *builder) buildWhere(query scope) (*stmt, error) {
 		default:
 			vi, err := f.Interface()
 			if err != nil {
-				return nil, err
+				return nil, nil, err
 			}
 
 			if f.IsJSON() {
 				str, vv, err := b.db.dialect.FilterJSON(f)
 				if err != nil {
-					return nil, fmt.Errorf("goloquent: %v", err)
+					return nil, nil, fmt.Errorf("goloquent: %w", err)
 				}
 				wheres = append(wheres, str)
 				args = append(args, vv...)
@@ -147,10 +379,9 @@ func (b *builder) buildWhere(query scope) (*stmt, error) {
 
 			switch f.Field() {
 			case keyFieldName, pkColumn:
-				name = b.db.dialect.Quote(pkColumn)
 				vi, err = interfaceToKeyString(f.value)
 				if err != nil {
-					return nil, err
+					return nil, nil, err
 				}
 			}
 			v = vi
@@ -185,12 +416,23 @@ func (b *builder) buildWhere(query scope) (*stmt, error) {
 				x = append(x, v)
 			}
 			if len(x) <= 0 {
-				return nil, fmt.Errorf(`goloquent: value for "AnyLike" operator cannot be empty`)
+				return nil, nil, fmt.Errorf(`goloquent: value for "AnyLike" operator cannot be empty`)
+			}
+			if f.escape != "" {
+				patterns := make([]interface{}, len(x))
+				for j := range x {
+					str, isOk := x[j].(string)
+					if !isOk {
+						return nil, nil, fmt.Errorf(`goloquent: value for "AnyLike" operator must be string`)
+					}
+					patterns[j] = fmt.Sprintf(f.escape, b.db.dialect.EscapeLike(str))
+				}
+				x, vv = patterns, fmt.Sprintf("%s ESCAPE %s", variable, b.db.dialect.Value(likeEscape))
 			}
 			buf := new(bytes.Buffer)
 			buf.WriteString("(")
 			for j := 0; j < len(x); j++ {
-				buf.WriteString(fmt.Sprintf("%s LIKE %s OR ", name, variable))
+				buf.WriteString(fmt.Sprintf("%s LIKE %s OR ", name, vv))
 			}
 			buf.Truncate(buf.Len() - 4)
 			buf.WriteString(")")
@@ -198,10 +440,31 @@ func (b *builder) buildWhere(query scope) (*stmt, error) {
 			wheres = append(wheres, buf.String())
 			args = append(args, x...)
 			continue
-		case Like:
+		case Like, NotLike:
 			op = "LIKE"
-		case NotLike:
-			op = "NOT LIKE"
+			if f.operator == NotLike {
+				op = "NOT LIKE"
+			}
+			if f.escape != "" {
+				str, isOk := v.(string)
+				if !isOk {
+					return nil, nil, fmt.Errorf("goloquent: value for %q operator must be string", op)
+				}
+				v = fmt.Sprintf(f.escape, b.db.dialect.EscapeLike(str))
+				vv = fmt.Sprintf("%s ESCAPE %s", variable, b.db.dialect.Value(likeEscape))
+			}
+		case Between, NotBetween:
+			op = "BETWEEN"
+			if f.operator == NotBetween {
+				op = "NOT BETWEEN"
+			}
+			x, isOk := v.([]interface{})
+			if !isOk || len(x) != 2 {
+				return nil, nil, fmt.Errorf("goloquent: value for %q operator must be a slice with exactly two elements", op)
+			}
+			wheres = append(wheres, fmt.Sprintf("%s %s %s AND %s", name, op, variable, variable))
+			args = append(args, x...)
+			continue
 		case In:
 			op = "IN"
 			switch vi := v.(type) {
@@ -211,7 +474,7 @@ func (b *builder) buildWhere(query scope) (*stmt, error) {
 					x = append(x, v)
 				}
 				if len(x) <= 0 {
-					return nil, fmt.Errorf(`goloquent: value for "In" operator cannot be empty`)
+					return nil, nil, fmt.Errorf(`goloquent: value for "In" operator cannot be empty`)
 				}
 				vv = fmt.Sprintf("(%s)", strings.TrimRight(
 					strings.Repeat(variable+",", len(x)), ","))
@@ -232,7 +495,7 @@ func (b *builder) buildWhere(query scope) (*stmt, error) {
 					x = append(x, v)
 				}
 				if len(x) <= 0 {
-					return nil, fmt.Errorf(`goloquent: value for "NotIn" operator cannot be empty`)
+					return nil, nil, fmt.Errorf(`goloquent: value for "NotIn" operator cannot be empty`)
 				}
 				vv = fmt.Sprintf("(%s)", strings.TrimRight(
 					strings.Repeat(variable+",", len(x)), ","))
@@ -248,22 +511,64 @@ func (b *builder) buildWhere(query scope) (*stmt, error) {
 		args = append(args, v)
 	}
 
+	return combineFilters(wheres, ors), args, nil
+}
+
+// combineFilters will merge the conditions joined by `OR` into a single condition, so the result
+// can always be joined by `AND`. `AND` binds tighter than `OR` as in sql,
+// e.g. `a AND b OR c AND d` will become `((a AND b) OR (c AND d))`
+func combineFilters(conds []string, ors []bool) []string {
+	runs := make([][]string, 0)
+	for i, c := range conds {
+		if i == 0 || ors[i] {
+			runs = append(runs, []string{})
+		}
+		runs[len(runs)-1] = append(runs[len(runs)-1], c)
+	}
+	if len(runs) <= 1 {
+		return conds
+	}
+	terms := make([]string, len(runs))
+	for i, run := range runs {
+		terms[i] = strings.Join(run, " AND ")
+		if len(run) > 1 {
+			terms[i] = "(" + terms[i] + ")"
+		}
+	}
+	return []string{"(" + strings.Join(terms, " OR ") + ")"}
+}
+
+func (b *builder) buildWhere(query scope) (*stmt, error) {
+	buf := new(bytes.Buffer)
+	wheres, args, err := b.buildFilters(query.filters, b.quoteColumn)
+	if err != nil {
+		return nil, err
+	}
+
+	// the key of the descendant is prefixed by the path of the ancestor, the wildcards of the path are
+	// escaped, and the descendants deeper than `AncestorDepth` are excluded by counting the delimiters
+	pk, escape := b.quoteColumn(b.pk()), b.db.dialect.Value(likeEscape)
+	ancestor := func(k *datastore.Key) string {
+		path := b.db.dialect.EscapeLike(stringifyKey(k)) + keyDelimeter
+		args = append(args, path+"%")
+		cond := fmt.Sprintf("%s LIKE %s ESCAPE %s", pk, variable, escape)
+		if query.ancestorDepth > 0 {
+			args = append(args, path+strings.Repeat("%"+keyDelimeter, query.ancestorDepth)+"%")
+			cond = fmt.Sprintf("(%s AND %s NOT LIKE %s ESCAPE %s)", cond, pk, variable, escape)
+		}
+		return cond
+	}
 	for _, aa := range query.ancestors {
 		if aa.isGroup {
-			buf := new(bytes.Buffer)
-			buf.WriteString("(")
+			conds := make([]string, 0, len(aa.data))
 			for _, x := range aa.data {
-				buf.WriteString(fmt.Sprintf("%s LIKE %s OR ", b.db.dialect.Quote(pkColumn), variable))
-				args = append(args, fmt.Sprintf("%%%s/%%", stringifyKey(x.(*datastore.Key))))
+				conds = append(conds, ancestor(x.(*datastore.Key)))
 			}
-			buf.Truncate(buf.Len() - 4)
-			buf.WriteString(")")
-			wheres = append(wheres, buf.String())
+			wheres = append(wheres, "("+strings.Join(conds, " OR ")+")")
 			continue
 		}
 
-		wheres = append(wheres, fmt.Sprintf("%s LIKE %s", b.db.dialect.Quote(pkColumn), variable))
-		args = append(args, fmt.Sprintf("%%%s/%%", stringifyKey(aa.data[0].(*datastore.Key))))
+		wheres = append(wheres, ancestor(aa.data[0].(*datastore.Key)))
 	}
 
 	if len(wheres) > 0 {
@@ -279,6 +584,36 @@ func (b *builder) buildWhere(query scope) (*stmt, error) {
 	}, nil
 }
 
+func (b *builder) buildGroupBy(query scope) *stmt {
+	buf := new(bytes.Buffer)
+	if len(query.groupBy) > 0 {
+		arr := make([]string, 0, len(query.groupBy))
+		for _, g := range query.groupBy {
+			arr = append(arr, b.aggregateColumn(g))
+		}
+		buf.WriteString(" GROUP BY " + strings.Join(arr, ","))
+	}
+	return &stmt{
+		statement: buf,
+	}
+}
+
+func (b *builder) buildHaving(query scope) (*stmt, error) {
+	buf := new(bytes.Buffer)
+	havings, args, err := b.buildFilters(query.havings, b.quoteIfNecessary)
+	if err != nil {
+		return nil, err
+	}
+	if len(havings) > 0 {
+		buf.WriteString(" HAVING ")
+		buf.WriteString(strings.Join(havings, " AND "))
+	}
+	return &stmt{
+		statement: buf,
+		arguments: args,
+	}, nil
+}
+
 func (b *builder) buildOrder(query scope) *stmt {
 	buf := new(bytes.Buffer)
 
@@ -286,10 +621,7 @@ func (b *builder) buildOrder(query scope) *stmt {
 	if len(query.orders) > 0 {
 		arr := make([]string, 0, len(query.orders))
 		for _, o := range query.orders {
-			name := b.db.dialect.Quote(o.field)
-			if o.field == keyFieldName {
-				name = b.db.dialect.Quote(pkColumn)
-			}
+			name := b.quoteColumn(b.column(o.field))
 			suffix := " ASC"
 			if o.direction != ascending {
 				suffix = " DESC"
@@ -327,6 +659,15 @@ func (b *builder) buildStmt(query scope, args ...interface{}) (*stmt, error) {
 		args = append(args, cmd.arguments...)
 		buf.WriteString(cmd.string())
 	}
+	buf.WriteString(b.buildGroupBy(query).string())
+	cmd, err = b.buildHaving(query)
+	if err != nil {
+		return nil, err
+	}
+	if !cmd.isZero() {
+		args = append(args, cmd.arguments...)
+		buf.WriteString(cmd.string())
+	}
 	buf.WriteString(b.buildOrder(query).string())
 	buf.WriteString(b.buildLimitOffset(query).string())
 	return &stmt{
@@ -335,24 +676,127 @@ func (b *builder) buildStmt(query scope, args ...interface{}) (*stmt, error) {
 	}, nil
 }
 
-func (b *builder) createTable(e *entity) error {
+func (b *builder) createTable(e *entity) ([]string, error) {
 	return b.db.dialect.CreateTable(e.Name(), e.columns)
 }
 
-func (b *builder) alterTable(e *entity) error {
-	return b.db.dialect.AlterTable(e.Name(), e.columns)
+func (b *builder) alterTable(e *entity) ([]string, error) {
+	return b.db.dialect.AlterTable(e.Name(), e.columns, !b.db.noDrop)
 }
 
-func (b *builder) migrate(model interface{}) error {
-	e, err := newEntity(model)
+// migrateStmts will return the statements to migrate the model without executing them,
+// the table is created when it's not exists, otherwise it's altered to match the model
+func (b *builder) migrateStmts(model interface{}) ([]string, error) {
+	e, err := b.newEntity(model)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	e.setName(b.query.table)
+	var stmts []string
 	if b.db.dialect.HasTable(e.Name()) {
-		return b.alterTable(e)
+		stmts, err = b.alterTable(e)
+		if err == nil && !b.db.noDrop {
+			for _, idx := range b.staleUniqueIndexes(e) {
+				stmts = append(stmts, b.db.dialect.DropIndex(e.Name(), idx))
+			}
+		}
+	} else {
+		stmts, err = b.createTable(e)
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, fields := range e.uniques {
+		ss, err := b.uniqueIndexStmt(e, fields)
+		if err != nil {
+			return nil, err
+		}
+		if ss != "" {
+			stmts = append(stmts, ss)
+		}
+	}
+	return stmts, nil
+}
+
+func (b *builder) migrate(model interface{}) error {
+	stmts, err := b.migrateStmts(model)
+	if err != nil {
+		return err
+	}
+	// the columns may be added or dropped by the migration
+	defer b.db.columns.reset()
+	exec := func(db *DB) error {
+		for _, ss := range stmts {
+			if err := db.client.execStmt(&stmt{
+				statement: bytes.NewBufferString(ss),
+			}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	// the statements of the model are executed within a transaction, so it can be rolled back
+	// on the database which supports transactional DDL, such as postgres
+	if _, isOk := b.db.client.sqlCommon.(*sql.DB); !isOk {
+		return exec(b.db)
+	}
+	return b.runInTransaction(exec)
+}
+
+// staleUniqueIndexes will return the unique indexes of the table which are no longer declared by the model,
+// such as the unique option is removed or the unique group is renamed
+func (b *builder) staleUniqueIndexes(e *entity) []string {
+	declared := newDictionary(nil)
+	for _, c := range e.columns {
+		for _, ss := range b.db.dialect.GetSchema(c) {
+			if ss.IsUnique {
+				declared.add(indexName(e.Name(), []string{ss.Name}, uniqueIdx))
+			}
+		}
+	}
+	for _, fields := range e.uniques {
+		cols := make([]string, len(fields))
+		for i, f := range fields {
+			cols[i] = e.column(f)
+		}
+		declared.add(indexName(e.Name(), cols, uniqueIdx))
 	}
-	return b.createTable(e)
+	idxs := make([]string, 0)
+	for _, idx := range b.db.dialect.GetIndexes(e.Name()) {
+		if strings.HasSuffix(idx, "_unique") && !declared.has(idx) {
+			idxs = append(idxs, idx)
+		}
+	}
+	sort.Strings(idxs)
+	return idxs
+}
+
+// addUniqueIndex will create the unique index across the fields of the entity if it's not exists
+func (b *builder) addUniqueIndex(e *entity, fields []string) error {
+	ss, err := b.uniqueIndexStmt(e, fields)
+	if err != nil || ss == "" {
+		return err
+	}
+	return b.db.client.execStmt(&stmt{
+		statement: bytes.NewBufferString(ss),
+	})
+}
+
+// uniqueIndexStmt will return the statement to create the unique index across the fields of the entity,
+// it's empty when the unique index is exists
+func (b *builder) uniqueIndexStmt(e *entity, fields []string) (string, error) {
+	if len(fields) <= 0 {
+		return "", fmt.Errorf("goloquent: unique index of entity %q required at least one field", e.Name())
+	}
+	cols := make([]string, len(fields))
+	for i, f := range fields {
+		if _, isOk := e.fields[f]; !isOk {
+			return "", fmt.Errorf("goloquent: entity %q doesn't has field %q", e.Name(), f)
+		}
+		cols[i] = e.column(f)
+	}
+	b.query.table = e.Name()
+	return b.indexStmt(cols, uniqueIdx), nil
 }
 
 func (b *builder) migrateMultiple(models []interface{}) error {
@@ -364,78 +808,259 @@ func (b *builder) migrateMultiple(models []interface{}) error {
 	return nil
 }
 
-func (b *builder) getCommand(e *entity) (*stmt, error) {
-	query := b.query
-	buf := new(bytes.Buffer)
-	buf.WriteString(b.buildSelect(query).string())
-	buf.WriteString(fmt.Sprintf(" FROM %s", b.db.dialect.GetTable(e.Name())))
-	if !query.noScope && e.hasSoftDelete() {
-		query.filters = append(query.filters, Filter{
+// migrateDryRun will return the statements to migrate the models in order, nothing is executed
+func (b *builder) migrateDryRun(models []interface{}) ([]string, error) {
+	stmts := make([]string, 0)
+	for _, mm := range models {
+		ss, err := b.migrateStmts(mm)
+		if err != nil {
+			return nil, err
+		}
+		stmts = append(stmts, ss...)
+	}
+	return stmts, nil
+}
+
+// softDeleteScope will append the soft delete filter, it excludes the soft deleted records unless
+// the query is `Unscoped`, and `OnlyTrashed` will only include the soft deleted records
+func softDeleteScope(query scope, hasSoftDelete bool) scope {
+	if !hasSoftDelete {
+		return query
+	}
+	// the soft delete filter applies to all the filters, even when they are joined by `OR`
+	filters := encloseFilters(query.filters)
+	filters = filters[:len(filters):len(filters)]
+	switch {
+	case query.onlyTrashed:
+		filters = append(filters, Filter{
+			field:    softDeleteColumn,
+			operator: NotEqual,
+			value:    nil,
+		})
+	case !query.noScope:
+		filters = append(filters, Filter{
 			field:    softDeleteColumn,
 			operator: Equal,
 			value:    nil,
 		})
 	}
-	cmd, err := b.buildStmt(query)
+	query.filters = filters
+	return query
+}
+
+func (b *builder) getCommand(e *entity) (*stmt, error) {
+	query := b.query
+	query.table = e.Name()
+	query.pk = e.pk
+	// the columns are qualified using the table of the builder query, so the shared query is never mutated
+	return (&builder{db: b.db, query: query}).buildGet(e.hasSoftDelete())
+}
+
+// buildSelectFrom will build the select statement of the query table without the lock and `;`
+func (b *builder) buildSelectFrom(hasSoftDelete bool) (*stmt, error) {
+	query := b.query
+	buf := new(bytes.Buffer)
+	ss := b.buildSelect(query)
+	buf.WriteString(ss.string())
+	buf.WriteString(fmt.Sprintf(" FROM %s", b.db.dialect.GetTable(query.table)))
+	buf.WriteString(b.buildJoin(query).string())
+	query = softDeleteScope(query, hasSoftDelete)
+	cmd, err := b.buildStmt(query, ss.arguments...)
 	if err != nil {
 		return nil, err
 	}
 	buf.WriteString(cmd.string())
-	switch query.lockMode {
-	case ReadLock:
-		buf.WriteString(" LOCK IN SHARE MODE")
-	case WriteLock:
-		buf.WriteString(" FOR UPDATE")
-	}
-	buf.WriteString(";")
-
 	return &stmt{
 		statement: buf,
 		arguments: cmd.arguments,
 	}, nil
 }
 
-func (b *builder) run(table string, cmd *stmt) (*Iterator, error) {
-	var rows, err = b.db.client.execQuery(cmd)
-	if err != nil {
-		return nil, fmt.Errorf("goloquent: %v", err)
+// buildUnion will build the select statements of the query and its unions, the `ORDER BY`,
+// `LIMIT` and `OFFSET` of the query are applied to the result of the union. The soft delete
+// scope of the model applies to all the unions, as they are expected to select the same table
+func (b *builder) buildUnion(hasSoftDelete bool) (*stmt, error) {
+	query := b.query
+	if query.lockMode > 0 {
+		return nil, fmt.Errorf("goloquent: lock is not supported by union")
 	}
-	defer rows.Close()
-	cols, err := rows.Columns()
+	first := query
+	first.unions, first.orders = nil, nil
+	first.limit, first.offset = -1, -1
+	cmd, err := (&builder{db: b.db, query: first}).buildSelectFrom(hasSoftDelete)
 	if err != nil {
-		return nil, fmt.Errorf("goloquent: %v", err)
-	}
-
-	it := Iterator{
-		table:    table,
-		stmt:     &Stmt{stmt: *cmd, replacer: b.db.dialect},
-		position: -1,
-		columns:  cols,
+		return nil, err
 	}
-
-	i := 0
-	for rows.Next() {
-		m := make([]interface{}, len(cols))
-		for j := range cols {
-			m[j] = &m[j]
+	buf, args := new(bytes.Buffer), cmd.arguments
+	buf.WriteString(b.db.dialect.UnionSelect(cmd.string()))
+	for _, u := range query.unions {
+		if err := matchProjection(query, u.query); err != nil {
+			return nil, err
 		}
-
-		if err := rows.Scan(m...); err != nil {
+		ss := u.query
+		if ss.table == "" {
+			ss.table = query.table
+		}
+		cmd, err := (&builder{db: b.db, query: ss}).buildSelectFrom(hasSoftDelete)
+		if err != nil {
 			return nil, err
 		}
-
-		for j, name := range cols {
-			it.put(i, name, m[j])
+		buf.WriteString(" UNION ")
+		if u.all {
+			buf.WriteString("ALL ")
 		}
-		it.patchKey()
-		i++
+		buf.WriteString(b.db.dialect.UnionSelect(cmd.string()))
+		args = append(args, cmd.arguments...)
+	}
+	buf.WriteString(b.buildOrder(query).string())
+	buf.WriteString(b.buildLimitOffset(query).string())
+	buf.WriteString(";")
+	return &stmt{
+		statement: buf,
+		arguments: args,
+	}, nil
+}
+
+// buildGet will build the select statement of the query table
+func (b *builder) buildGet(hasSoftDelete bool) (*stmt, error) {
+	query := b.query
+	if len(query.unions) > 0 {
+		return b.buildUnion(hasSoftDelete)
+	}
+	cmd, err := b.buildSelectFrom(hasSoftDelete)
+	if err != nil {
+		return nil, err
+	}
+	buf := cmd.statement
+	if query.lockOption > 0 && query.lockMode <= 0 {
+		return nil, fmt.Errorf("goloquent: lock option requires a lock mode")
+	}
+	if query.lockMode > 0 {
+		clause, err := b.db.dialect.LockClause(query.lockMode, query.lockOption)
+		if err != nil {
+			return nil, err
+		}
+		if clause != "" {
+			buf.WriteString(" " + clause)
+		}
+	}
+	buf.WriteString(";")
+
+	return &stmt{
+		statement: buf,
+		arguments: cmd.arguments,
+	}, nil
+}
+
+// getStmt will build the select statement without executing it, the soft delete
+// scope only applies when the model is provided
+func (b *builder) getStmt(model ...interface{}) (*Stmt, error) {
+	hasSoftDelete := false
+	if len(model) > 0 {
+		e, err := b.newEntity(model[0])
+		if err != nil {
+			return nil, err
+		}
+		e.setName(b.query.table)
+		b.query.table = e.Name()
+		b.query.pk = e.pk
+		hasSoftDelete = e.hasSoftDelete()
+	}
+	if b.query.table == "" {
+		return nil, fmt.Errorf("goloquent: missing table name")
+	}
+	cmd, err := b.buildGet(hasSoftDelete)
+	if err != nil {
+		return nil, err
+	}
+	return &Stmt{stmt: *cmd, replacer: b.db.dialect}, nil
+}
+
+func (b *builder) run(table string, cmd *stmt) (*Iterator, error) {
+	rows, cancel, err := b.readClient().execQuery(cmd)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	defer cancel()
+	defer rows.Close()
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, fmt.Errorf("goloquent: %w", err)
+	}
+
+	it := Iterator{
+		table:    table,
+		pk:       b.pk(),
+		deleted:  b.db.client.deletedColumn(),
+		stmt:     &Stmt{stmt: *cmd, replacer: b.db.dialect},
+		position: -1,
+		columns:  cols,
+	}
+
+	i := 0
+	for rows.Next() {
+		if err := it.scanRow(rows, i); err != nil {
+			return nil, err
+		}
+		i++
 	}
 
 	return &it, nil
 }
 
+// each will scan the record one by one from the rows without buffering all the records
+func (b *builder) each(model interface{}, fn func(interface{}) error) error {
+	e, err := b.newEntity(model)
+	if err != nil {
+		return err
+	}
+	e.setName(b.query.table)
+	cmd, err := b.getCommand(e)
+	if err != nil {
+		return err
+	}
+
+	rows, cancel, err := b.readClient().execQuery(cmd)
+	if err != nil {
+		return wrapError(err)
+	}
+	defer cancel()
+	defer rows.Close()
+	cols, err := rows.Columns()
+	if err != nil {
+		return fmt.Errorf("goloquent: %w", err)
+	}
+
+	it := Iterator{
+		table:   e.Name(),
+		pk:      e.pk,
+		deleted: e.deleted,
+		stmt:    &Stmt{stmt: *cmd, replacer: b.db.dialect},
+		columns: cols,
+	}
+	t := reflect.TypeOf(model).Elem()
+	for rows.Next() {
+		it.results = nil
+		if err := it.scanRow(rows, 0); err != nil {
+			return err
+		}
+		it.position = 0
+		vi := reflect.New(t)
+		if err := it.Scan(vi.Interface()); err != nil {
+			return err
+		}
+		if err := fn(vi.Interface()); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return wrapError(err)
+	}
+	return nil
+}
+
 func (b *builder) get(model interface{}, mustExist bool) error {
-	e, err := newEntity(model)
+	e, err := b.newEntity(model)
 	if err != nil {
 		return err
 	}
@@ -468,8 +1093,48 @@ func (b *builder) get(model interface{}, mustExist bool) error {
 	return nil
 }
 
+// findMulti will get the records of the keys in the order of the keys, the element of the missing
+// record is left as zero value, and the missing keys are returned as `NotFoundError`
+func (b *builder) findMulti(keys []*datastore.Key, model interface{}) error {
+	e, err := b.newEntity(model)
+	if err != nil {
+		return err
+	}
+	v := reflect.Indirect(reflect.ValueOf(model))
+	records := reflect.New(v.Type())
+	if len(keys) > 0 {
+		if err := b.getMulti(records.Interface()); err != nil {
+			return err
+		}
+	}
+
+	found := make(map[string]reflect.Value)
+	f := e.field(keyFieldName)
+	for i := 0; i < records.Elem().Len(); i++ {
+		r := records.Elem().Index(i)
+		if k, isOk := mustGetField(r, f).Interface().(*datastore.Key); isOk && k != nil {
+			found[stringPk(k)] = r
+		}
+	}
+	vv := reflect.MakeSlice(v.Type(), len(keys), len(keys))
+	missing := make(NotFoundError)
+	for i, k := range keys {
+		r, isOk := found[stringPk(k)]
+		if !isOk {
+			missing[i] = k
+			continue
+		}
+		vv.Index(i).Set(r)
+	}
+	v.Set(vv)
+	if len(missing) > 0 {
+		return missing
+	}
+	return nil
+}
+
 func (b *builder) getMulti(model interface{}) error {
-	e, err := newEntity(model)
+	e, err := b.newEntity(model)
 	if err != nil {
 		return err
 	}
@@ -502,6 +1167,57 @@ func (b *builder) getMulti(model interface{}) error {
 	return nil
 }
 
+// getMaps will scan the records into maps keyed by column name, the table must be set on the query
+func (b *builder) getMaps(dest *[]map[string]interface{}) error {
+	if dest == nil {
+		return fmt.Errorf("goloquent: destination cannot be nil")
+	}
+	table := b.query.table
+	if table == "" {
+		return fmt.Errorf("goloquent: missing table name")
+	}
+	hasSoftDelete := false
+	if b.query.onlyTrashed || !b.query.noScope {
+		hasSoftDelete = b.hasSoftDelete(table)
+	}
+	cmd, err := b.buildGet(hasSoftDelete)
+	if err != nil {
+		return err
+	}
+
+	rows, cancel, err := b.readClient().execQuery(cmd)
+	if err != nil {
+		return wrapError(err)
+	}
+	defer cancel()
+	defer rows.Close()
+	cols, err := rows.Columns()
+	if err != nil {
+		return fmt.Errorf("goloquent: %w", err)
+	}
+
+	results := make([]map[string]interface{}, 0)
+	for rows.Next() {
+		m := make([]interface{}, len(cols))
+		for i := range cols {
+			m[i] = &m[i]
+		}
+		if err := rows.Scan(m...); err != nil {
+			return fmt.Errorf("goloquent: %w", err)
+		}
+		data := make(map[string]interface{}, len(cols))
+		for i, name := range cols {
+			data[name] = baseToInterface(m[i])
+		}
+		results = append(results, data)
+	}
+	if err := rows.Err(); err != nil {
+		return wrapError(err)
+	}
+	*dest = results
+	return nil
+}
+
 func baseToInterface(it interface{}) interface{} {
 	var v interface{}
 	switch vi := it.(type) {
@@ -517,8 +1233,33 @@ func baseToInterface(it interface{}) interface{} {
 	return v
 }
 
+// buildCursorFilter will build the keyset filter of the records after the cursor record, `values` are the
+// order fields of the cursor record. A record is after the cursor when it's equal on the leading order
+// fields and after on the next one, such as `(a > ?) OR (a = ? AND b < ?) OR (a = ? AND b = ? AND key > ?)`
+func (b *builder) buildCursorFilter(orders []order, values []interface{}) *stmt {
+	or, args := make([]string, 0, len(orders)), make([]interface{}, 0)
+	for i, o := range orders {
+		and := make([]string, 0, i+1)
+		for j := 0; j < i; j++ {
+			and = append(and, fmt.Sprintf("%s = %s", b.quoteColumn(orders[j].field), variable))
+			args = append(args, values[j])
+		}
+		op := ">"
+		if o.direction == descending {
+			op = "<"
+		}
+		and = append(and, fmt.Sprintf("%s %s %s", b.quoteColumn(o.field), op, variable))
+		args = append(args, values[i])
+		or = append(or, "("+strings.Join(and, " AND ")+")")
+	}
+	return &stmt{
+		statement: bytes.NewBufferString("(" + strings.Join(or, " OR ") + ")"),
+		arguments: args,
+	}
+}
+
 func (b *builder) paginate(p *Pagination, model interface{}) error {
-	e, err := newEntity(model)
+	e, err := b.newEntity(model)
 	if err != nil {
 		return err
 	}
@@ -532,33 +1273,32 @@ func (b *builder) paginate(p *Pagination, model interface{}) error {
 	if p.Cursor != "" {
 		c, err := DecodeCursor(p.Cursor)
 		if err != nil {
-			return err
+			return ErrInvalidCursor
 		}
 		if sha1Sign(&Stmt{stmt: *cmds, replacer: b.db.dialect}) != c.Signature {
 			return ErrInvalidCursor
 		}
 		query := b.query
 		buf, args := new(bytes.Buffer), make([]interface{}, 0)
-		buf.WriteString(b.buildSelect(query).string())
+		ss := b.buildSelect(query)
+		buf.WriteString(ss.string())
+		args = append(args, ss.arguments...)
 		buf.WriteString(fmt.Sprintf(" FROM %s", b.db.dialect.GetTable(e.Name())))
-		if !query.noScope && e.hasSoftDelete() {
-			query.filters = append(query.filters, Filter{
-				field:    softDeleteColumn,
-				operator: Equal,
-				value:    nil,
-			})
-		}
+		buf.WriteString(b.buildJoin(query).string())
+		query = softDeleteScope(query, e.hasSoftDelete())
 		cmd, err := b.buildWhere(query)
 		if err != nil {
 			return err
 		}
 
-		orders := query.orders
+		orders := make([]order, len(query.orders))
+		copy(orders, query.orders)
 		projection := make([]string, 0, len(orders))
-		for _, o := range orders {
-			projection = append(projection, o.field)
+		for i, o := range orders {
+			orders[i].field = b.column(o.field)
+			projection = append(projection, orders[i].field)
 		}
-		values, or := make([]interface{}, len(orders)), make([]string, 0)
+		values := make([]interface{}, len(orders))
 		for i := 0; i < len(values); i++ {
 			values[i] = &values[i]
 		}
@@ -571,30 +1311,17 @@ func (b *builder) paginate(p *Pagination, model interface{}) error {
 			}
 		}
 		if err := b.db.Table(e.Name()).
-			WhereEqual(keyFieldName, c.Key).
+			WhereEqual(b.pk(), stringPk(c.Key)).
 			Select(projection...).
 			Limit(1).Scan(values...); err != nil {
 			return ErrInvalidCursor
 		}
-		arg := make([]interface{}, 0, len(orders))
-		for i, o := range orders {
-			vv := baseToInterface(values[i])
-			op := ">="
-			if o.direction == descending {
-				op = "<="
-			}
-			if i < len(orders)-1 {
-				buf.WriteString(fmt.Sprintf("%s %s %s AND ",
-					b.db.dialect.Quote(o.field), op, variable))
-				args = append(args, vv)
-				op = strings.Trim(op, "=")
-			}
-			or = append(or, fmt.Sprintf("%s %s %s",
-				b.db.dialect.Quote(o.field), op, variable))
-			arg = append(arg, vv)
+		for i := range values {
+			values[i] = baseToInterface(values[i])
 		}
-		buf.WriteString("(" + strings.Join(or, " OR ") + ")")
-		args = append(args, arg...)
+		filter := b.buildCursorFilter(orders, values)
+		buf.WriteString(filter.string())
+		args = append(args, filter.arguments...)
 		buf.WriteString(b.buildOrder(query).string())
 		buf.WriteString(b.buildLimitOffset(query).string())
 		buf.WriteString(";")
@@ -639,28 +1366,58 @@ func (b *builder) paginate(p *Pagination, model interface{}) error {
 	return nil
 }
 
+// replaceInto will copy the records of the query into the table, the columns are the selected columns,
+// or the columns exist in both tables if nothing is selected, so they are mapped by name rather than position.
+// The soft deleted records are not copied unless the query is `Unscoped` or `WithTrashed`
 func (b *builder) replaceInto(table string) error {
-	buf, args := new(bytes.Buffer), make([]interface{}, 0)
-	buf.WriteString("REPLACE INTO ")
-	buf.WriteString(b.db.dialect.GetTable(table))
-	buf.WriteString(" ")
-	cmd := b.buildSelect(b.query)
-	buf.WriteString(cmd.string())
-	buf.WriteString(" FROM " + b.db.dialect.GetTable(b.query.table))
-	cmd, err := b.buildWhere(b.query)
+	query := b.query
+	if len(query.rawSelects) > 0 {
+		return fmt.Errorf("goloquent: `SelectRaw` is not supported by `ReplaceInto`")
+	}
+	cols := make([]string, 0, len(query.projection))
+	exprs := make([]string, 0, len(query.projection))
+	for _, p := range query.projection {
+		col := b.column(p)
+		exprs = append(exprs, b.quoteColumn(col))
+		// the column of the joined table is inserted into the column of the same name
+		if paths := strings.SplitN(col, ".", 2); len(paths) > 1 && query.hasTable(paths[0]) {
+			col = paths[1]
+		}
+		cols = append(cols, col)
+	}
+	if len(cols) <= 0 {
+		dst := newDictionary(b.tableColumns(table))
+		for _, c := range b.tableColumns(query.table) {
+			if !dst.has(c) {
+				continue
+			}
+			cols = append(cols, c)
+			if len(query.joins) > 0 {
+				exprs = append(exprs, b.db.dialect.Quote(query.table)+"."+b.db.dialect.Quote(c))
+			} else {
+				exprs = append(exprs, b.db.dialect.Quote(c))
+			}
+		}
+		if len(cols) <= 0 {
+			return fmt.Errorf("goloquent: table %q and %q have no column in common", query.table, table)
+		}
+	}
+
+	buf := new(bytes.Buffer)
+	buf.WriteString("SELECT " + strings.Join(exprs, ","))
+	buf.WriteString(" FROM " + b.db.dialect.GetTable(query.table))
+	buf.WriteString(b.buildJoin(query).string())
+	if query.onlyTrashed || !query.noScope {
+		query = softDeleteScope(query, b.hasSoftDelete(query.table))
+	}
+	cmd, err := b.buildStmt(query)
 	if err != nil {
 		return err
 	}
-	if !cmd.isZero() {
-		buf.WriteString(cmd.string())
-		args = append(args, cmd.arguments...)
-	}
-	buf.WriteString(b.buildOrder(b.query).string())
-	buf.WriteString(b.buildLimitOffset(b.query).string())
-	buf.WriteString(";")
+	buf.WriteString(cmd.string())
 	return b.db.client.execStmt(&stmt{
-		statement: buf,
-		arguments: args,
+		statement: bytes.NewBufferString(b.db.dialect.ReplaceInto(table, cols, buf.String())),
+		arguments: cmd.arguments,
 	})
 }
 
@@ -671,6 +1428,7 @@ func (b *builder) insertInto(table string) error {
 	buf.WriteString(" ")
 	cmd := b.buildSelect(b.query)
 	buf.WriteString(cmd.string())
+	args = append(args, cmd.arguments...)
 	buf.WriteString(" FROM " + b.db.dialect.GetTable(b.query.table))
 	cmd, err := b.buildWhere(b.query)
 	if err != nil {
@@ -699,6 +1457,7 @@ func (b *builder) putStmt(parentKey []*datastore.Key, e *entity) (*stmt, error)
 		}
 	}
 
+	now := time.Now().UTC()
 	cols := e.Columns()
 	buf.WriteString(fmt.Sprintf("INSERT INTO %s (%s) VALUES ",
 		b.db.dialect.GetTable(e.Name()),
@@ -727,6 +1486,14 @@ func (b *builder) putStmt(parentKey []*datastore.Key, e *entity) (*stmt, error)
 		}
 		fv.Set(reflect.ValueOf(pk))
 
+		if !b.query.noTimestamps {
+			if err := setTimestamp(vi, now, false, createdAtFields...); err != nil {
+				return nil, err
+			}
+			if err := setTimestamp(vi, now, false, updatedAtFields...); err != nil {
+				return nil, err
+			}
+		}
 		if x, isOk := vi.Interface().(Saver); isOk {
 			if err := x.Save(); err != nil {
 				return nil, err
@@ -734,17 +1501,17 @@ func (b *builder) putStmt(parentKey []*datastore.Key, e *entity) (*stmt, error)
 		}
 		props, err := SaveStruct(vi.Interface())
 		if err != nil {
-			return nil, nil
+			return nil, err
 		}
 
-		props[pkColumn] = Property{[]string{pkColumn}, typeOfPtrKey, stringPk(pk)}
+		props[keyFieldName] = Property{[]string{e.pk}, typeOfPtrKey, stringPk(pk)}
 		f.Set(vi.Elem())
 		if i != 0 {
 			buf.WriteString(",")
 		}
 		vals := make([]interface{}, len(cols), len(cols))
-		for j, c := range cols {
-			vv, err := props[c].Interface()
+		for j, c := range e.columns {
+			vv, err := props[c.Name()].Interface()
 			if err != nil {
 				return nil, err
 			}
@@ -767,104 +1534,321 @@ func (b *builder) putStmt(parentKey []*datastore.Key, e *entity) (*stmt, error)
 	}, nil
 }
 
-func (b *builder) put(model interface{}, parentKey []*datastore.Key) error {
-	e, err := newEntity(model)
-	if err != nil {
-		return err
-	}
-	e.setName(b.query.table)
-	if e.slice.Elem().Len() <= 0 {
+var (
+	createdAtFields = []string{"CreatedAt", "created_at"}
+	updatedAtFields = []string{"UpdatedAt", "updated_at"}
+	// numericTypes are the data types of the numeric columns, it's the data type from `INFORMATION_SCHEMA`
+	numericTypes = newDictionary([]string{
+		"tinyint", "smallint", "mediumint", "int", "integer", "bigint",
+		"decimal", "numeric", "float", "double", "real", "double precision",
+	})
+)
+
+// setTimestamp will set the time to the first matched `time.Time` field of the struct,
+// if `overwrite` is false, only zero value will be set
+func setTimestamp(v reflect.Value, t time.Time, overwrite bool, names ...string) error {
+	if v.Kind() != reflect.Ptr || v.IsNil() {
 		return nil
 	}
-	cmd, err := b.putStmt(parentKey, e)
+	codec, err := getStructCodec(v.Interface())
 	if err != nil {
 		return err
 	}
-	return b.db.client.execStmt(cmd)
+	dict := newDictionary(names)
+	for _, f := range codec.fields {
+		if !dict.has(f.name) {
+			continue
+		}
+		switch f.typeOf {
+		case typeOfTime:
+			fv := getField(v.Elem(), f.paths)
+			if overwrite || fv.Interface().(time.Time).IsZero() {
+				fv.Set(reflect.ValueOf(t))
+			}
+			return nil
+		case reflect.PtrTo(typeOfTime):
+			fv := getField(v.Elem(), f.paths)
+			if overwrite || fv.Elem().Interface().(time.Time).IsZero() {
+				tt := t
+				fv.Set(reflect.ValueOf(&tt))
+			}
+			return nil
+		}
+	}
+	return nil
 }
 
-func (b *builder) upsert(model interface{}, parentKey []*datastore.Key) error {
-	e, err := newEntity(model)
-	if err != nil {
-		return err
-	}
-	e.setName(b.query.table)
-	if e.slice.Elem().Len() <= 0 {
-		return nil
-	}
-	cmd, err := b.putStmt(parentKey, e)
+// seedFilters will set the struct fields with the values of the equal filters,
+// so the new record created by `FirstOrCreate` will match the query
+func seedFilters(model interface{}, filters []Filter) error {
+	codec, err := getStructCodec(model)
 	if err != nil {
 		return err
 	}
-	cols := e.Columns()
-	omits := newDictionary(b.query.omits)
-	columns := make([]string, 0, len(cols))
-	for _, c := range cols {
-		if omits.has(c) || c == pkColumn || c == keyFieldName {
+	v := reflect.ValueOf(model).Elem()
+	for _, f := range filters {
+		if f.operator != Equal || f.isJSON || f.or || f.group != nil || f.value == nil {
 			continue
 		}
-		columns = append(columns, c)
-	}
-	cmd.statement.Truncate(cmd.statement.Len() - 1)
-	buf := new(bytes.Buffer)
-	buf.WriteString(cmd.string())
-	if len(columns) > 0 {
-		buf.WriteString(" " + b.db.dialect.OnConflictUpdate(e.Name(), columns))
+		switch f.field {
+		case keyFieldName, pkColumn:
+			continue
+		}
+		for _, sf := range codec.fields {
+			if sf.name != f.field {
+				continue
+			}
+			fv := getField(v, sf.paths)
+			rv := reflect.ValueOf(f.value)
+			switch {
+			case rv.Type().AssignableTo(fv.Type()):
+				fv.Set(rv)
+			case rv.Type().ConvertibleTo(fv.Type()) && fv.Kind() != reflect.String:
+				fv.Set(rv.Convert(fv.Type()))
+			default:
+				return fmt.Errorf("goloquent: unable to set %v to field %q with type %v", rv.Type(), f.field, fv.Type())
+			}
+			break
+		}
 	}
-	buf.WriteString(";")
-	cmd.statement = buf
-	return b.db.client.execStmt(cmd)
+	return nil
 }
 
-func (b *builder) saveMutation(model interface{}) (*stmt, error) {
-	v := reflect.Indirect(reflect.ValueOf(model))
-	if v.Len() <= 0 {
-		return new(stmt), nil
+// eachEntity will call `fn` with the pointer of every entity in the slice
+func eachEntity(v reflect.Value, fn func(interface{}) error) error {
+	for i := 0; i < v.Len(); i++ {
+		vi := v.Index(i)
+		if vi.Kind() != reflect.Ptr {
+			vi = vi.Addr()
+		}
+		if vi.IsNil() {
+			continue
+		}
+		if err := fn(vi.Interface()); err != nil {
+			return err
+		}
 	}
-	e, err := newEntity(model)
+	return nil
+}
+
+func (b *builder) put(model interface{}, parentKey []*datastore.Key) (sql.Result, error) {
+	e, err := b.newEntity(model)
 	if err != nil {
 		return nil, err
 	}
 	e.setName(b.query.table)
-	buf := new(bytes.Buffer)
-	args := make([]interface{}, 0)
-	buf.WriteString(fmt.Sprintf("UPDATE %s SET ", b.db.dialect.GetTable(e.Name())))
-	f := v.Index(0)
-	if x, isOk := f.Interface().(Saver); isOk {
-		if err := x.Save(); err != nil {
-			return nil, err
+	v := e.slice.Elem()
+	if v.Len() <= 0 {
+		return driver.RowsAffected(0), nil
+	}
+	if err := eachEntity(v, func(it interface{}) error {
+		if x, isOk := it.(BeforeCreateHook); isOk {
+			return x.BeforeCreate()
 		}
+		return nil
+	}); err != nil {
+		return nil, err
 	}
-	props, err := SaveStruct(f.Interface())
+	result, err := b.execBatch(e, func(e *entity) (*stmt, error) {
+		return b.putStmt(parentKey, e)
+	})
 	if err != nil {
 		return nil, err
 	}
-
-	pk, isOk := props[keyFieldName].Value.(*datastore.Key)
-	if !isOk {
-		return nil, fmt.Errorf("goloquent: entity %q has no primary key property", f.Type().Name())
+	if err := eachEntity(v, func(it interface{}) error {
+		if x, isOk := it.(AfterCreateHook); isOk {
+			return x.AfterCreate()
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// upsert will insert the records, or update the records when the records conflict on the `keys`,
+// the conflict target is the primary key when the `keys` is empty
+func (b *builder) upsert(model interface{}, parentKey []*datastore.Key, keys ...string) error {
+	e, err := b.newEntity(model)
+	if err != nil {
+		return err
+	}
+	e.setName(b.query.table)
+	if e.slice.Elem().Len() <= 0 {
+		return nil
+	}
+	targets := make([]string, len(keys))
+	for i, k := range keys {
+		if _, isOk := e.fields[k]; !isOk && k != e.pk {
+			return fmt.Errorf("goloquent: entity %q doesn't has field %q", e.Name(), k)
+		}
+		targets[i] = e.column(k)
+	}
+	if len(targets) <= 0 {
+		targets = append(targets, e.pk)
+	}
+	omits := newDictionary(b.query.omits)
+	conflicts := newDictionary(targets)
+	// the creation time of the existing record is kept, it's only set when the record is inserted
+	createdAt := newDictionary(createdAtFields)
+	columns := make([]string, 0, len(e.columns))
+	for _, c := range e.columns {
+		col := e.column(c.Name())
+		if omits.has(c.Name()) || conflicts.has(col) || c.Name() == keyFieldName ||
+			createdAt.has(c.Name()) || createdAt.has(col) {
+			continue
+		}
+		columns = append(columns, col)
+	}
+	_, err = b.execBatch(e, func(e *entity) (*stmt, error) {
+		cmd, err := b.putStmt(parentKey, e)
+		if err != nil {
+			return nil, err
+		}
+		cmd.statement.Truncate(cmd.statement.Len() - 1)
+		buf := new(bytes.Buffer)
+		buf.WriteString(cmd.string())
+		if len(columns) > 0 {
+			buf.WriteString(" " + b.db.dialect.OnConflictUpdate(e.Name(), targets, columns))
+		}
+		buf.WriteString(";")
+		cmd.statement = buf
+		return cmd, nil
+	})
+	return err
+}
+
+// batchResult is the result of the batches, `LastInsertId` is the id of the first batch
+// and `RowsAffected` is the sum of all the batches
+type batchResult []sql.Result
+
+// LastInsertId :
+func (r batchResult) LastInsertId() (int64, error) {
+	return r[0].LastInsertId()
+}
+
+// RowsAffected :
+func (r batchResult) RowsAffected() (int64, error) {
+	var total int64
+	for _, result := range r {
+		n, err := result.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		total += n
+	}
+	return total, nil
+}
+
+// execBatch will split the entities into batches, and execute the batches within a transaction
+func (b *builder) execBatch(e *entity, build func(*entity) (*stmt, error)) (sql.Result, error) {
+	v := e.slice.Elem()
+	size := b.db.batchSize
+	if size <= 0 {
+		size = defaultBatchSize
+	}
+	if v.Len() <= size {
+		cmd, err := build(e)
+		if err != nil {
+			return nil, err
+		}
+		return b.db.client.execResult(cmd)
+	}
+
+	client := b.db.client
+	var tx *sql.Tx
+	if conn, isOk := client.sqlCommon.(*sql.DB); isOk {
+		var err error
+		tx, err = conn.Begin()
+		if err != nil {
+			return nil, fmt.Errorf("goloquent: unable to begin transaction, %w", err)
+		}
+		defer tx.Rollback()
+		client.sqlCommon = tx
+	}
+	results := make(batchResult, 0, (v.Len()+size-1)/size)
+	for i := 0; i < v.Len(); i += size {
+		j := i + size
+		if j > v.Len() {
+			j = v.Len()
+		}
+		batch := *e
+		vv := reflect.New(reflect.SliceOf(v.Type().Elem()))
+		vv.Elem().Set(v.Slice(i, j))
+		batch.slice = vv
+		cmd, err := build(&batch)
+		if err != nil {
+			return nil, err
+		}
+		result, err := client.execResult(cmd)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+	if tx != nil {
+		if err := tx.Commit(); err != nil {
+			return nil, err
+		}
+	}
+	return results, nil
+}
+
+// saveMutation will build the update statement of the entity, keyed on its own primary key
+func (b *builder) saveMutation(e *entity, f reflect.Value) (*stmt, error) {
+	if f.Kind() != reflect.Ptr {
+		f = f.Addr()
+	}
+	if f.IsNil() {
+		return nil, fmt.Errorf("goloquent: entity cannot be nil")
+	}
+	buf := new(bytes.Buffer)
+	args := make([]interface{}, 0)
+	buf.WriteString(fmt.Sprintf("UPDATE %s SET ", b.db.dialect.GetTable(e.Name())))
+	if !b.query.noTimestamps {
+		if err := setTimestamp(f, time.Now().UTC(), true, updatedAtFields...); err != nil {
+			return nil, err
+		}
+	}
+	if x, isOk := f.Interface().(Saver); isOk {
+		if err := x.Save(); err != nil {
+			return nil, err
+		}
+	}
+	props, err := SaveStruct(f.Interface())
+	if err != nil {
+		return nil, err
+	}
+
+	pk, isOk := props[keyFieldName].Value.(*datastore.Key)
+	if !isOk {
+		return nil, fmt.Errorf("goloquent: entity %q has no primary key property", f.Elem().Type().Name())
 	}
 	delete(props, keyFieldName)
 	if pk == nil || pk.Incomplete() {
 		return nil, fmt.Errorf("goloquent: invalid key value, %v", pk)
 	}
 
+	// the columns are sorted, so the statement of every entity is the same and the prepared statement is reused
+	names := make([]string, 0, len(props))
+	for k := range props {
+		names = append(names, k)
+	}
+	sort.Strings(names)
 	omits := newDictionary(b.query.omits)
-	j := int(1)
-	for k, p := range props {
+	for _, k := range names {
 		if omits.has(k) {
 			continue
 		}
-		it, err := p.Interface()
+		it, err := props[k].Interface()
 		if err != nil {
 			return nil, err
 		}
-		buf.WriteString(fmt.Sprintf("%s = %s,", b.db.dialect.Quote(k), variable))
+		buf.WriteString(fmt.Sprintf("%s = %s,", b.db.dialect.Quote(e.column(k)), variable))
 		args = append(args, it)
-		j++
 	}
 	buf.Truncate(buf.Len() - 1)
-	buf.WriteString(fmt.Sprintf(" WHERE %s = %s;", b.db.dialect.Quote(pkColumn), variable))
+	buf.WriteString(fmt.Sprintf(" WHERE %s = %s;", b.db.dialect.Quote(e.pk), variable))
 	args = append(args, stringPk(pk))
 
 	return &stmt{
@@ -873,29 +1857,164 @@ func (b *builder) saveMutation(model interface{}) (*stmt, error) {
 	}, nil
 }
 
+// SaveError : the errors of the bulk save, keyed by the index of the failed element
+type SaveError map[int]error
+
+// Error :
+func (e SaveError) Error() string {
+	idxs := make([]int, 0, len(e))
+	for i := range e {
+		idxs = append(idxs, i)
+	}
+	sort.Ints(idxs)
+	msgs := make([]string, len(idxs))
+	for i, idx := range idxs {
+		msgs[i] = fmt.Sprintf("element %d: %v", idx, e[idx])
+	}
+	return fmt.Sprintf("goloquent: unable to save %d element(s), %s", len(e), strings.Join(msgs, "; "))
+}
+
+// NotFoundError : the keys of `FindMulti` which have no record, keyed by the index of the key,
+// it matches `ErrNoSuchEntity` using `errors.Is`
+type NotFoundError map[int]*datastore.Key
+
+// Error :
+func (e NotFoundError) Error() string {
+	idxs := make([]int, 0, len(e))
+	for i := range e {
+		idxs = append(idxs, i)
+	}
+	sort.Ints(idxs)
+	msgs := make([]string, len(idxs))
+	for i, idx := range idxs {
+		msgs[i] = fmt.Sprintf("key %d: %v", idx, e[idx])
+	}
+	return fmt.Sprintf("goloquent: %d entity(s) not found, %s", len(e), strings.Join(msgs, "; "))
+}
+
+// Is :
+func (e NotFoundError) Is(target error) bool {
+	return target == ErrNoSuchEntity
+}
+
 func (b *builder) save(model interface{}) error {
 	v := reflect.ValueOf(model)
 	if !v.IsValid() {
 		return errors.New("goloquent: invalid entity to save")
 	}
-	vi := reflect.MakeSlice(reflect.SliceOf(v.Type()), 1, 1)
-	vi.Index(0).Set(v)
-	vv := reflect.New(vi.Type())
-	vv.Elem().Set(vi)
-	cmd, err := b.saveMutation(vv.Interface())
+	if v.Kind() == reflect.Ptr && v.Elem().Kind() == reflect.Slice {
+		return b.saveMulti(model)
+	}
+	e, err := b.newEntity(model)
+	if err != nil {
+		return err
+	}
+	e.setName(b.query.table)
+	if v.IsNil() {
+		return errors.New("goloquent: invalid entity to save")
+	}
+	if x, isOk := model.(BeforeUpdateHook); isOk {
+		if err := x.BeforeUpdate(); err != nil {
+			return err
+		}
+	}
+	cmd, err := b.saveMutation(e, v)
 	if err != nil {
 		return err
 	}
 	if err := b.db.client.execStmt(cmd); err != nil {
 		return err
 	}
-	v.Elem().Set(vi.Index(0).Elem())
+	if x, isOk := model.(AfterUpdateHook); isOk {
+		if err := x.AfterUpdate(); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
-func (b *builder) updateWithMap(v reflect.Value) (*stmt, error) {
+// saveMulti will update every element of the slice within a transaction, nothing
+// is saved if any of the element is failed, and the failed elements are reported by `SaveError`
+func (b *builder) saveMulti(model interface{}) error {
+	e, err := b.newEntity(model)
+	if err != nil {
+		return err
+	}
+	e.setName(b.query.table)
+	v := e.slice.Elem()
+	if v.Len() <= 0 {
+		return nil
+	}
+	errs := make(SaveError)
+	cmds := make([]*stmt, v.Len())
+	for i := 0; i < v.Len(); i++ {
+		f := v.Index(i)
+		if f.Kind() == reflect.Ptr && f.IsNil() {
+			errs[i] = fmt.Errorf("goloquent: entity cannot be nil")
+			continue
+		}
+		if f.Kind() != reflect.Ptr {
+			f = f.Addr()
+		}
+		if x, isOk := f.Interface().(BeforeUpdateHook); isOk {
+			if err := x.BeforeUpdate(); err != nil {
+				errs[i] = err
+				continue
+			}
+		}
+		cmds[i], err = b.saveMutation(e, f)
+		if err != nil {
+			errs[i] = err
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	if err := b.runInTransaction(func(tx *DB) error {
+		for i, cmd := range cmds {
+			if err := tx.client.execStmt(cmd); err != nil {
+				return SaveError{i: err}
+			}
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	return eachEntity(v, func(it interface{}) error {
+		if x, isOk := it.(AfterUpdateHook); isOk {
+			return x.AfterUpdate()
+		}
+		return nil
+	})
+}
+
+// columnValue will convert the map value to the value of the column
+func columnValue(val interface{}) (interface{}, error) {
+	v, err := normalizeValue(val)
+	if err != nil {
+		return nil, err
+	}
+	it, err := interfaceToValue(v)
+	if err != nil {
+		return nil, err
+	}
+	return marshal(it)
+}
+
+func (b *builder) updateWithMap(table string, v reflect.Value) (*stmt, error) {
 	buf := new(bytes.Buffer)
 	args := make([]interface{}, 0)
+	set := func(name string, val interface{}) error {
+		buf.WriteString(fmt.Sprintf(" %s = %s,", b.db.dialect.Quote(name), variable))
+		vi, err := columnValue(val)
+		if err != nil {
+			return err
+		}
+		args = append(args, vi)
+		return nil
+	}
+
+	keys := make(map[string]bool)
 	for _, k := range v.MapKeys() {
 		vv := v.MapIndex(k)
 		if k.Kind() != reflect.String {
@@ -905,20 +2024,26 @@ func (b *builder) updateWithMap(v reflect.Value) (*stmt, error) {
 		if kk == keyFieldName {
 			return nil, fmt.Errorf("goloquent: update __key__ is not allow")
 		}
-		buf.WriteString(fmt.Sprintf(" %s = %s,", b.db.dialect.Quote(kk), variable))
-		v, err := normalizeValue(vv.Interface())
-		if err != nil {
-			return nil, err
-		}
-		it, err := interfaceToValue(v)
-		if err != nil {
+		if err := set(kk, vv.Interface()); err != nil {
 			return nil, err
 		}
-		vi, err := marshal(it)
-		if err != nil {
-			return nil, err
+		keys[kk] = true
+	}
+
+	// the map doesn't carry the struct fields, so look for the `UpdatedAt` column on the table
+	if !b.query.noTimestamps {
+		cols := newDictionary(b.tableColumns(table))
+		for _, name := range updatedAtFields {
+			if !cols.has(name) {
+				continue
+			}
+			if !keys[name] {
+				if err := set(name, time.Now().UTC()); err != nil {
+					return nil, err
+				}
+			}
+			break
 		}
-		args = append(args, vi)
 	}
 	buf.Truncate(buf.Len() - 1)
 	return &stmt{
@@ -934,24 +2059,40 @@ func (b *builder) updateWithStruct(model interface{}) (*stmt, error) {
 	if err := checkSinglePtr(vv.Interface()); err != nil {
 		return nil, err
 	}
+	if !b.query.noTimestamps {
+		if err := setTimestamp(vv, time.Now().UTC(), true, updatedAtFields...); err != nil {
+			return nil, err
+		}
+	}
+	// the zero value fields are skipped, unless they are selected or `WithZeroValues`,
+	// the soft delete column is only written when it's selected
 	cols := newDictionary(b.query.projection)
 	buf, args := new(bytes.Buffer), make([]interface{}, 0)
 	props, err := SaveStruct(vv.Interface())
 	if err != nil {
 		return nil, err
 	}
-	for _, p := range props {
-		name := p.Name()
-		if name == keyFieldName || (!cols.has(name) && p.isZero()) {
+	names := make([]string, 0, len(props))
+	for k := range props {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		p := props[name]
+		if name == keyFieldName || (!cols.has(name) && p.isZero() &&
+			(!b.query.withZeroValues || name == softDeleteColumn)) {
 			continue
 		}
 		it, err := p.Interface()
 		if err != nil {
 			return nil, err
 		}
-		buf.WriteString(fmt.Sprintf("%s = %s,", b.db.dialect.Quote(p.Name()), variable))
+		buf.WriteString(fmt.Sprintf("%s = %s,", b.db.dialect.Quote(b.column(name)), variable))
 		args = append(args, it)
 	}
+	if buf.Len() <= 0 {
+		return nil, fmt.Errorf("goloquent: no field to update")
+	}
 	buf.Truncate(buf.Len() - 1)
 	return &stmt{
 		statement: buf,
@@ -959,47 +2100,69 @@ func (b *builder) updateWithStruct(model interface{}) (*stmt, error) {
 	}, nil
 }
 
-func (b *builder) updateMulti(v interface{}) error {
+// updateStmt will build the update statement, it's nil when there is nothing to update
+func (b *builder) updateStmt(v interface{}) (*stmt, error) {
 	vi := reflect.Indirect(reflect.ValueOf(v))
-	buf, args := new(bytes.Buffer), make([]interface{}, 0)
+	if !vi.IsValid() {
+		return nil, fmt.Errorf("goloquent: value for `Update` cannot be nil")
+	}
 	table := b.query.table
 	if table == "" {
-		table = vi.Type().Name()
+		table = tableName(vi.Type())
 	}
 	if table == "" {
-		return fmt.Errorf("goloquent: missing table name")
+		return nil, fmt.Errorf("goloquent: missing table name")
 	}
-	buf.WriteString(fmt.Sprintf("UPDATE %s SET", b.db.dialect.GetTable(table)))
+	set := new(stmt)
 	switch vi.Type().Kind() {
 	case reflect.Map:
 		if vi.IsNil() || vi.Len() == 0 {
-			return nil
+			return nil, nil
 		}
-		cmd, err := b.updateWithMap(vi)
+		cmd, err := b.updateWithMap(table, vi)
 		if err != nil {
-			return err
+			return nil, err
 		}
-		buf.WriteString(cmd.string())
-		args = append(args, cmd.arguments...)
+		set = cmd
 	case reflect.Struct:
+		if b.query.pk == "" {
+			b.query.pk = primaryKey(vi.Type())
+		}
 		cmd, err := b.updateWithStruct(v)
 		if err != nil {
-			return err
+			return nil, err
 		}
-		buf.WriteString(" " + cmd.string())
-		args = append(args, cmd.arguments...)
+		set.statement = bytes.NewBufferString(" " + cmd.string())
+		set.arguments = cmd.arguments
 	default:
-		return fmt.Errorf("goloquent: unsupported data type %v on `Update`", vi.Type())
+		return nil, fmt.Errorf("goloquent: unsupported data type %v on `Update`", vi.Type())
+	}
+	return b.buildUpdate(table, set)
+}
+
+func (b *builder) updateMulti(v interface{}) (int64, error) {
+	cmd, err := b.updateStmt(v)
+	if err != nil || cmd == nil {
+		return 0, err
 	}
+	return b.db.client.execAffected(cmd)
+}
+
+// buildUpdate will build the update statement of the records which match the query, `set` is the assignments
+func (b *builder) buildUpdate(table string, set *stmt) (*stmt, error) {
+	buf, args := new(bytes.Buffer), make([]interface{}, 0)
+	buf.WriteString(fmt.Sprintf("UPDATE %s SET", b.db.dialect.GetTable(table)))
+	buf.WriteString(set.string())
+	args = append(args, set.arguments...)
 	cmd, err := b.buildStmt(b.query)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	if b.query.limit > 0 && !b.db.dialect.UpdateWithLimit() {
 		buf.WriteString(fmt.Sprintf(" WHERE %s IN (",
-			b.db.dialect.Quote(pkColumn)))
+			b.db.dialect.Quote(b.pk())))
 		buf.WriteString(fmt.Sprintf("SELECT %s FROM %s",
-			b.db.dialect.Quote(pkColumn),
+			b.db.dialect.Quote(b.pk()),
 			b.db.dialect.GetTable(table)))
 		buf.WriteString(cmd.string())
 		buf.WriteString(")")
@@ -1007,103 +2170,301 @@ func (b *builder) updateMulti(v interface{}) error {
 		buf.WriteString(cmd.string())
 	}
 	buf.WriteString(";")
-	return b.db.client.execStmt(&stmt{
+	return &stmt{
 		statement: buf,
 		arguments: append(args, cmd.arguments...),
-	})
+	}, nil
 }
 
-func (b *builder) concatKeys(e *entity) (*stmt, error) {
-	v := e.slice.Elem()
+// incrementStmt will build the statement to increase (op is `+`) or decrease (op is `-`) the fields
+// by the delta, the `columns` are the data type of the table columns which used to validate the fields
+func (b *builder) incrementStmt(op string, fields map[string]interface{}, columns map[string]string) (*stmt, error) {
+	table := b.query.table
+	if table == "" {
+		return nil, fmt.Errorf("goloquent: missing table name")
+	}
+	if len(fields) <= 0 {
+		return nil, fmt.Errorf("goloquent: no field to increment")
+	}
+	names := make([]string, 0, len(fields))
+	for k := range fields {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
 	buf, args := new(bytes.Buffer), make([]interface{}, 0)
-	buf.WriteString("(")
-	for i := 0; i < v.Len(); i++ {
-		f := v.Index(i)
-		if i != 0 {
-			buf.WriteString(",")
+	for _, name := range names {
+		delta := fields[name]
+		switch reflect.Indirect(reflect.ValueOf(delta)).Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+			reflect.Float32, reflect.Float64:
+		default:
+			return nil, fmt.Errorf("goloquent: increment value of field %q must be numeric, but get %T", name, delta)
 		}
-		kk, isOk := mustGetField(f, e.field(keyFieldName)).Interface().(*datastore.Key)
+		dataType, isOk := columns[name]
 		if !isOk {
-			return nil, fmt.Errorf("goloquent: entity %q has no primary key property", f.Type().Name())
+			return nil, fmt.Errorf("goloquent: table %q has no column %q", table, name)
 		}
-		if kk.Incomplete() {
-			return nil, fmt.Errorf("goloquent: entity %q has incomplete key", f.Type().Name())
+		if !numericTypes.has(dataType) {
+			return nil, fmt.Errorf("goloquent: column %q with data type %q is not numeric", name, dataType)
+		}
+		col := b.db.dialect.Quote(name)
+		buf.WriteString(fmt.Sprintf(" %s = %s %s %s,", col, col, op, variable))
+		args = append(args, reflect.Indirect(reflect.ValueOf(delta)).Interface())
+	}
+	if !b.query.noTimestamps {
+		for _, name := range updatedAtFields {
+			if _, isOk := columns[name]; isOk {
+				buf.WriteString(fmt.Sprintf(" %s = %s,", b.db.dialect.Quote(name), variable))
+				args = append(args, time.Now().UTC())
+				break
+			}
 		}
-		buf.WriteString(variable)
-		args = append(args, stringPk(kk))
 	}
-	buf.WriteString(")")
-	return &stmt{
+	buf.Truncate(buf.Len() - 1)
+	return b.buildUpdate(table, &stmt{
 		statement: buf,
 		arguments: args,
-	}, nil
+	})
 }
 
-func (b *builder) softDeleteStmt(e *entity) (*stmt, error) {
-	buf, args := new(bytes.Buffer), make([]interface{}, 0)
-	buf.WriteString(fmt.Sprintf("UPDATE %s SET ", b.db.dialect.GetTable(e.Name())))
-	buf.WriteString(fmt.Sprintf("%s = %s WHERE %s IN ",
-		b.db.dialect.Quote(softDeleteColumn), variable, b.db.dialect.Quote(pkColumn)))
-	args = append(args, time.Now().UTC().Format("2006-01-02 15:04:05"))
-	ss, err := b.concatKeys(e)
-	if err != nil {
-		return nil, err
+var jsonPathRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// jsonPaths will split the json path, such as `$.address.city` or `address.city`, into the keys
+func jsonPaths(path string) ([]string, error) {
+	path = strings.TrimPrefix(strings.TrimSpace(path), "$.")
+	paths := strings.Split(path, ".")
+	for _, p := range paths {
+		if !jsonPathRegexp.MatchString(p) {
+			return nil, fmt.Errorf("goloquent: invalid json path %q", path)
+		}
 	}
-	buf.WriteString(ss.string())
-	buf.WriteString(";")
-	return &stmt{
-		statement: buf,
-		arguments: append(args, ss.arguments...),
-	}, nil
+	return paths, nil
 }
 
-func (b *builder) deleteStmt(e *entity, isSoftDelete bool) (*stmt, error) {
-	buf, args := new(bytes.Buffer), make([]interface{}, 0)
-	if isSoftDelete && e.hasSoftDelete() {
-		return b.softDeleteStmt(e)
+// updateJSONStmt will build the statement to replace the value of the json path,
+// the other keys of the json column are untouched
+func (b *builder) updateJSONStmt(field, path string, value interface{}) (*stmt, error) {
+	table := b.query.table
+	if table == "" {
+		return nil, fmt.Errorf("goloquent: missing table name")
 	}
-	buf.WriteString(fmt.Sprintf("DELETE FROM %s WHERE %s IN ",
-		b.db.dialect.GetTable(e.Name()),
-		b.db.dialect.Quote(pkColumn)))
-	ss, err := b.concatKeys(e)
+	field = strings.TrimSpace(field)
+	if field == "" {
+		return nil, fmt.Errorf("goloquent: field cannot be empty")
+	}
+	if field == keyFieldName || field == pkColumn {
+		return nil, fmt.Errorf("goloquent: update __key__ is not allow")
+	}
+	paths, err := jsonPaths(path)
 	if err != nil {
 		return nil, err
 	}
-	buf.WriteString(ss.string())
-	buf.WriteString(";")
+	v, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("goloquent: unable to marshal the value %v", value)
+	}
+
+	buf := new(bytes.Buffer)
+	args := []interface{}{string(v)}
+	buf.WriteString(fmt.Sprintf(" %s = %s,", b.db.dialect.Quote(field), b.db.dialect.SetJSON(field, paths)))
+	if !b.query.noTimestamps {
+		cols := newDictionary(b.tableColumns(table))
+		for _, name := range updatedAtFields {
+			if cols.has(name) {
+				buf.WriteString(fmt.Sprintf(" %s = %s,", b.db.dialect.Quote(name), variable))
+				args = append(args, time.Now().UTC())
+				break
+			}
+		}
+	}
+	buf.Truncate(buf.Len() - 1)
+	return b.buildUpdate(table, &stmt{
+		statement: buf,
+		arguments: args,
+	})
+}
+
+func (b *builder) updateJSON(field, path string, value interface{}) error {
+	cmd, err := b.updateJSONStmt(field, path, value)
+	if err != nil {
+		return err
+	}
+	return b.db.client.execStmt(cmd)
+}
+
+func (b *builder) increment(op string, fields map[string]interface{}) error {
+	cmd, err := b.incrementStmt(op, fields, b.db.dialect.GetColumnTypes(b.query.table))
+	if err != nil {
+		return err
+	}
+	return b.db.client.execStmt(cmd)
+}
+
+// concatKeys will return nil when there is no entity, the caller should skip the statement
+// instead of building an invalid `IN ()`
+func (b *builder) concatKeys(e *entity) (*stmt, error) {
+	v := e.slice.Elem()
+	if v.Len() <= 0 {
+		return nil, nil
+	}
+	buf, args := new(bytes.Buffer), make([]interface{}, 0)
+	buf.WriteString("(")
+	for i := 0; i < v.Len(); i++ {
+		f := v.Index(i)
+		if i != 0 {
+			buf.WriteString(",")
+		}
+		kk, isOk := mustGetField(f, e.field(keyFieldName)).Interface().(*datastore.Key)
+		if !isOk {
+			return nil, fmt.Errorf("goloquent: entity %q has no primary key property", f.Type().Name())
+		}
+		if kk.Incomplete() {
+			return nil, fmt.Errorf("goloquent: entity %q has incomplete key", f.Type().Name())
+		}
+		buf.WriteString(variable)
+		args = append(args, stringPk(kk))
+	}
+	buf.WriteString(")")
+	return &stmt{
+		statement: buf,
+		arguments: args,
+	}, nil
+}
+
+func (b *builder) softDeleteStmt(e *entity) (*stmt, error) {
+	buf, args := new(bytes.Buffer), make([]interface{}, 0)
+	buf.WriteString(fmt.Sprintf("UPDATE %s SET ", b.db.dialect.GetTable(e.Name())))
+	buf.WriteString(fmt.Sprintf("%s = %s WHERE %s IN ",
+		b.db.dialect.Quote(e.deleted), variable, b.db.dialect.Quote(e.pk)))
+	args = append(args, time.Now().UTC().Format("2006-01-02 15:04:05"))
+	ss, err := b.concatKeys(e)
+	if err != nil || ss == nil {
+		return nil, err
+	}
+	buf.WriteString(ss.string())
+	buf.WriteString(";")
+	return &stmt{
+		statement: buf,
+		arguments: append(args, ss.arguments...),
+	}, nil
+}
+
+func (b *builder) deleteStmt(e *entity, isSoftDelete bool) (*stmt, error) {
+	buf, args := new(bytes.Buffer), make([]interface{}, 0)
+	if isSoftDelete && e.hasSoftDelete() {
+		return b.softDeleteStmt(e)
+	}
+	buf.WriteString(fmt.Sprintf("DELETE FROM %s WHERE %s IN ",
+		b.db.dialect.GetTable(e.Name()),
+		b.db.dialect.Quote(e.pk)))
+	ss, err := b.concatKeys(e)
+	if err != nil || ss == nil {
+		return nil, err
+	}
+	buf.WriteString(ss.string())
+	buf.WriteString(";")
 	return &stmt{
 		statement: buf,
 		arguments: append(args, ss.arguments...),
 	}, nil
 }
 
-func (b *builder) delete(model interface{}, isSoftDelete bool) error {
-	e, err := newEntity(model)
+func (b *builder) delete(model interface{}, isSoftDelete bool) (int64, error) {
+	e, err := b.newEntity(model)
 	if err != nil {
-		return err
+		return 0, err
 	}
 	e.setName(b.query.table)
 	cmd, err := b.deleteStmt(e, isSoftDelete)
+	if err != nil || cmd == nil {
+		return 0, err
+	}
+	return b.db.client.execAffected(cmd)
+}
+
+func (b *builder) restoreStmt(e *entity) (*stmt, error) {
+	if !e.hasSoftDelete() {
+		return nil, fmt.Errorf("goloquent: entity %q has no soft delete column", e.Name())
+	}
+	buf := new(bytes.Buffer)
+	buf.WriteString(fmt.Sprintf("UPDATE %s SET %s = NULL WHERE %s IN ",
+		b.db.dialect.GetTable(e.Name()),
+		b.db.dialect.Quote(e.deleted),
+		b.db.dialect.Quote(e.pk)))
+	ss, err := b.concatKeys(e)
+	if err != nil || ss == nil {
+		return nil, err
+	}
+	buf.WriteString(ss.string())
+	buf.WriteString(";")
+	return &stmt{
+		statement: buf,
+		arguments: ss.arguments,
+	}, nil
+}
+
+func (b *builder) restore(model interface{}) error {
+	e, err := b.newEntity(model)
 	if err != nil {
 		return err
 	}
+	e.setName(b.query.table)
+	if e.slice.Elem().Len() <= 0 {
+		return nil
+	}
+	cmd, err := b.restoreStmt(e)
+	if err != nil || cmd == nil {
+		return err
+	}
 	return b.db.client.execStmt(cmd)
 }
 
-func (b *builder) deleteByQuery() error {
+func (b *builder) restoreByQuery() error {
 	query := b.query
-	cmd, err := b.buildStmt(query)
+	if query.table == "" {
+		return fmt.Errorf("goloquent: missing table name")
+	}
+	if !b.hasSoftDelete(query.table) {
+		return fmt.Errorf("goloquent: table %q has no soft delete column", query.table)
+	}
+	cmd, err := b.buildWhere(query)
 	if err != nil {
 		return err
 	}
 	buf := new(bytes.Buffer)
-	buf.WriteString(fmt.Sprintf("DELETE FROM %s", b.db.dialect.GetTable(query.table)))
+	buf.WriteString(fmt.Sprintf("UPDATE %s SET %s = NULL",
+		b.db.dialect.GetTable(query.table),
+		b.db.dialect.Quote(b.db.client.deletedColumn())))
 	buf.WriteString(cmd.string())
 	buf.WriteString(";")
 	cmd.statement = buf
 	return b.db.client.execStmt(cmd)
 }
 
+func (b *builder) deleteByQueryStmt() (*stmt, error) {
+	query := b.query
+	cmd, err := b.buildStmt(query)
+	if err != nil {
+		return nil, err
+	}
+	buf := new(bytes.Buffer)
+	buf.WriteString(fmt.Sprintf("DELETE FROM %s", b.db.dialect.GetTable(query.table)))
+	buf.WriteString(cmd.string())
+	buf.WriteString(";")
+	cmd.statement = buf
+	return cmd, nil
+}
+
+func (b *builder) deleteByQuery() (int64, error) {
+	cmd, err := b.deleteByQueryStmt()
+	if err != nil {
+		return 0, err
+	}
+	return b.db.client.execAffected(cmd)
+}
+
 func (b *builder) truncate(tables ...string) error {
 	for _, n := range tables {
 		buf := new(bytes.Buffer)
@@ -1117,41 +2478,457 @@ func (b *builder) truncate(tables ...string) error {
 	return nil
 }
 
-func (b *builder) scan(dest ...interface{}) error {
-	query := b.query
-	table := query.table
+func (b *builder) scanStmt(query scope) (*stmt, error) {
 	buf := new(bytes.Buffer)
-	buf.WriteString(b.buildSelect(query).string())
-	buf.WriteString(fmt.Sprintf(" FROM %s", b.db.dialect.GetTable(table)))
-	ss, err := b.buildStmt(b.query)
+	cmd := b.buildSelect(query)
+	buf.WriteString(cmd.string())
+	buf.WriteString(fmt.Sprintf(" FROM %s", b.db.dialect.GetTable(query.table)))
+	buf.WriteString(b.buildJoin(query).string())
+	ss, err := b.buildStmt(query, cmd.arguments...)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	buf.WriteString(ss.string())
 	buf.WriteString(";")
-	if err := b.db.client.execQueryRow(&stmt{
+	return &stmt{
 		statement: buf,
 		arguments: ss.arguments,
-	}).Scan(dest...); err != nil {
-		return fmt.Errorf("goloquent: %v", err)
+	}, nil
+}
+
+func (b *builder) scan(dest ...interface{}) error {
+	cmd, err := b.scanStmt(b.query)
+	if err != nil {
+		return err
+	}
+	if err := b.readClient().execQueryRow(cmd, dest...); err != nil {
+		return wrapError(err)
 	}
 	return nil
 }
 
+// scanStruct will scan the first record into the struct, the columns are mapped
+// to the struct fields by the column name, so the struct doesn't need to be an entity
+func (b *builder) scanStruct(dest interface{}) error {
+	if v := reflect.ValueOf(dest); v.Kind() != reflect.Ptr || v.IsNil() {
+		return fmt.Errorf("goloquent: struct is not addressable")
+	}
+	query := b.query
+	query.limit = 1
+	cmd, err := b.scanStmt(query)
+	if err != nil {
+		return err
+	}
+	it, err := b.run(query.table, cmd)
+	if err != nil {
+		return err
+	}
+	if it.First() == nil {
+		return fmt.Errorf("goloquent: %w", sql.ErrNoRows)
+	}
+	return it.Scan(dest)
+}
+
+// hasSoftDelete will check the table whether it has soft delete column,
+// it's only required when the query doesn't has any entity
+func (b *builder) hasSoftDelete(table string) bool {
+	return newDictionary(b.tableColumns(table)).has(b.db.client.deletedColumn())
+}
+
+// tableColumns will return the columns of the table, they are cached per table on the connection
+func (b *builder) tableColumns(table string) []string {
+	if cols, isOk := b.db.columns.get(table); isOk {
+		return cols
+	}
+	cols := b.db.dialect.GetColumns(table)
+	// the table is not exists yet, so it's looked up again after it's created
+	if len(cols) > 0 {
+		b.db.columns.set(table, cols)
+	}
+	return cols
+}
+
+// buildAggregate will build the aggregate of the records which match the query, the aggregate of
+// the grouped query is ambiguous, so it's rejected when there is `GroupBy` or `Having`
+func (b *builder) buildAggregate(expr string) (*stmt, error) {
+	if len(b.query.groupBy) > 0 || len(b.query.havings) > 0 {
+		return nil, fmt.Errorf("goloquent: aggregate is not supported with `GroupBy` or `Having`")
+	}
+	return b.buildSelectAggregate(expr)
+}
+
+// buildSelectAggregate will build the select statement of `expr` with the filters, groups and having of the query
+func (b *builder) buildSelectAggregate(expr string) (*stmt, error) {
+	query := b.query
+	table := query.table
+	if table == "" {
+		return nil, fmt.Errorf("goloquent: missing table name")
+	}
+	if query.onlyTrashed || !query.noScope {
+		query = softDeleteScope(query, b.hasSoftDelete(table))
+	}
+	buf, args := new(bytes.Buffer), make([]interface{}, 0)
+	buf.WriteString(fmt.Sprintf("SELECT %s FROM %s", expr, b.db.dialect.GetTable(table)))
+	buf.WriteString(b.buildJoin(query).string())
+	cmd, err := b.buildWhere(query)
+	if err != nil {
+		return nil, err
+	}
+	if !cmd.isZero() {
+		buf.WriteString(cmd.string())
+		args = append(args, cmd.arguments...)
+	}
+	buf.WriteString(b.buildGroupBy(query).string())
+	cmd, err = b.buildHaving(query)
+	if err != nil {
+		return nil, err
+	}
+	buf.WriteString(cmd.string())
+	args = append(args, cmd.arguments...)
+	return &stmt{
+		statement: buf,
+		arguments: args,
+	}, nil
+}
+
+func (b *builder) aggregate(expr string, dest interface{}) error {
+	cmd, err := b.buildAggregate(expr)
+	if err != nil {
+		return err
+	}
+	cmd.statement.WriteString(";")
+	if err := b.readClient().execQueryRow(cmd, dest); err != nil {
+		return wrapError(err)
+	}
+	return nil
+}
+
+// existsStmt will stop scanning on the first matched record, the entity columns are never selected
+func (b *builder) existsStmt() (*stmt, error) {
+	cmd, err := b.buildSelectAggregate("1")
+	if err != nil {
+		return nil, err
+	}
+	buf := new(bytes.Buffer)
+	buf.WriteString("SELECT EXISTS(")
+	buf.WriteString(cmd.string())
+	buf.WriteString(" LIMIT 1);")
+	return &stmt{
+		statement: buf,
+		arguments: cmd.arguments,
+	}, nil
+}
+
+func (b *builder) exists() (bool, error) {
+	cmd, err := b.existsStmt()
+	if err != nil {
+		return false, err
+	}
+	var isExist bool
+	if err := b.readClient().execQueryRow(cmd, &isExist); err != nil {
+		return false, wrapError(err)
+	}
+	return isExist, nil
+}
+
+// countStmt will count the groups instead of the records when the query is grouped, the count of
+// multiple distinct columns is MySQL only, so the distinct rows are counted using the subquery as well
+func (b *builder) countStmt() (*stmt, error) {
+	distinctOn := make([]string, len(b.query.distinctOn))
+	for i, f := range b.query.distinctOn {
+		distinctOn[i] = b.quoteIfNecessary(f)
+	}
+	if len(b.query.groupBy) == 0 && len(b.query.havings) == 0 {
+		switch len(distinctOn) {
+		case 0:
+			return b.buildAggregate("COUNT(*)")
+		case 1:
+			return b.buildAggregate(fmt.Sprintf("COUNT(DISTINCT %s)", distinctOn[0]))
+		}
+	}
+	expr := "1"
+	if len(distinctOn) > 0 {
+		expr = "DISTINCT " + strings.Join(distinctOn, ",")
+	}
+	cmd, err := b.buildSelectAggregate(expr)
+	if err != nil {
+		return nil, err
+	}
+	buf := new(bytes.Buffer)
+	buf.WriteString(fmt.Sprintf("SELECT COUNT(*) FROM (%s) AS %s", cmd.string(), b.db.dialect.Quote("t")))
+	return &stmt{
+		statement: buf,
+		arguments: cmd.arguments,
+	}, nil
+}
+
+func (b *builder) count() (int64, error) {
+	cmd, err := b.countStmt()
+	if err != nil {
+		return 0, err
+	}
+	cmd.statement.WriteString(";")
+	var count int64
+	if err := b.readClient().execQueryRow(cmd, &count); err != nil {
+		return 0, wrapError(err)
+	}
+	return count, nil
+}
+
+// countDistinctStmt will count the distinct values of the field, null is not counted
+func (b *builder) countDistinctStmt(field string) (*stmt, error) {
+	field = strings.TrimSpace(field)
+	if field == "" {
+		return nil, fmt.Errorf("goloquent: field cannot be empty")
+	}
+	cmd, err := b.buildAggregate(fmt.Sprintf("COUNT(DISTINCT %s)", b.aggregateColumn(field)))
+	if err != nil {
+		return nil, err
+	}
+	cmd.statement.WriteString(";")
+	return cmd, nil
+}
+
+func (b *builder) countDistinct(field string) (int64, error) {
+	cmd, err := b.countDistinctStmt(field)
+	if err != nil {
+		return 0, err
+	}
+	var count int64
+	if err := b.readClient().execQueryRow(cmd, &count); err != nil {
+		return 0, wrapError(err)
+	}
+	return count, nil
+}
+
+// aggregateColumn will quote the field of the aggregate function, the primary key and soft delete
+// column are always quoted, as `$Key` is not a valid identifier unless it's quoted
+func (b *builder) aggregateColumn(field string) string {
+	field = strings.TrimSpace(field)
+	switch field {
+	case keyFieldName, pkColumn, softDeleteColumn:
+		return b.quoteColumn(b.column(field))
+	}
+	return b.quoteIfNecessary(field)
+}
+
+func (b *builder) aggregateField(fn, field string) string {
+	return fmt.Sprintf("%s(%s)", fn, b.aggregateColumn(field))
+}
+
+// aggregateFloat will return zero when the aggregate result is null, such as empty table
+func (b *builder) aggregateFloat(fn, field string) (float64, error) {
+	var v sql.NullFloat64
+	if err := b.aggregate(b.aggregateField(fn, field), &v); err != nil {
+		return 0, err
+	}
+	return v.Float64, nil
+}
+
+func (b *builder) aggregateValue(fn, field string) (interface{}, error) {
+	var v interface{}
+	if err := b.aggregate(b.aggregateField(fn, field), &v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// parentKey will return the ancestor of the query as the parent key of the new record,
+// it's only applicable when the query has single `Ancestor`
+func (b *builder) parentKey() []*datastore.Key {
+	if len(b.query.ancestors) != 1 || b.query.ancestors[0].isGroup {
+		return nil
+	}
+	return []*datastore.Key{b.query.ancestors[0].data[0].(*datastore.Key)}
+}
+
+// firstOrCreate will get the first record which match the query, or create the record when there is
+// no such record. The lookup is locked for update, same as `updateOrCreate`, and the transaction is
+// rerun when the insert is rejected by the unique index or the concurrent lookups are deadlocked
+func (b *builder) firstOrCreate(model interface{}) error {
+	return b.retryOnConflict(func(tx *DB) error {
+		q := tx.NewQuery()
+		q.scope = b.query
+		q.limit = 1
+		q.lockMode = WriteLock
+		err := newBuilder(q).get(model, true)
+		if err != ErrNoSuchEntity {
+			return err
+		}
+		if err := seedFilters(model, b.query.filters); err != nil {
+			return err
+		}
+		_, err = newBuilder(q).put(model, b.parentKey())
+		return err
+	})
+}
+
+// updateOrCreate will update the first record which match the query with `values`, or create the
+// record with the merge of `attrs` and `values` when there is no such record. Same as `firstOrCreate`,
+// it requires an unique index on the `attrs` columns to stop the same record being created concurrently
+func (b *builder) updateOrCreate(attrs, values map[string]interface{}) (*datastore.Key, error) {
+	table := b.query.table
+	if table == "" {
+		return nil, fmt.Errorf("goloquent: missing table name")
+	}
+	var key *datastore.Key
+	if err := b.retryOnConflict(func(tx *DB) error {
+		q := tx.NewQuery()
+		q.scope = b.query
+		q.limit = 1
+		q.lockMode = WriteLock
+		q.projection = []string{b.pk()}
+		qb := newBuilder(q)
+		cmd, err := qb.buildGet(qb.hasSoftDelete(table))
+		if err != nil {
+			return err
+		}
+		it, err := qb.run(table, cmd)
+		if err != nil {
+			return err
+		}
+		if it.First() == nil {
+			key = newPrimaryKey(table, nil)
+			if parentKey := b.parentKey(); parentKey != nil {
+				key = newPrimaryKey(table, parentKey[0])
+			}
+			data := make(map[string]interface{}, len(attrs)+len(values))
+			for k, v := range attrs {
+				data[k] = v
+			}
+			for k, v := range values {
+				data[k] = v
+			}
+			return newBuilder(q).insertMap(key, data)
+		}
+
+		key, err = parseKey(string(it.Get(keyFieldName)))
+		if err != nil {
+			return err
+		}
+		q = tx.NewQuery()
+		q.table = table
+		q.noTimestamps = b.query.noTimestamps
+		_, err = newBuilder(q.Where(keyFieldName, "=", key)).updateMulti(values)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// insertMap will insert the record of the query table using the map, the map
+// doesn't carry the struct fields, so the timestamps are set by the table columns
+func (b *builder) insertMap(key *datastore.Key, values map[string]interface{}) error {
+	table := b.query.table
+	data := make(map[string]interface{}, len(values))
+	for k, v := range values {
+		col := b.column(k)
+		if col == b.pk() {
+			return fmt.Errorf("goloquent: insert __key__ is not allow")
+		}
+		data[col] = v
+	}
+	if !b.query.noTimestamps {
+		cols := newDictionary(b.tableColumns(table))
+		now := time.Now().UTC()
+		for _, names := range [][]string{createdAtFields, updatedAtFields} {
+			for _, name := range names {
+				if !cols.has(name) {
+					continue
+				}
+				if _, isOk := data[name]; !isOk {
+					data[name] = now
+				}
+				break
+			}
+		}
+	}
+
+	names := make([]string, 0, len(data))
+	for k := range data {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	cols := []string{b.db.dialect.Quote(b.pk())}
+	args := []interface{}{stringPk(key)}
+	for _, k := range names {
+		vi, err := columnValue(data[k])
+		if err != nil {
+			return err
+		}
+		cols = append(cols, b.db.dialect.Quote(k))
+		args = append(args, vi)
+	}
+	buf := new(bytes.Buffer)
+	buf.WriteString(fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s);",
+		b.db.dialect.GetTable(table),
+		strings.Join(cols, ","),
+		strings.TrimRight(strings.Repeat(variable+",", len(cols)), ",")))
+	return b.db.client.execStmt(&stmt{
+		statement: buf,
+		arguments: args,
+	})
+}
+
+// retryOnConflict will rerun the transaction when it's failed by the unique index or the concurrent lookups
+// are deadlocked. The conflicting record is created by others after the lookup, so the rerun will find it,
+// the duplicate is only retried once and the deadlock is retried with the same backoff as `runInTransactionRetry`
+func (b *builder) retryOnConflict(cb TransactionHandler) error {
+	// the outer transaction is aborted by the deadlock, it has to be retried as a whole
+	_, inTx := b.db.client.sqlCommon.(*sql.Tx)
+	isRetried := false
+	backoff := retryBackoff
+	for i := 0; ; i++ {
+		if i > 0 {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+		err := b.runInTransaction(cb)
+		switch {
+		case err == nil:
+			return nil
+		case b.db.dialect.IsDuplicate(err) && !isRetried:
+			isRetried = true
+		case b.db.dialect.IsRetryable(err) && !inTx && i+1 < conflictAttempts:
+		default:
+			return err
+		}
+	}
+}
+
 func (b *builder) runInTransaction(cb TransactionHandler) error {
+	return b.runInTransactionWith(context.Background(), nil, cb)
+}
+
+func (b *builder) runInTransactionWith(ctx context.Context, opts *sql.TxOptions, cb TransactionHandler) error {
+	if opts != nil && !b.db.dialect.SupportIsolationLevel(opts.Isolation) {
+		return fmt.Errorf("goloquent: isolation level %q is not supported by %s", opts.Isolation, b.db.driver)
+	}
+	if tx, isOk := b.db.client.sqlCommon.(*sql.Tx); isOk {
+		// isolation level cannot be changed within a transaction
+		if opts != nil && opts.Isolation != sql.LevelDefault {
+			return fmt.Errorf("goloquent: isolation level cannot be set on nested transaction")
+		}
+		return b.runInSavepoint(tx, cb)
+	}
 	conn, isOk := b.db.client.sqlCommon.(*sql.DB)
 	if !isOk {
 		return fmt.Errorf("goloquent: unable to initiate transaction")
 	}
-	tx, err := conn.Begin()
+	tx, err := conn.BeginTx(ctx, opts)
 	if err != nil {
-		return fmt.Errorf("goloquent: unable to begin transaction, %v", err)
+		return fmt.Errorf("goloquent: unable to begin transaction, %w", err)
 	}
 	db := b.db.clone()
 	db.client.sqlCommon = tx
+	// transaction must always pin to the primary
+	db.readers = nil
+	// the transaction is rolled back before the panic is propagated, so it's never treated as committed
 	defer func() {
 		if r := recover(); r != nil {
-			defer tx.Rollback()
+			tx.Rollback()
+			panic(r)
 		}
 	}()
 	defer tx.Rollback()
@@ -1161,6 +2938,58 @@ func (b *builder) runInTransaction(cb TransactionHandler) error {
 	return tx.Commit()
 }
 
+// runInTransactionRetry will rerun the transaction when the error is retryable, such as deadlock,
+// it will wait for `retryBackoff` before the next attempt and the wait time is doubled every attempt
+func (b *builder) runInTransactionRetry(attempts int, cb TransactionHandler) error {
+	// the outer transaction has to be retried as a whole
+	if _, isOk := b.db.client.sqlCommon.(*sql.Tx); isOk || attempts < 1 {
+		attempts = 1
+	}
+	var err error
+	backoff := retryBackoff
+	for i := 0; i < attempts; i++ {
+		if i > 0 {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+		err = b.runInTransaction(cb)
+		if err == nil || !b.db.dialect.IsRetryable(err) {
+			return err
+		}
+	}
+	return err
+}
+
+// runInSavepoint will nest the transaction within the current transaction using savepoint,
+// the changes of the callback will be rolled back without affecting the outer transaction
+func (b *builder) runInSavepoint(tx *sql.Tx, cb TransactionHandler) error {
+	db := b.db.clone()
+	db.txDepth++
+	name := fmt.Sprintf("goloquent_sp%d", db.txDepth)
+	if _, err := tx.Exec("SAVEPOINT " + name); err != nil {
+		return fmt.Errorf("goloquent: unable to create savepoint, %w", err)
+	}
+	// the savepoint still exists after rolled back, release it so the name can be reused
+	rollback := func() {
+		tx.Exec("ROLLBACK TO SAVEPOINT " + name)
+		tx.Exec("RELEASE SAVEPOINT " + name)
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			rollback()
+			panic(r)
+		}
+	}()
+	if err := cb(db); err != nil {
+		rollback()
+		return err
+	}
+	if _, err := tx.Exec("RELEASE SAVEPOINT " + name); err != nil {
+		return fmt.Errorf("goloquent: unable to release savepoint, %w", err)
+	}
+	return nil
+}
+
 func sha1Sign(s *Stmt) string {
 	h, rgx := sha1.New(), regexp.MustCompile(`(?i)FROM.+?(LIMIT)`)
 	bb := bytes.TrimSpace(bytes.TrimLeft(bytes.TrimRight(rgx.Find([]byte(s.String())), "LIMIT"), "FROM"))
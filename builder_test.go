@@ -0,0 +1,2207 @@
+package goloquent
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/datastore"
+)
+
+func newTestQuery(d Dialect, table string) *Query {
+	q := newQuery(&DB{dialect: d})
+	q.table = table
+	return q
+}
+
+func buildTestStmt(t *testing.T, q *Query) *Stmt {
+	b := newBuilder(q)
+	ss := b.buildSelect(b.query)
+	ss.statement.WriteString(" FROM " + b.db.dialect.GetTable(b.query.table))
+	ss.statement.WriteString(b.buildJoin(b.query).string())
+	cmd, err := b.buildStmt(b.query, ss.arguments...)
+	if err != nil {
+		t.Fatalf("Unexpected error, %v", err)
+	}
+	ss.statement.WriteString(cmd.string())
+	return &Stmt{
+		stmt: stmt{
+			statement: ss.statement,
+			arguments: cmd.arguments,
+		},
+		replacer: b.db.dialect,
+	}
+}
+
+func TestBuilderPlaceholder(t *testing.T) {
+	query := func(d Dialect) *Query {
+		return newTestQuery(d, "User").
+			Where("Name", "=", "Joe").
+			Where("Age", ">", 10).
+			WhereIn("Status", []interface{}{"ACTIVE", "PENDING"}).
+			WhereNull("DeletedAt").
+			Order("-Age").
+			Limit(10)
+	}
+
+	ss := buildTestStmt(t, query(&mysql{sequel: sequel{dbName: "goloquent"}}))
+	if raw := ss.Raw(); raw != "SELECT * FROM `goloquent`.`User` WHERE `Name` = ? AND `Age` > ? AND `Status` IN (?,?) AND `DeletedAt` IS NULL ORDER BY `Age` DESC LIMIT 10" {
+		t.Fatalf("Unexpected mysql statement, %q", raw)
+	}
+	if len(ss.Arguments()) != 4 {
+		t.Fatalf("Unexpected mysql arguments, %v", ss.Arguments())
+	}
+
+	ss = buildTestStmt(t, query(new(postgres)))
+	if raw := ss.Raw(); raw != `SELECT * FROM "User" WHERE "Name" = $1 AND "Age" > $2 AND "Status" IN ($3,$4) AND "DeletedAt" IS NULL ORDER BY "Age" DESC LIMIT 10` {
+		t.Fatalf("Unexpected postgres statement, %q", raw)
+	}
+	if len(ss.Arguments()) != 4 {
+		t.Fatalf("Unexpected postgres arguments, %v", ss.Arguments())
+	}
+}
+
+func TestBuilderGroupByHaving(t *testing.T) {
+	q := newTestQuery(new(postgres), "User").
+		Select("Status", "COUNT(*)").
+		Where("Age", ">", 10).
+		GroupBy("Status").
+		Having("COUNT(*)", ">=", 2).
+		Order("Status").
+		Limit(5)
+	ss := buildTestStmt(t, q)
+	if raw := ss.Raw(); raw != `SELECT "Status",COUNT(*) FROM "User" WHERE "Age" > $1 GROUP BY "Status" HAVING COUNT(*) >= $2 ORDER BY "Status" ASC LIMIT 5` {
+		t.Fatalf("Unexpected statement, %q", raw)
+	}
+	if len(ss.Arguments()) != 2 {
+		t.Fatalf("Unexpected arguments, %v", ss.Arguments())
+	}
+
+	q = newTestQuery(new(postgres), "User").Having("COUNT(*)", "~", 1)
+	if err := q.getError(); err == nil {
+		t.Fatal("Expected error on invalid having operator")
+	}
+}
+
+func TestBuilderCountDistinctOn(t *testing.T) {
+	q := newTestQuery(new(postgres), "User").
+		Unscoped().
+		Where("Age", ">", 10).
+		DistinctOn("Email")
+	cmd, err := newBuilder(q).countStmt()
+	if err != nil {
+		t.Fatalf("Unexpected error, %v", err)
+	}
+	if cmd.string() != `SELECT COUNT(DISTINCT "Email") FROM "User" WHERE "Age" > ??` {
+		t.Fatalf("Unexpected statement, %q", cmd.string())
+	}
+
+	q = q.DistinctOn("Name")
+	cmd, err = newBuilder(q).countStmt()
+	if err != nil {
+		t.Fatalf("Unexpected error, %v", err)
+	}
+	if cmd.string() != `SELECT COUNT(*) FROM (SELECT DISTINCT "Email","Name" FROM "User" WHERE "Age" > ??) AS "t"` {
+		t.Fatalf("Unexpected statement, %q", cmd.string())
+	}
+	if len(cmd.arguments) != 1 {
+		t.Fatalf("Unexpected arguments, %v", cmd.arguments)
+	}
+}
+
+func TestBuilderCountGroupBy(t *testing.T) {
+	q := newTestQuery(new(postgres), "User").
+		Unscoped().
+		Where("Age", ">", 10).
+		GroupBy("Status").
+		Having("COUNT(*)", ">=", 2)
+	cmd, err := newBuilder(q).countStmt()
+	if err != nil {
+		t.Fatalf("Unexpected error, %v", err)
+	}
+	if cmd.string() != `SELECT COUNT(*) FROM (SELECT 1 FROM "User" WHERE "Age" > ?? GROUP BY "Status" HAVING COUNT(*) >= ??) AS "t"` {
+		t.Fatalf("Unexpected statement, %q", cmd.string())
+	}
+	if len(cmd.arguments) != 2 {
+		t.Fatalf("Unexpected arguments, %v", cmd.arguments)
+	}
+
+	cmd, err = newBuilder(q).existsStmt()
+	if err != nil {
+		t.Fatalf("Unexpected error, %v", err)
+	}
+	if cmd.string() != `SELECT EXISTS(SELECT 1 FROM "User" WHERE "Age" > ?? GROUP BY "Status" HAVING COUNT(*) >= ?? LIMIT 1);` {
+		t.Fatalf("Unexpected statement, %q", cmd.string())
+	}
+
+	if _, err := newBuilder(q).buildAggregate(`SUM("Age")`); err == nil {
+		t.Fatal("Expected error on aggregate of grouped query")
+	}
+}
+
+func TestBuilderWhereMatch(t *testing.T) {
+	query := func(d Dialect) *Query {
+		return newTestQuery(d, "Post").
+			Where("Status", "=", "ACTIVE").
+			WhereMatch([]string{"Title", "Body"}, "database orm")
+	}
+
+	ss := buildTestStmt(t, query(&mysql{sequel: sequel{dbName: "goloquent"}}))
+	if raw := ss.Raw(); raw != "SELECT * FROM `goloquent`.`Post` WHERE `Status` = ? AND MATCH(`Title`,`Body`) AGAINST (? IN NATURAL LANGUAGE MODE)" {
+		t.Fatalf("Unexpected mysql statement, %q", raw)
+	}
+	if args := ss.Arguments(); len(args) != 2 || args[1] != "database orm" {
+		t.Fatalf("Unexpected mysql arguments, %v", args)
+	}
+
+	ss = buildTestStmt(t, query(new(postgres)))
+	if raw := ss.Raw(); raw != `SELECT * FROM "Post" WHERE "Status" = $1 AND to_tsvector(concat_ws(' ', "Title", "Body")) @@ plainto_tsquery($2)` {
+		t.Fatalf("Unexpected postgres statement, %q", raw)
+	}
+
+	if err := newTestQuery(new(mysql), "Post").WhereMatch(nil, "orm").getError(); err == nil {
+		t.Fatal("Expected error on empty fields")
+	}
+	if err := newTestQuery(new(mysql), "Post").WhereMatch([]string{"Title", " "}, "orm").getError(); err == nil {
+		t.Fatal("Expected error on empty field")
+	}
+}
+
+func TestBuilderWhereLike(t *testing.T) {
+	for _, tc := range []struct {
+		dialect Dialect
+		raw     string
+	}{
+		{&mysql{sequel: sequel{dbName: "goloquent"}}, "SELECT * FROM `goloquent`.`User` WHERE `Name` LIKE ? ESCAPE \"\\\\\" AND `Code` NOT LIKE ? ESCAPE \"\\\\\" AND `Email` LIKE ? AND `Tag` NOT LIKE ?"},
+		{new(postgres), `SELECT * FROM "User" WHERE "Name" LIKE $1 ESCAPE '\' AND "Code" NOT LIKE $2 ESCAPE '\' AND "Email" LIKE $3 AND "Tag" NOT LIKE $4`},
+		{new(sqlite), `SELECT * FROM "User" WHERE "Name" LIKE ? ESCAPE '\' AND "Code" NOT LIKE ? ESCAPE '\' AND "Email" LIKE ? AND "Tag" NOT LIKE ?`},
+	} {
+		// `WhereLike` and `WhereNotLike` pass the pattern as it is, only the escaped variants escape the value
+		ss := buildTestStmt(t, newTestQuery(tc.dialect, "User").
+			WhereLikeEscaped("Name", "50%").
+			WhereNotLikeEscaped("Code", `a_b\c`).
+			WhereLike("Email", "%@gmail.com").
+			WhereNotLike("Tag", "tmp_%"))
+		if raw := ss.Raw(); raw != tc.raw {
+			t.Fatalf("Unexpected statement, %q", raw)
+		}
+		args := ss.Arguments()
+		if len(args) != 4 || args[0] != `50\%` || args[1] != `a\_b\\c` || args[2] != "%@gmail.com" || args[3] != "tmp_%" {
+			t.Fatalf("Unexpected arguments, %v", args)
+		}
+	}
+}
+
+func TestBuilderWhereContains(t *testing.T) {
+	ss := buildTestStmt(t, newTestQuery(new(postgres), "User").
+		WhereContains("Name", "50%").
+		WhereStartsWith("Code", "a_").
+		WhereEndsWith("Email", `\com`).
+		WhereAnyContains("Tag", []string{"x%", "y"}).
+		WhereAnyLike("Remark", []string{"%z"}))
+	if raw := ss.Raw(); raw != `SELECT * FROM "User" WHERE "Name" LIKE $1 ESCAPE '\' AND "Code" LIKE $2 ESCAPE '\' AND `+
+		`"Email" LIKE $3 ESCAPE '\' AND ("Tag" LIKE $4 ESCAPE '\' OR "Tag" LIKE $5 ESCAPE '\') AND ("Remark" LIKE $6)` {
+		t.Fatalf("Unexpected statement, %q", raw)
+	}
+	expected := []interface{}{`%50\%%`, `a\_%`, `%\\com`, `%x\%%`, "%y%", "%z"}
+	if args := ss.Arguments(); !reflect.DeepEqual(args, expected) {
+		t.Fatalf("Unexpected arguments, %v", args)
+	}
+
+	if err := newTestQuery(new(postgres), "User").WhereAnyContains("Tag", nil).getError(); err == nil {
+		t.Fatal("Expected error on empty values")
+	}
+}
+
+func TestBuilderSelectRaw(t *testing.T) {
+	q := newTestQuery(new(postgres), "User").
+		SelectRaw("COUNT(*) AS total").
+		Select("Status").
+		SelectRaw("DATE_TRUNC(?, CreatedAt) AS day", "day").
+		Where("Age", ">", 10).
+		GroupBy("Status")
+	ss := buildTestStmt(t, q)
+	if raw := ss.Raw(); raw != `SELECT COUNT(*) AS total,"Status",DATE_TRUNC($1, CreatedAt) AS day FROM "User" WHERE "Age" > $2 GROUP BY "Status"` {
+		t.Fatalf("Unexpected statement, %q", raw)
+	}
+	if args := ss.Arguments(); len(args) != 2 || args[0] != "day" {
+		t.Fatalf("Unexpected arguments, %v", args)
+	}
+
+	if err := newTestQuery(new(postgres), "User").SelectRaw(" ").getError(); err == nil {
+		t.Fatal("Expected error on empty raw expression")
+	}
+	if err := newTestQuery(new(postgres), "User").SelectRaw("DATE(?)").getError(); err == nil {
+		t.Fatal("Expected error on missing arguments")
+	}
+}
+
+func TestBuilderBetween(t *testing.T) {
+	parent := datastore.NameKey("Parent", "a", nil)
+	q := newTestQuery(new(postgres), "User").
+		Where("Age", "between", []int{10, 20}).
+		Where("Status", "not between", []string{"A", "C"}).
+		Where("$Key", "between", []*datastore.Key{
+			datastore.NameKey("User", "a", parent),
+			datastore.NameKey("User", "z", parent),
+		})
+	ss := buildTestStmt(t, q)
+	if raw := ss.Raw(); raw != `SELECT * FROM "User" WHERE "Age" BETWEEN $1 AND $2 AND "Status" NOT BETWEEN $3 AND $4 AND "$Key" BETWEEN $5 AND $6` {
+		t.Fatalf("Unexpected statement, %q", raw)
+	}
+	if len(ss.Arguments()) != 6 {
+		t.Fatalf("Unexpected arguments, %v", ss.Arguments())
+	}
+	if _, isOk := ss.Arguments()[4].(string); !isOk {
+		t.Fatalf("Unexpected key argument, %v", ss.Arguments()[4])
+	}
+
+	q = newTestQuery(new(mysql), "User").
+		WhereBetween("Age", 10, 20).
+		WhereNotBetween("CreditLimit", 1.5, 100)
+	b := newBuilder(q)
+	cmd, err := b.buildWhere(b.query)
+	if err != nil {
+		t.Fatalf("Unexpected error, %v", err)
+	}
+	if cmd.string() != " WHERE `Age` BETWEEN ?? AND ?? AND `CreditLimit` NOT BETWEEN ?? AND ??" {
+		t.Fatalf("Unexpected statement, %q", cmd.string())
+	}
+	if len(cmd.arguments) != 4 {
+		t.Fatalf("Unexpected arguments, %v", cmd.arguments)
+	}
+
+	for _, v := range []interface{}{
+		[]int{10},
+		[]int{10, 20, 30},
+		10,
+	} {
+		b := newBuilder(newTestQuery(new(postgres), "User").Where("Age", "between", v))
+		if _, err := b.buildWhere(b.query); err == nil {
+			t.Fatalf("Expected error on between value %v", v)
+		}
+	}
+}
+
+func TestBuilderWhereGroup(t *testing.T) {
+	q := newTestQuery(new(postgres), "User").
+		Where("Status", "=", "ACTIVE").
+		WhereGroup(func(q *Query) {
+			q.Where("Age", "<", 18)
+			q.OrWhere("Age", ">", 60).
+				OrWhereGroup(func(q *Query) {
+					q.WhereIn("Name", []string{"Joe", "Jane"})
+					q.OrWhereNull("Nickname")
+				})
+		}).
+		Where("CreditLimit", ">=", 100)
+	ss := buildTestStmt(t, q)
+	if raw := ss.Raw(); raw != `SELECT * FROM "User" WHERE "Status" = $1 AND ("Age" < $2 OR "Age" > $3 OR ("Name" IN ($4,$5) OR "Nickname" IS NULL)) AND "CreditLimit" >= $6` {
+		t.Fatalf("Unexpected statement, %q", raw)
+	}
+	args := ss.Arguments()
+	if len(args) != 6 || args[0] != "ACTIVE" || args[3] != "Joe" || args[5] != int64(100) {
+		t.Fatalf("Unexpected arguments, %v", args)
+	}
+
+	q = newTestQuery(new(postgres), "User").
+		WhereNotNull("Email").
+		WhereGroup(func(q *Query) {
+			q.Where("Status", "=", "ACTIVE")
+			q.OrWhereGroup(func(q *Query) {
+				q.Where("Age", ">", 18)
+				q.Where("CreditLimit", ">=", 100)
+			})
+		})
+	ss = buildTestStmt(t, q)
+	if raw := ss.Raw(); raw != `SELECT * FROM "User" WHERE "Email" IS NOT NULL AND ("Status" = $1 OR ("Age" > $2 AND "CreditLimit" >= $3))` {
+		t.Fatalf("Unexpected statement, %q", raw)
+	}
+
+	// the filters within the group are joined by `AND` unless they are added using `OrWhere`
+	q = newTestQuery(new(postgres), "User").
+		WhereNotNull("Email").
+		WhereGroup(func(q *Query) {
+			q.Where("Status", "=", "ACTIVE")
+			q.Where("Age", ">", 18)
+		}).
+		OrWhereGroup(func(q *Query) {
+			q.Where("Status", "=", "VIP")
+			q.OrWhere("CreditLimit", ">=", 100)
+		})
+	ss = buildTestStmt(t, q)
+	if raw := ss.Raw(); raw != `SELECT * FROM "User" WHERE (("Email" IS NOT NULL AND ("Status" = $1 AND "Age" > $2)) OR ("Status" = $3 OR "CreditLimit" >= $4))` {
+		t.Fatalf("Unexpected statement, %q", raw)
+	}
+
+	q = newTestQuery(new(postgres), "User").WhereGroup(func(q *Query) {})
+	if len(q.filters) != 0 {
+		t.Fatal("Expected empty group should be ignored")
+	}
+
+	q = newTestQuery(new(postgres), "User").WhereGroup(func(q *Query) {
+		q.Where("Age", "~", 18)
+	})
+	if err := q.getError(); err == nil {
+		t.Fatal("Expected error within group should be returned")
+	}
+}
+
+func TestBuilderOrWhere(t *testing.T) {
+	q := newTestQuery(new(postgres), "User").
+		Where("Status", "=", "ACTIVE").
+		Where("Age", ">", 18).
+		OrWhere("Name", "=", "Joe").
+		Where("CreditLimit", ">=", 100)
+	ss := buildTestStmt(t, q)
+	// `AND` binds tighter than `OR`
+	if raw := ss.Raw(); raw != `SELECT * FROM "User" WHERE (("Status" = $1 AND "Age" > $2) OR ("Name" = $3 AND "CreditLimit" >= $4))` {
+		t.Fatalf("Unexpected statement, %q", raw)
+	}
+	args := ss.Arguments()
+	if len(args) != 4 || args[0] != "ACTIVE" || args[2] != "Joe" {
+		t.Fatalf("Unexpected arguments, %v", args)
+	}
+
+	q = newTestQuery(new(postgres), "User").
+		Where("Age", "<", 18).
+		OrWhere("Age", ">", 60).
+		WhereGroup(func(q *Query) {
+			q.WhereNull("Nickname")
+		}).
+		Ancestor(datastore.NameKey("Parent", "a", nil))
+	b := newBuilder(q)
+	cmd, err := b.buildWhere(b.query)
+	if err != nil {
+		t.Fatalf("Unexpected error, %v", err)
+	}
+	if cmd.string() != ` WHERE ("Age" < ?? OR ("Age" > ?? AND "Nickname" IS NULL)) AND "$Key" LIKE ?? ESCAPE '\'` {
+		t.Fatalf("Unexpected statement, %q", cmd.string())
+	}
+
+	// the filters appended internally, such as the key of `Find`, apply to all the filters
+	b = newBuilder(q.enclose().WhereEqual("Status", "ACTIVE"))
+	cmd, err = b.buildWhere(b.query)
+	if err != nil {
+		t.Fatalf("Unexpected error, %v", err)
+	}
+	if cmd.string() != ` WHERE ("Age" < ?? OR ("Age" > ?? AND "Nickname" IS NULL)) AND "Status" = ?? AND "$Key" LIKE ?? ESCAPE '\'` {
+		t.Fatalf("Unexpected statement, %q", cmd.string())
+	}
+
+	if err := newTestQuery(new(postgres), "User").OrWhere("Age", "~", 18).getError(); err == nil {
+		t.Fatal("Expected error on invalid operator")
+	}
+}
+
+func TestBuilderWhereColumn(t *testing.T) {
+	q := newTestQuery(new(postgres), "User").
+		WhereColumn("UpdatedAt", ">", "CreatedAt").
+		OrWhere("Age", ">=", 18).
+		WhereColumn("__key__", "!=", "ParentKey")
+	ss := buildTestStmt(t, q)
+	if raw := ss.Raw(); raw != `SELECT * FROM "User" WHERE ("UpdatedAt" > "CreatedAt" OR ("Age" >= $1 AND "$Key" <> "ParentKey"))` {
+		t.Fatalf("Unexpected statement, %q", raw)
+	}
+	if args := ss.Arguments(); len(args) != 1 || args[0] != int64(18) {
+		t.Fatalf("Unexpected arguments, %v", args)
+	}
+
+	q = newTestQuery(&mysql{sequel: sequel{dbName: "goloquent"}}, "User").
+		Join("Company", "CompanyID", "ID").
+		WhereColumn("User.Name", "=", "Company.Name")
+	ss = buildTestStmt(t, q)
+	if raw := ss.Raw(); raw != "SELECT * FROM `goloquent`.`User` JOIN `goloquent`.`Company` ON `User`.`CompanyID` = `Company`.`ID` WHERE `User`.`Name` = `Company`.`Name`" {
+		t.Fatalf("Unexpected statement, %q", raw)
+	}
+
+	for _, op := range []string{"in", "like", "between", "~"} {
+		if err := newTestQuery(new(postgres), "User").WhereColumn("A", op, "B").getError(); err == nil {
+			t.Fatalf("Expected error on operator %q", op)
+		}
+	}
+	if err := newTestQuery(new(postgres), "User").WhereColumn("A", "=", "").getError(); err == nil {
+		t.Fatal("Expected error on empty column")
+	}
+}
+
+func TestBuilderUnion(t *testing.T) {
+	for _, tc := range []struct {
+		dialect Dialect
+		raw     string
+	}{
+		{&mysql{sequel: sequel{dbName: "goloquent"}}, "(SELECT `Name`,`Age` FROM `goloquent`.`User` WHERE `Age` > ?) UNION " +
+			"(SELECT `Name`,`Age` FROM `goloquent`.`User` WHERE `Name` = ? LIMIT 5) UNION ALL " +
+			"(SELECT `Name`,`Age` FROM `goloquent`.`Admin`) ORDER BY `Name` ASC LIMIT 10 OFFSET 2;"},
+		{new(postgres), `(SELECT "Name","Age" FROM "User" WHERE "Age" > $1) UNION ` +
+			`(SELECT "Name","Age" FROM "User" WHERE "Name" = $2 LIMIT 5) UNION ALL ` +
+			`(SELECT "Name","Age" FROM "Admin") ORDER BY "Name" ASC LIMIT 10 OFFSET 2;`},
+		{new(sqlite), `SELECT * FROM (SELECT "Name","Age" FROM "User" WHERE "Age" > ?) UNION ` +
+			`SELECT * FROM (SELECT "Name","Age" FROM "User" WHERE "Name" = ? LIMIT 5) UNION ALL ` +
+			`SELECT * FROM (SELECT "Name","Age" FROM "Admin") ORDER BY "Name" ASC LIMIT 10 OFFSET 2;`},
+	} {
+		query := func(table string) *Query {
+			return newTestQuery(tc.dialect, table).Select("Name", "Age")
+		}
+		q := query("User").
+			Where("Age", ">", 60).
+			Union(query("").Where("Name", "=", "Joe").Limit(5)).
+			UnionAll(query("Admin")).
+			Order("Name").
+			Limit(10).
+			Offset(2)
+		if err := q.getError(); err != nil {
+			t.Fatalf("Unexpected error, %v", err)
+		}
+		ss, err := newBuilder(q).getStmt()
+		if err != nil {
+			t.Fatalf("Unexpected error, %v", err)
+		}
+		if raw := ss.Raw(); raw != tc.raw {
+			t.Fatalf("Unexpected statement, %q", raw)
+		}
+		if args := ss.Arguments(); len(args) != 2 || args[0] != int64(60) || args[1] != "Joe" {
+			t.Fatalf("Unexpected arguments, %v", args)
+		}
+	}
+
+	q := newTestQuery(new(postgres), "User").Select("Name")
+	if err := q.Union(newTestQuery(new(postgres), "User").Select("Name", "Age")).getError(); err == nil {
+		t.Fatal("Expected error on different number of columns")
+	}
+	if err := q.Union(newTestQuery(new(postgres), "User").Select("Age")).getError(); err == nil {
+		t.Fatal("Expected error on different columns")
+	}
+	if err := q.Union(nil).getError(); err == nil {
+		t.Fatal("Expected error on nil query")
+	}
+	if _, err := newBuilder(q.Union(newTestQuery(new(postgres), "User").Select("Name")).
+		LockForUpdate()).getStmt(); err == nil {
+		t.Fatal("Expected error on lock with union")
+	}
+}
+
+func TestBuilderWhereNull(t *testing.T) {
+	q := newTestQuery(new(postgres), "User").
+		WhereNull("DeletedBy").
+		OrWhereNotNull("RestoredBy").
+		WhereGroup(func(q *Query) {
+			q.WhereNotNull("Email").OrWhereNull("Phone")
+		})
+	ss := buildTestStmt(t, q)
+	if raw := ss.Raw(); raw != `SELECT * FROM "User" WHERE ("DeletedBy" IS NULL OR ("RestoredBy" IS NOT NULL AND ("Email" IS NOT NULL OR "Phone" IS NULL)))` {
+		t.Fatalf("Unexpected statement, %q", raw)
+	}
+	if args := ss.Arguments(); len(args) != 0 {
+		t.Fatalf("Unexpected arguments, %v", args)
+	}
+}
+
+func TestBuilderWhereJSON(t *testing.T) {
+	query := func(d Dialect) *Query {
+		return newTestQuery(d, "User").
+			WhereJSONContains("Address>region", map[string]interface{}{"code": "MY"}).
+			WhereJSONLength("Nicknames", ">=", 2)
+	}
+
+	ss := buildTestStmt(t, query(&mysql{sequel: sequel{dbName: "goloquent"}}))
+	if raw := ss.Raw(); raw != "SELECT * FROM `goloquent`.`User` WHERE JSON_CONTAINS(`Address`->>\"$.region\", ?) AND JSON_LENGTH(`Nicknames`) >= ?" {
+		t.Fatalf("Unexpected mysql statement, %q", raw)
+	}
+	args := ss.Arguments()
+	if len(args) != 2 || fmt.Sprintf("%s", args[0]) != `{"code":"MY"}` || args[1] != int64(2) {
+		t.Fatalf("Unexpected mysql arguments, %v", args)
+	}
+
+	ss = buildTestStmt(t, query(new(postgres)))
+	if raw := ss.Raw(); raw != `SELECT * FROM "User" WHERE ("Address"->'region')::jsonb @> $1::jsonb AND jsonb_array_length(("Nicknames")::jsonb) >= $2` {
+		t.Fatalf("Unexpected postgres statement, %q", raw)
+	}
+
+	if err := newTestQuery(new(mysql), "User").WhereJSONLength("Nicknames", "like", 1).getError(); err == nil {
+		t.Fatal("Expected error on invalid json length operator")
+	}
+	if err := newTestQuery(new(mysql), "User").WhereJSONContains("Nicknames", func() {}).getError(); err == nil {
+		t.Fatal("Expected error on invalid json value")
+	}
+}
+
+func TestBuilderJoin(t *testing.T) {
+	q := newTestQuery(new(postgres), "User").
+		Select("User.Name", "Address.Line1", "Address.Country").
+		Join("Address", "$Key", "UserKey").
+		LeftJoin("Company", "User.CompanyID", "Company.ID").
+		Where("Address.Country", "=", "MY").
+		WhereEqual(keyFieldName, datastore.NameKey("User", "a", nil)).
+		Order("User.Name")
+	ss := buildTestStmt(t, q)
+	if raw := ss.Raw(); raw != `SELECT "User"."Name","Address"."Line1","Address"."Country" FROM "User" JOIN "Address" ON "User"."$Key" = "Address"."UserKey" LEFT JOIN "Company" ON "User"."CompanyID" = "Company"."ID" WHERE "Address"."Country" = $1 AND "User"."$Key" = $2 ORDER BY "User"."Name" ASC` {
+		t.Fatalf("Unexpected statement, %q", raw)
+	}
+
+	// flatten column should remain as it is when there is no such table
+	q = newTestQuery(new(postgres), "User").
+		Join("Address", "$Key", "UserKey").
+		Where("Region.CountryCode", "=", "MY")
+	ss = buildTestStmt(t, q)
+	if raw := ss.Raw(); raw != `SELECT * FROM "User" JOIN "Address" ON "User"."$Key" = "Address"."UserKey" WHERE "Region.CountryCode" = $1` {
+		t.Fatalf("Unexpected statement, %q", raw)
+	}
+
+	if err := newTestQuery(new(postgres), "User").Join("Address", "", "UserKey").getError(); err == nil {
+		t.Fatal("Expected error on invalid join")
+	}
+}
+
+func TestBuilderCursorFilter(t *testing.T) {
+	b := newBuilder(newTestQuery(new(postgres), "User"))
+	filter := b.buildCursorFilter([]order{
+		{field: "Age", direction: descending},
+		{field: "Name", direction: ascending},
+		{field: pkColumn, direction: ascending},
+	}, []interface{}{18, "Joe", "User,10"})
+	if raw := filter.string(); raw != `(("Age" < ??) OR ("Age" = ?? AND "Name" > ??) OR ("Age" = ?? AND "Name" = ?? AND "$Key" > ??))` {
+		t.Fatalf("Unexpected statement, %q", raw)
+	}
+	if !reflect.DeepEqual(filter.arguments, []interface{}{18, 18, "Joe", 18, "Joe", "User,10"}) {
+		t.Fatalf("Unexpected arguments, %v", filter.arguments)
+	}
+}
+
+func TestBuilderReadClient(t *testing.T) {
+	db := &DB{dialect: new(postgres)}
+	if b := newBuilder(db.NewQuery()); b.readClient() != &b.db.client {
+		t.Fatal("Expected primary client when there is no replica")
+	}
+
+	r1, r2 := new(sql.DB), new(sql.DB)
+	db.SetReplica(r1, nil, r2)
+	if len(db.readers) != 2 {
+		t.Fatalf("Expected 2 replicas, but get %d", len(db.readers))
+	}
+	picked := make([]sqlCommon, 0)
+	for i := 0; i < 4; i++ {
+		b := newBuilder(db.NewQuery())
+		picked = append(picked, b.readClient().sqlCommon)
+	}
+	if picked[0] == picked[1] || picked[0] != picked[2] || picked[1] != picked[3] {
+		t.Fatal("Expected replicas should be picked in round-robin")
+	}
+	for _, c := range picked {
+		if c != r1 && c != r2 {
+			t.Fatal("Expected replica client for read query")
+		}
+	}
+	if b := newBuilder(db.NewQuery().UsePrimary()); b.readClient() != &b.db.client {
+		t.Fatal("Expected primary client when `UsePrimary`")
+	}
+	if b := newBuilder(db.NewQuery().WLock()); b.readClient() != &b.db.client {
+		t.Fatal("Expected primary client when the query is locked")
+	}
+
+	db.SetReplica()
+	if db.readers != nil {
+		t.Fatal("Expected replicas to be removed")
+	}
+}
+
+func TestBuilderAncestorDepth(t *testing.T) {
+	parent := datastore.NameKey("Parent", "50%_off", nil)
+	q := newTestQuery(new(postgres), "User").Ancestor(parent)
+	cmd, err := newBuilder(q).buildWhere(q.scope)
+	if err != nil {
+		t.Fatalf("Unexpected error, %v", err)
+	}
+	if raw := cmd.string(); raw != ` WHERE "$Key" LIKE ?? ESCAPE '\'` {
+		t.Fatalf("Unexpected statement, %q", raw)
+	}
+	if len(cmd.arguments) != 1 || cmd.arguments[0] != `Parent,'50\%25\_off'/%` {
+		t.Fatalf("Unexpected arguments, %v", cmd.arguments)
+	}
+
+	q = newTestQuery(new(postgres), "User").
+		AnyOfAncestor(parent, datastore.IDKey("Parent", 1, nil)).
+		AncestorDepth(1)
+	cmd, err = newBuilder(q).buildWhere(q.scope)
+	if err != nil {
+		t.Fatalf("Unexpected error, %v", err)
+	}
+	if raw := cmd.string(); raw != ` WHERE (("$Key" LIKE ?? ESCAPE '\' AND "$Key" NOT LIKE ?? ESCAPE '\') OR ("$Key" LIKE ?? ESCAPE '\' AND "$Key" NOT LIKE ?? ESCAPE '\'))` {
+		t.Fatalf("Unexpected statement, %q", raw)
+	}
+	expected := []interface{}{`Parent,'50\%25\_off'/%`, `Parent,'50\%25\_off'/%/%`, "Parent,1/%", "Parent,1/%/%"}
+	if !reflect.DeepEqual(cmd.arguments, expected) {
+		t.Fatalf("Unexpected arguments, %v", cmd.arguments)
+	}
+
+	q = newTestQuery(new(postgres), "User")
+	if err := q.AncestorDepth(-1).getError(); err == nil {
+		t.Fatal("Expected error on negative ancestor depth")
+	}
+	if err := q.getError(); err != nil {
+		t.Fatalf("Unexpected error on the original query, %v", err)
+	}
+}
+
+func TestBuilderCountDistinct(t *testing.T) {
+	q := newTestQuery(new(postgres), "User").
+		Unscoped().
+		Where("Age", ">", 10)
+	cmd, err := newBuilder(q).countDistinctStmt("Email")
+	if err != nil {
+		t.Fatalf("Unexpected error, %v", err)
+	}
+	if cmd.string() != `SELECT COUNT(DISTINCT "Email") FROM "User" WHERE "Age" > ??;` {
+		t.Fatalf("Unexpected statement, %q", cmd.string())
+	}
+	if len(cmd.arguments) != 1 {
+		t.Fatalf("Unexpected arguments, %v", cmd.arguments)
+	}
+
+	cmd, err = newBuilder(q).countDistinctStmt(keyFieldName)
+	if err != nil {
+		t.Fatalf("Unexpected error, %v", err)
+	}
+	if cmd.string() != `SELECT COUNT(DISTINCT "$Key") FROM "User" WHERE "Age" > ??;` {
+		t.Fatalf("Unexpected statement, %q", cmd.string())
+	}
+	if _, err := newBuilder(q).countDistinctStmt(" "); err == nil {
+		t.Fatal("Expected error on empty field")
+	}
+}
+
+func TestBuilderColumnCache(t *testing.T) {
+	conn, err := sql.Open("goloquent_test", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	defer func() { testDriver.results = nil }()
+
+	d := new(sqlite)
+	db := &DB{client: Client{sqlCommon: conn, dialect: d}, dialect: d, columns: newColumnCache()}
+	d.SetDB(db.client)
+	columns := func(cols ...driver.Value) *fakeRows {
+		rows := &fakeRows{cols: []string{"name"}}
+		for _, c := range cols {
+			rows.vals = append(rows.vals, []driver.Value{c})
+		}
+		return rows
+	}
+	testDriver.results = []*fakeRows{columns(), columns(pkColumn, "Name", "UpdatedAt", softDeleteColumn)}
+	b := newBuilder(db.NewQuery())
+	if b.hasSoftDelete("User") {
+		t.Fatal("Expected table not exists has no soft delete column")
+	}
+	// the table is looked up again when it's not exists
+	for i := 0; i < 2; i++ {
+		if !b.hasSoftDelete("User") {
+			t.Fatal("Expected table has soft delete column")
+		}
+	}
+	// the `UpdatedAt` column of the map update is found from the cache
+	cmd, err := newBuilder(db.Table("User").Where("Name", "=", "Joe")).updateStmt(map[string]interface{}{"Name": "Jane"})
+	if err != nil {
+		t.Fatalf("Unexpected error, %v", err)
+	}
+	if raw := cmd.string(); raw != `UPDATE "User" SET "Name" = ??, "UpdatedAt" = ?? WHERE "Name" = ??;` {
+		t.Fatalf("Unexpected statement, %q", raw)
+	}
+	if len(testDriver.results) != 0 {
+		t.Fatalf("Unexpected pending results, %v", testDriver.results)
+	}
+
+	testDriver.results = []*fakeRows{columns(pkColumn, "Name")}
+	db.columns.reset("User")
+	if newBuilder(db.NewQuery()).hasSoftDelete("User") {
+		t.Fatal("Expected table should be looked up again after it's reset")
+	}
+}
+
+func TestBuilderExists(t *testing.T) {
+	q := newTestQuery(new(postgres), "User").
+		Unscoped().
+		Where("Age", ">", 10).
+		Ancestor(datastore.NameKey("Parent", "a", nil))
+	cmd, err := newBuilder(q).existsStmt()
+	if err != nil {
+		t.Fatalf("Unexpected error, %v", err)
+	}
+	if cmd.string() != `SELECT EXISTS(SELECT 1 FROM "User" WHERE "Age" > ?? AND "$Key" LIKE ?? ESCAPE '\' LIMIT 1);` {
+		t.Fatalf("Unexpected statement, %q", cmd.string())
+	}
+	if len(cmd.arguments) != 2 {
+		t.Fatalf("Unexpected arguments, %v", cmd.arguments)
+	}
+}
+
+func TestBuilderIncrement(t *testing.T) {
+	columns := map[string]string{"Age": "tinyint", "CreditLimit": "double", "Name": "varchar"}
+	q := newTestQuery(&mysql{sequel: sequel{dbName: "goloquent"}}, "User").
+		Where("Status", "=", "ACTIVE").
+		Limit(10)
+	cmd, err := newBuilder(q).incrementStmt("+", map[string]interface{}{
+		"CreditLimit": 10.5,
+		"Age":         1,
+	}, columns)
+	if err != nil {
+		t.Fatalf("Unexpected error, %v", err)
+	}
+	if cmd.string() != "UPDATE `goloquent`.`User` SET `Age` = `Age` + ??, `CreditLimit` = `CreditLimit` + ?? WHERE `Status` = ?? LIMIT 10;" {
+		t.Fatalf("Unexpected statement, %q", cmd.string())
+	}
+	if len(cmd.arguments) != 3 || cmd.arguments[0] != 1 || cmd.arguments[2] != "ACTIVE" {
+		t.Fatalf("Unexpected arguments, %v", cmd.arguments)
+	}
+
+	columns["UpdatedAt"] = "datetime"
+	cmd, err = newBuilder(newTestQuery(new(postgres), "User")).incrementStmt("-", map[string]interface{}{"Age": uint8(2)}, columns)
+	if err != nil {
+		t.Fatalf("Unexpected error, %v", err)
+	}
+	if cmd.string() != `UPDATE "User" SET "Age" = "Age" - ??, "UpdatedAt" = ??;` {
+		t.Fatalf("Unexpected statement, %q", cmd.string())
+	}
+
+	for _, fields := range []map[string]interface{}{
+		{},
+		{"Age": "1"},
+		{"Name": 1},
+		{"Unknown": 1},
+	} {
+		if _, err := newBuilder(newTestQuery(new(postgres), "User")).incrementStmt("+", fields, columns); err == nil {
+			t.Fatalf("Expected error on increment %v", fields)
+		}
+	}
+}
+
+func TestBuilderSavepoint(t *testing.T) {
+	conn, err := sql.Open("goloquent_test", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	db := &DB{client: Client{sqlCommon: conn, dialect: new(postgres)}, dialect: new(postgres)}
+	testDriver.executed = nil
+	if err := db.RunInTransaction(func(tx *DB) error {
+		if err := tx.RunInTransaction(func(tx *DB) error {
+			return tx.RunInTransaction(func(tx *DB) error {
+				return errors.New("rollback")
+			})
+		}); err == nil {
+			t.Fatal("Expected error should be returned from the nested transaction")
+		}
+		return tx.RunInTransaction(func(tx *DB) error {
+			return nil
+		})
+	}); err != nil {
+		t.Fatalf("Unexpected error, %v", err)
+	}
+
+	expected := []string{
+		"SAVEPOINT goloquent_sp1",
+		"SAVEPOINT goloquent_sp2",
+		"ROLLBACK TO SAVEPOINT goloquent_sp2",
+		"RELEASE SAVEPOINT goloquent_sp2",
+		"ROLLBACK TO SAVEPOINT goloquent_sp1",
+		"RELEASE SAVEPOINT goloquent_sp1",
+		"SAVEPOINT goloquent_sp1",
+		"RELEASE SAVEPOINT goloquent_sp1",
+	}
+	if !reflect.DeepEqual(testDriver.executed, expected) {
+		t.Fatalf("Unexpected statements, %v", testDriver.executed)
+	}
+
+	testDriver.executed = nil
+	db.RunInTransaction(func(tx *DB) error {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Fatal("Expected panic should be propagated to the outer transaction")
+			}
+		}()
+		return tx.RunInTransaction(func(tx *DB) error {
+			panic("nested")
+		})
+	})
+	expected = []string{
+		"SAVEPOINT goloquent_sp1",
+		"ROLLBACK TO SAVEPOINT goloquent_sp1",
+		"RELEASE SAVEPOINT goloquent_sp1",
+	}
+	if !reflect.DeepEqual(testDriver.executed, expected) {
+		t.Fatalf("Unexpected statements, %v", testDriver.executed)
+	}
+
+	func() {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Fatal("Expected panic should be propagated from the transaction")
+			}
+		}()
+		db.RunInTransaction(func(tx *DB) error {
+			panic("outer")
+		})
+		t.Fatal("Expected transaction should not be committed after panic")
+	}()
+}
+
+func TestBuilderTransactionIsolation(t *testing.T) {
+	conn, err := sql.Open("goloquent_test", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	db := &DB{driver: "postgres", client: Client{sqlCommon: conn, dialect: new(postgres)}, dialect: new(postgres)}
+	noop := func(tx *DB) error {
+		return nil
+	}
+	if err := db.RunInTransactionWith(&sql.TxOptions{Isolation: sql.LevelSnapshot}, noop); err == nil {
+		t.Fatal("Expected error on unsupported isolation level")
+	}
+	if err := db.RunInTransactionWith(&sql.TxOptions{}, func(tx *DB) error {
+		return tx.RunInTransactionWith(&sql.TxOptions{Isolation: sql.LevelSerializable}, noop)
+	}); err == nil {
+		t.Fatal("Expected error on setting isolation level for nested transaction")
+	}
+	if err := db.RunInTransactionWith(nil, noop); err != nil {
+		t.Fatalf("Unexpected error, %v", err)
+	}
+	if err := db.RunInTransactionWithOptions(&sql.TxOptions{Isolation: sql.LevelSnapshot}, noop); err == nil {
+		t.Fatal("Expected error on unsupported isolation level")
+	}
+}
+
+func TestBuilderTransactionRetry(t *testing.T) {
+	conn, err := sql.Open("goloquent_test", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	backoff := retryBackoff
+	retryBackoff = time.Millisecond
+	defer func() { retryBackoff = backoff }()
+
+	db := &DB{client: Client{sqlCommon: conn, dialect: new(mysql)}, dialect: new(mysql)}
+	deadlock := fmt.Errorf("goloquent: %w", &MySQLError{1213, "Deadlock found when trying to get lock"})
+	run := func(attempts int, errs ...error) int {
+		i := 0
+		db.RunInTransactionRetry(attempts, func(tx *DB) error {
+			defer func() { i++ }()
+			if i < len(errs) {
+				return errs[i]
+			}
+			return nil
+		})
+		return i
+	}
+	if n := run(3, deadlock, deadlock); n != 3 {
+		t.Fatalf("Expected transaction to be retried until success, but run %d times", n)
+	}
+	if n := run(2, deadlock, deadlock, deadlock); n != 2 {
+		t.Fatalf("Expected transaction to stop after all attempts, but run %d times", n)
+	}
+	if n := run(3, errors.New("goloquent: duplicate entry")); n != 1 {
+		t.Fatalf("Expected non-retryable error not to be retried, but run %d times", n)
+	}
+}
+
+type MySQLError struct {
+	Number  uint16
+	Message string
+}
+
+func (e *MySQLError) Error() string {
+	return fmt.Sprintf("Error %d: %s", e.Number, e.Message)
+}
+
+type pqError map[byte]string
+
+func (e pqError) Error() string {
+	return "pq: " + e['M']
+}
+
+func (e pqError) Get(k byte) string {
+	return e[k]
+}
+
+func TestDialectIsRetryable(t *testing.T) {
+	for _, tc := range []struct {
+		d       Dialect
+		err     error
+		isRetry bool
+	}{
+		{new(mysql), &MySQLError{1213, "Deadlock found when trying to get lock"}, true},
+		{new(mysql), fmt.Errorf("goloquent: %w", &MySQLError{1205, "Lock wait timeout exceeded"}), true},
+		{new(mysql), &MySQLError{1062, "Duplicate entry"}, false},
+		{new(mysql), errors.New("Error 1213: Deadlock found when trying to get lock"), false},
+		{new(postgres), pqError{'C': "40001", 'M': "could not serialize access due to concurrent update"}, true},
+		{new(postgres), fmt.Errorf("goloquent: %w", pqError{'C': "40P01", 'M': "deadlock detected"}), true},
+		{new(postgres), pqError{'C': "23505", 'M': "duplicate key value violates unique constraint"}, false},
+		{new(postgres), nil, false},
+		{new(sequel), &MySQLError{1213, "Deadlock found when trying to get lock"}, false},
+	} {
+		if tc.d.IsRetryable(tc.err) != tc.isRetry {
+			t.Fatalf("Unexpected retryable result on %v", tc.err)
+		}
+	}
+}
+
+func TestBuilderAddUniqueIndex(t *testing.T) {
+	type user struct {
+		_     struct{}       `goloquent:"uniqueTogether:Email,Phone"`
+		Key   *datastore.Key `goloquent:"__key__"`
+		Email string
+	}
+
+	e, err := newEntity(new(user))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(e.uniques) != 1 {
+		t.Fatalf("Unexpected unique indexes, %v", e.uniques)
+	}
+	b := newBuilder(newTestQuery(new(mysql), ""))
+	if err := b.addUniqueIndex(e, e.uniques[0]); err == nil {
+		t.Fatal("Expected error on unknown field")
+	}
+	if err := b.addUniqueIndex(e, nil); err == nil {
+		t.Fatal("Expected error on empty fields")
+	}
+}
+
+func TestBuilderStaleUniqueIndexes(t *testing.T) {
+	conn, err := sql.Open("goloquent_test", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	defer func() { testDriver.results = nil }()
+
+	type user struct {
+		_      struct{}       `goloquent:"uniqueTogether:Email,Phone"`
+		Key    *datastore.Key `goloquent:"__key__"`
+		Email  string         `goloquent:",unique"`
+		Phone  string
+		Code   string `goloquent:",unique=code_tenant"`
+		Tenant string `goloquent:",unique=code_tenant"`
+	}
+
+	d := &mysql{sequel: sequel{dbName: "goloquent"}}
+	db := &DB{client: Client{sqlCommon: conn, dialect: d}, dialect: d}
+	d.SetDB(db.client)
+	e, err := newEntity(new(user))
+	if err != nil {
+		t.Fatal(err)
+	}
+	testDriver.results = []*fakeRows{{
+		cols: []string{"INDEX_NAME"},
+		vals: [][]driver.Value{{"user_Email_unique"}, {"user_Phone_unique"}, {"user_Email_Phone_unique"},
+			{"user_Code_Tenant_unique"}, {"user_Code_Group_unique"}, {"user_Phone_idx"}},
+	}}
+	if idxs := newBuilder(db.NewQuery()).staleUniqueIndexes(e); !reflect.DeepEqual(idxs, []string{"user_Code_Group_unique", "user_Phone_unique"}) {
+		t.Fatalf("Unexpected stale unique indexes, %v", idxs)
+	}
+	for _, tc := range []struct {
+		dialect Dialect
+		raw     string
+	}{
+		{d, "DROP INDEX `user_Phone_unique` ON `goloquent`.`user`;"},
+		{new(postgres), `DROP INDEX IF EXISTS "user_Phone_unique";`},
+		{new(sqlite), `DROP INDEX IF EXISTS "user_Phone_unique";`},
+	} {
+		if raw := tc.dialect.DropIndex("user", "user_Phone_unique"); raw != tc.raw {
+			t.Fatalf("Unexpected statement, %q", raw)
+		}
+	}
+}
+
+func TestBuilderFirstOrCreateConflict(t *testing.T) {
+	type user struct {
+		Key      *datastore.Key `goloquent:"__key__"`
+		Username string
+	}
+
+	conn, err := sql.Open("goloquent_test", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	db := &DB{client: Client{sqlCommon: conn, dialect: new(mysql)}, dialect: new(mysql)}
+	cols := []string{pkColumn, "Username"}
+	// the record is created by others after the lookup, so the insert is rejected by the unique index
+	testDriver.results = []*fakeRows{
+		{cols: cols},
+		{cols: cols, vals: [][]driver.Value{{[]byte("10"), []byte("joe")}}},
+	}
+	testDriver.execErr = &MySQLError{1062, "Duplicate entry 'joe' for key 'Username'"}
+	testDriver.executed = nil
+	defer func() {
+		testDriver.results = nil
+		testDriver.execErr = nil
+	}()
+
+	u := new(user)
+	if err := db.NewQuery().FirstOrCreate(u, map[string]interface{}{"Username": "joe"}); err != nil {
+		t.Fatalf("Unexpected error, %v", err)
+	}
+	if u.Key == nil || u.Key.ID != 10 || u.Username != "joe" {
+		t.Fatalf("Expected the conflicting record should be retrieved, but get %v", u)
+	}
+	if len(testDriver.executed) != 1 {
+		t.Fatalf("Expected record should only be inserted once, %v", testDriver.executed)
+	}
+	if testDriver.prepared["SELECT * FROM ``.`user` WHERE `Username` = ? LIMIT 1 FOR UPDATE;"] == 0 {
+		t.Fatalf("Expected the lookup should be locked for update, %v", testDriver.prepared)
+	}
+
+	testDriver.results = []*fakeRows{{cols: cols}, {cols: cols}}
+	testDriver.executed = nil
+	err = db.NewQuery().FirstOrCreate(new(user), map[string]interface{}{"Username": "joe"})
+	if !db.dialect.IsDuplicate(err) {
+		t.Fatalf("Expected duplicate error when the conflicting record is not matched, but get %v", err)
+	}
+	if len(testDriver.executed) != 2 {
+		t.Fatalf("Expected insert should only be retried once, %v", testDriver.executed)
+	}
+}
+
+func TestBuilderInsertMap(t *testing.T) {
+	conn, err := sql.Open("goloquent_test", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	db := &DB{client: Client{sqlCommon: conn, dialect: new(postgres)}, dialect: new(postgres)}
+	q := db.NewQuery().WithoutTimestamps()
+	q.table = "Post"
+	testDriver.executed = nil
+	key := datastore.IDKey("Post", 10, nil)
+	if err := newBuilder(q).insertMap(key, map[string]interface{}{
+		"Title":  "Hello",
+		"Status": "ACTIVE",
+	}); err != nil {
+		t.Fatalf("Unexpected error, %v", err)
+	}
+	if len(testDriver.executed) != 1 || testDriver.executed[0] != `INSERT INTO "Post" ("$Key","Status","Title") VALUES ($1,$2,$3);` {
+		t.Fatalf("Unexpected statement, %v", testDriver.executed)
+	}
+	if err := newBuilder(q).insertMap(key, map[string]interface{}{keyFieldName: key}); err == nil {
+		t.Fatal("Expected error on inserting __key__")
+	}
+
+	db.client.pk, db.client.deleted = "id", "deleted_at"
+	q = db.NewQuery().WithoutTimestamps()
+	q.table = "Post"
+	testDriver.executed = nil
+	if err := newBuilder(q).insertMap(key, map[string]interface{}{
+		"Title":          "Hello",
+		softDeleteColumn: nil,
+	}); err != nil {
+		t.Fatalf("Unexpected error, %v", err)
+	}
+	if len(testDriver.executed) != 1 || testDriver.executed[0] != `INSERT INTO "Post" ("id","Title","deleted_at") VALUES ($1,$2,$3);` {
+		t.Fatalf("Unexpected statement, %v", testDriver.executed)
+	}
+	if err := newBuilder(q).insertMap(key, map[string]interface{}{pkColumn: key}); err == nil {
+		t.Fatal("Expected error on inserting the primary key")
+	}
+}
+
+func TestBuilderUpdateOrCreateDeadlock(t *testing.T) {
+	conn, err := sql.Open("goloquent_test", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	backoff := retryBackoff
+	retryBackoff = time.Millisecond
+	defer func() { retryBackoff = backoff }()
+
+	d := &mysql{sequel: sequel{dbName: "goloquent"}}
+	db := &DB{client: Client{sqlCommon: conn, dialect: d}, dialect: d}
+	d.SetDB(db.client)
+	columns := &fakeRows{cols: []string{"COLUMN_NAME"}}
+	// the concurrent lookups are both locked on the gap, so the insert is deadlocked
+	testDriver.results = nil
+	for i := 0; i < conflictAttempts; i++ {
+		testDriver.results = append(testDriver.results, columns, &fakeRows{cols: []string{pkColumn}})
+	}
+	testDriver.execErr = &MySQLError{1213, "Deadlock found when trying to get lock"}
+	testDriver.executed = nil
+	defer func() {
+		testDriver.results = nil
+		testDriver.execErr = nil
+	}()
+
+	_, err = db.Table("user").WithoutTimestamps().UpdateOrCreate(
+		map[string]interface{}{"Username": "joe"},
+		map[string]interface{}{"Name": "Joe"})
+	if !db.dialect.IsRetryable(err) {
+		t.Fatalf("Expected deadlock error after all attempts, but get %v", err)
+	}
+	if len(testDriver.executed) != conflictAttempts {
+		t.Fatalf("Expected deadlocked insert should be retried, %v", testDriver.executed)
+	}
+
+}
+
+func TestQueryReplaceInto(t *testing.T) {
+	conn, err := sql.Open("goloquent_test", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	defer func() { testDriver.results = nil }()
+
+	// the source table has soft delete column, so the soft deleted records are excluded
+	columns := func() *fakeRows {
+		return &fakeRows{
+			cols: []string{"COLUMN_NAME"},
+			vals: [][]driver.Value{{pkColumn}, {"Name"}, {"Age"}, {softDeleteColumn}},
+		}
+	}
+	for _, tc := range []struct {
+		dialect Dialect
+		raw     string
+	}{
+		{&mysql{sequel: sequel{dbName: "goloquent"}}, "REPLACE INTO `goloquent`.`TempUser` (`$Key`,`Name`) SELECT `$Key`,`Name` FROM `goloquent`.`User` WHERE `Age` > ? AND `$Deleted` IS NULL LIMIT 10;"},
+		{new(sqlite), `INSERT OR REPLACE INTO "TempUser" ("$Key","Name") SELECT "$Key","Name" FROM "User" WHERE "Age" > ? AND "$Deleted" IS NULL LIMIT 10;`},
+		{new(postgres), `INSERT INTO "TempUser" ("$Key","Name") SELECT "$Key","Name" FROM "User" WHERE "Age" > $1 AND "$Deleted" IS NULL LIMIT 10 ON CONFLICT ("$Key") DO UPDATE SET "Name" = EXCLUDED."Name";`},
+	} {
+		db := &DB{client: Client{sqlCommon: conn, dialect: tc.dialect}, dialect: tc.dialect}
+		tc.dialect.SetDB(db.client)
+		testDriver.results = []*fakeRows{columns()}
+		testDriver.executed = nil
+		if err := db.Table("User").Select(keyFieldName, "Name").
+			Where("Age", ">", 18).Limit(10).ReplaceInto("TempUser"); err != nil {
+			t.Fatalf("Unexpected error, %v", err)
+		}
+		if len(testDriver.executed) != 1 || testDriver.executed[0] != tc.raw {
+			t.Fatalf("Unexpected statement, %v", testDriver.executed)
+		}
+	}
+
+	d := &mysql{sequel: sequel{dbName: "goloquent"}}
+	db := &DB{client: Client{sqlCommon: conn, dialect: d}, dialect: d}
+	d.SetDB(db.client)
+	testDriver.executed = nil
+	if err := db.Table("User").Select("Name").WithTrashed().ReplaceInto("TempUser"); err != nil {
+		t.Fatalf("Unexpected error, %v", err)
+	}
+	if len(testDriver.executed) != 1 || testDriver.executed[0] != "REPLACE INTO `goloquent`.`TempUser` (`Name`) SELECT `Name` FROM `goloquent`.`User`;" {
+		t.Fatalf("Expected soft deleted records should be copied with trashed, %v", testDriver.executed)
+	}
+
+	testDriver.results = []*fakeRows{columns()}
+	testDriver.executed = nil
+	if err := db.Table("User").Select("User.Name").
+		Join("Post", "$Key", "UserKey").
+		GroupBy("User.Name").
+		Having("COUNT(*)", ">", 1).
+		ReplaceInto("TempUser"); err != nil {
+		t.Fatalf("Unexpected error, %v", err)
+	}
+	if len(testDriver.executed) != 1 || testDriver.executed[0] != "REPLACE INTO `goloquent`.`TempUser` (`Name`) SELECT `User`.`Name` FROM `goloquent`.`User` JOIN `goloquent`.`Post` ON `User`.`$Key` = `Post`.`UserKey` WHERE `User`.`$Deleted` IS NULL GROUP BY `User`.`Name` HAVING COUNT(*) > ?;" {
+		t.Fatalf("Unexpected statement with join and group by, %v", testDriver.executed)
+	}
+
+	if err := db.Table("User").SelectRaw("COUNT(*)").ReplaceInto("TempUser"); err == nil {
+		t.Fatal("Expected error on replacing with raw select")
+	}
+	if err := db.Table("User").Select("Name").ReplaceInto(" "); err == nil {
+		t.Fatal("Expected error on replacing into empty table name")
+	}
+}
+
+func TestBuilderSaveMulti(t *testing.T) {
+	conn, err := sql.Open("goloquent_test", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	type user struct {
+		Key    *datastore.Key `goloquent:"__key__"`
+		Name   string
+		Status string
+		Age    int
+	}
+
+	db := &DB{client: Client{sqlCommon: conn, dialect: new(postgres)}, dialect: new(postgres)}
+	q := db.NewQuery().WithoutTimestamps()
+	users := []user{
+		{Key: datastore.IDKey("user", 1, nil), Name: "Joe"},
+		{Key: datastore.IDKey("user", 2, nil), Name: "Ann"},
+	}
+	testDriver.executed = nil
+	if err := newBuilder(q).save(&users); err != nil {
+		t.Fatalf("Unexpected error, %v", err)
+	}
+	// the columns are sorted, so every entity is saved using the same statement
+	raw := `UPDATE "user" SET "Age" = $1,"Name" = $2,"Status" = $3 WHERE "$Key" = $4;`
+	if !reflect.DeepEqual(testDriver.executed, []string{raw, raw}) {
+		t.Fatalf("Unexpected statements, %v", testDriver.executed)
+	}
+
+	ptrs := []*user{&users[0], nil, {Name: "Ken"}}
+	testDriver.executed = nil
+	err = newBuilder(q).save(&ptrs)
+	errs, isOk := err.(SaveError)
+	if !isOk {
+		t.Fatalf("Expected SaveError, but get %v", err)
+	}
+	if len(errs) != 2 || errs[1] == nil || errs[2] == nil {
+		t.Fatalf("Unexpected failed elements, %v", errs)
+	}
+	if len(testDriver.executed) != 0 {
+		t.Fatalf("Expected nothing to be saved, but get %v", testDriver.executed)
+	}
+}
+
+func TestBuilderDeleteEmpty(t *testing.T) {
+	conn, err := sql.Open("goloquent_test", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	type user struct {
+		Key     *datastore.Key `goloquent:"__key__"`
+		Name    string
+		Deleted SoftDelete
+	}
+
+	db := &DB{client: Client{sqlCommon: conn, dialect: new(mysql)}, dialect: new(mysql)}
+	b := newBuilder(db.NewQuery())
+	e, err := newEntity(&[]user{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, isSoftDelete := range []bool{true, false} {
+		if cmd, err := b.deleteStmt(e, isSoftDelete); err != nil || cmd != nil {
+			t.Fatalf("Expected no statement on empty entities, but get %v, %v", cmd, err)
+		}
+	}
+	if cmd, err := b.restoreStmt(e); err != nil || cmd != nil {
+		t.Fatalf("Expected no statement on empty entities, but get %v, %v", cmd, err)
+	}
+
+	testDriver.executed = nil
+	if n, err := b.delete(&[]user{}, true); err != nil || n != 0 {
+		t.Fatalf("Unexpected result, %d, %v", n, err)
+	}
+	if n, err := b.delete(&[]*user{}, false); err != nil || n != 0 {
+		t.Fatalf("Unexpected result, %d, %v", n, err)
+	}
+	if err := b.restore(&[]user{}); err != nil {
+		t.Fatalf("Unexpected error, %v", err)
+	}
+	if len(testDriver.executed) != 0 {
+		t.Fatalf("Expected nothing to be executed, but get %v", testDriver.executed)
+	}
+}
+
+func TestDBDeleteCount(t *testing.T) {
+	conn, err := sql.Open("goloquent_test", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	type user struct {
+		Key     *datastore.Key `goloquent:"__key__"`
+		Name    string
+		Deleted SoftDelete
+	}
+
+	db := &DB{client: Client{sqlCommon: conn, dialect: new(mysql)}, dialect: new(mysql)}
+	u := &user{Key: datastore.NameKey("user", "a", nil)}
+	if n, err := db.DeleteCount(u); err != nil || n != 1 {
+		t.Fatalf("Unexpected result, %d, %v", n, err)
+	}
+	if n, err := db.DestroyCount(&[]*user{}); err != nil || n != 0 {
+		t.Fatalf("Unexpected result, %d, %v", n, err)
+	}
+}
+
+type tablerUser struct {
+	Key  *datastore.Key `goloquent:"__key__"`
+	Name string
+}
+
+func (tablerUser) TableName() string {
+	return "users"
+}
+
+func TestBuilderTableName(t *testing.T) {
+	for _, model := range []interface{}{new(tablerUser), &[]tablerUser{}, &[]*tablerUser{}} {
+		e, err := newEntity(model)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if e.Name() != "users" {
+			t.Fatalf("Unexpected table name of %T, %q", model, e.Name())
+		}
+	}
+	if table, err := modelTable(tablerUser{}); err != nil || table != "users" {
+		t.Fatalf("Unexpected table name, %q, %v", table, err)
+	}
+
+	ss, err := newBuilder(newTestQuery(new(postgres), "")).getStmt(new(tablerUser))
+	if err != nil {
+		t.Fatalf("Unexpected error, %v", err)
+	}
+	if raw := ss.Raw(); raw != `SELECT * FROM "users";` {
+		t.Fatalf("Unexpected statement, %q", raw)
+	}
+	// the table name of the query has higher priority
+	ss, err = newBuilder(newTestQuery(new(postgres), "Member")).getStmt(new(tablerUser))
+	if err != nil {
+		t.Fatalf("Unexpected error, %v", err)
+	}
+	if raw := ss.Raw(); raw != `SELECT * FROM "Member";` {
+		t.Fatalf("Unexpected statement, %q", raw)
+	}
+}
+
+type keyerUser struct {
+	Key  *datastore.Key `goloquent:"__key__"`
+	Name string
+}
+
+func (keyerUser) PrimaryKey() string {
+	return "id"
+}
+
+func TestBuilderPrimaryKey(t *testing.T) {
+	type taggedUser struct {
+		_   struct{}       `goloquent:"primaryKey:uid"`
+		Key *datastore.Key `goloquent:"__key__"`
+	}
+	type conflictUser struct {
+		_   struct{}       `goloquent:"primaryKey:id"`
+		Key *datastore.Key `goloquent:"__key__"`
+		ID  string         `goloquent:"id"`
+	}
+
+	for model, pk := range map[interface{}]string{
+		new(tablerUser): pkColumn,
+		new(keyerUser):  "id",
+		new(taggedUser): "uid",
+		&[]*keyerUser{}: "id",
+		&[]taggedUser{}: "uid",
+	} {
+		e, err := newEntity(model)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if e.pk != pk || e.Columns()[0] != pk || primaryKeyColumn(e.columns) != pk {
+			t.Fatalf("Unexpected primary key of %T, %q", model, e.pk)
+		}
+		if name := new(mysql).GetSchema(e.fields[keyFieldName])[0].Name; name != pk {
+			t.Fatalf("Unexpected primary key column of %T, %q", model, name)
+		}
+	}
+	if _, err := newEntity(new(conflictUser)); err == nil {
+		t.Fatal("Expected error on primary key conflicts with the field")
+	}
+
+	key := datastore.NameKey("keyerUser", "a", nil)
+	ss, err := newBuilder(newTestQuery(new(postgres), "").
+		WhereEqual(keyFieldName, key).
+		Order("-" + keyFieldName)).getStmt(new(keyerUser))
+	if err != nil {
+		t.Fatalf("Unexpected error, %v", err)
+	}
+	if raw := ss.Raw(); raw != `SELECT * FROM "keyerUser" WHERE "id" = $1 ORDER BY "id" DESC;` {
+		t.Fatalf("Unexpected statement, %q", raw)
+	}
+
+	u := &keyerUser{Key: key, Name: "Dennis"}
+	e, err := newEntity(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := newBuilder(newTestQuery(new(postgres), ""))
+	for _, tc := range []struct {
+		build    func() (*stmt, error)
+		expected string
+	}{
+		{func() (*stmt, error) { return b.putStmt(nil, e) }, `INSERT INTO "keyerUser" ("id","Name") VALUES (??,??);`},
+		{func() (*stmt, error) { return b.saveMutation(e, reflect.ValueOf(u)) }, `UPDATE "keyerUser" SET "Name" = ?? WHERE "id" = ??;`},
+		{func() (*stmt, error) { return b.deleteStmt(e, false) }, `DELETE FROM "keyerUser" WHERE "id" IN (??);`},
+	} {
+		cmd, err := tc.build()
+		if err != nil {
+			t.Fatalf("Unexpected error, %v", err)
+		}
+		if raw := cmd.string(); raw != tc.expected {
+			t.Fatalf("Unexpected statement, %q", raw)
+		}
+	}
+
+	// the primary key of the struct is used on `Update` as well
+	raw, _, err := newTestQuery(new(postgres), "").
+		WhereEqual(keyFieldName, key).
+		ToUpdateSQL(keyerUser{Name: "Dennis"})
+	if err != nil {
+		t.Fatalf("Unexpected error, %v", err)
+	}
+	if raw != `UPDATE "keyerUser" SET "Name" = $1 WHERE "id" = $2 ORDER BY "id" ASC;` {
+		t.Fatalf("Unexpected statement, %q", raw)
+	}
+}
+
+func TestBuilderReservedColumn(t *testing.T) {
+	b := newBuilder(newTestQuery(new(mysql), "User"))
+	for _, col := range []string{pkColumn, softDeleteColumn} {
+		if err := b.dropColumn("User", col); err == nil {
+			t.Fatalf("Expected error on dropping column %q", col)
+		}
+		if err := b.renameColumn("User", col, "Name"); err == nil {
+			t.Fatalf("Expected error on renaming column %q", col)
+		}
+		if err := b.renameColumn("User", "Name", col); err == nil {
+			t.Fatalf("Expected error on renaming to column %q", col)
+		}
+	}
+	if err := b.renameColumn("User", "Name", " "); err == nil {
+		t.Fatal("Expected error on renaming to empty column")
+	}
+}
+
+func TestBuilderRenameColumn(t *testing.T) {
+	conn, err := sql.Open("goloquent_test", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	defer func() { testDriver.results = nil }()
+
+	d := &mysql{sequel: sequel{dbName: "goloquent"}}
+	db := &DB{client: Client{sqlCommon: conn, dialect: d}, dialect: d}
+	d.SetDB(db.client)
+	count := func(n int64) *fakeRows {
+		return &fakeRows{cols: []string{"count(*)"}, vals: [][]driver.Value{{n}}}
+	}
+	ddl := "CREATE TABLE `User` (\n  `$Key` varchar(512) NOT NULL,\n" +
+		"  `Nickname` varchar(191) COLLATE utf8mb4_unicode_ci NOT NULL DEFAULT '',\n  PRIMARY KEY (`$Key`)\n)"
+	testDriver.results = []*fakeRows{count(1), count(0), {
+		cols: []string{"Table", "Create Table"},
+		vals: [][]driver.Value{{"User", ddl}},
+	}}
+	testDriver.executed = nil
+	if err := db.RenameColumn("User", "Nickname", "DisplayName"); err != nil {
+		t.Fatalf("Unexpected error, %v", err)
+	}
+	if len(testDriver.executed) != 1 || testDriver.executed[0] != "ALTER TABLE `goloquent`.`User` CHANGE COLUMN "+
+		"`Nickname` `DisplayName` varchar(191) COLLATE utf8mb4_unicode_ci NOT NULL DEFAULT '';" {
+		t.Fatalf("Unexpected statement, %v", testDriver.executed)
+	}
+
+	// the column is renamed already
+	testDriver.results = []*fakeRows{count(0), count(1)}
+	testDriver.executed = nil
+	if err := db.RenameColumn("User", "Nickname", "DisplayName"); err != nil || len(testDriver.executed) > 0 {
+		t.Fatalf("Expected rename should do nothing, %v", err)
+	}
+
+	// the error of looking up the column is returned instead of treating it as not exists
+	testDriver.results = nil
+	if err := db.DropColumn("User", "Nickname"); err == nil {
+		t.Fatal("Expected error when the column cannot be looked up")
+	}
+	if err := db.RenameColumn("User", "Nickname", "DisplayName"); err == nil {
+		t.Fatal("Expected error when the column cannot be looked up")
+	}
+
+	for _, tc := range []struct {
+		dialect Dialect
+		raw     string
+	}{
+		{new(postgres), `ALTER TABLE "User" RENAME COLUMN "Nickname" TO "DisplayName";`},
+		{new(sqlite), `ALTER TABLE "User" RENAME COLUMN "Nickname" TO "DisplayName";`},
+	} {
+		if raw, err := tc.dialect.RenameColumn("User", "Nickname", "DisplayName"); err != nil || raw != tc.raw {
+			t.Fatalf("Unexpected statement, %q, %v", raw, err)
+		}
+	}
+}
+
+func TestBuilderPutSaveStructError(t *testing.T) {
+	conn, err := sql.Open("goloquent_test", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// the element of `[]interface{}` cannot be saved, so `SaveStruct` will fail
+	type user struct {
+		Key  *datastore.Key `goloquent:"__key__"`
+		Tags []interface{}
+	}
+
+	u := &user{Tags: []interface{}{"a"}}
+	_, expected := SaveStruct(u)
+	if expected == nil {
+		t.Fatal("Expected `SaveStruct` to fail")
+	}
+
+	db := &DB{client: Client{sqlCommon: conn, dialect: new(mysql)}, dialect: new(mysql)}
+	testDriver.executed = nil
+	if err := db.Create(u); err == nil || err.Error() != expected.Error() {
+		t.Fatalf("Expected error %v, but get %v", expected, err)
+	}
+	if len(testDriver.executed) != 0 {
+		t.Fatalf("Expected nothing to be inserted, but get %v", testDriver.executed)
+	}
+}
+
+func TestDBCreateWithResult(t *testing.T) {
+	conn, err := sql.Open("goloquent_test", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	type user struct {
+		Key  *datastore.Key `goloquent:"__key__"`
+		Name string
+	}
+
+	db := &DB{client: Client{sqlCommon: conn, dialect: new(mysql)}, dialect: new(mysql), batchSize: 2}
+	testDriver.executed = nil
+	users := []user{{Name: "a"}, {Name: "b"}, {Name: "c"}}
+	result, err := db.CreateWithResult(&users)
+	if err != nil {
+		t.Fatalf("Unexpected error, %v", err)
+	}
+	if len(testDriver.executed) != 2 {
+		t.Fatalf("Expected 2 batches to be inserted, but get %v", testDriver.executed)
+	}
+	if n, err := result.RowsAffected(); err != nil || n != 2 {
+		t.Fatalf("Unexpected rows affected, %d, %v", n, err)
+	}
+
+	result, err = db.CreateWithResult(&[]user{})
+	if err != nil {
+		t.Fatalf("Unexpected error, %v", err)
+	}
+	if n, err := result.RowsAffected(); err != nil || n != 0 {
+		t.Fatalf("Unexpected rows affected, %d, %v", n, err)
+	}
+}
+
+func TestBuilderUpdateJSON(t *testing.T) {
+	for _, tc := range []struct {
+		dialect Dialect
+		raw     string
+	}{
+		{&mysql{sequel: sequel{dbName: "goloquent"}}, "UPDATE `goloquent`.`User` SET `Address` = JSON_SET(`Address`, '$.city.name', CAST(? AS JSON)) WHERE `Age` > ?;"},
+		{new(postgres), `UPDATE "User" SET "Address" = jsonb_set(("Address")::jsonb, '{city,name}', $1::jsonb) WHERE "Age" > $2;`},
+		{new(sqlite), `UPDATE "User" SET "Address" = json_set("Address", '$.city.name', json(?)) WHERE "Age" > ?;`},
+	} {
+		q := newTestQuery(tc.dialect, "User").WithoutTimestamps().Where("Age", ">", 10)
+		cmd, err := newBuilder(q).updateJSONStmt("Address", "$.city.name", "Kuala Lumpur")
+		if err != nil {
+			t.Fatalf("Unexpected error, %v", err)
+		}
+		ss := &Stmt{stmt: *cmd, replacer: tc.dialect}
+		if raw := ss.Raw(); raw != tc.raw {
+			t.Fatalf("Unexpected statement, %q", raw)
+		}
+		if len(cmd.arguments) != 2 || cmd.arguments[0] != `"Kuala Lumpur"` {
+			t.Fatalf("Unexpected arguments, %v", cmd.arguments)
+		}
+	}
+
+	b := newBuilder(newTestQuery(new(mysql), "User").WithoutTimestamps())
+	for _, path := range []string{"", "$.", "a..b", "a'b", "a[0]", "$.a.b c"} {
+		if _, err := b.updateJSONStmt("Address", path, 1); err == nil {
+			t.Fatalf("Expected error on invalid json path %q", path)
+		}
+	}
+	if _, err := b.updateJSONStmt(keyFieldName, "a", 1); err == nil {
+		t.Fatal("Expected error on updating __key__")
+	}
+	if _, err := newBuilder(newTestQuery(new(mysql), "")).updateJSONStmt("Address", "a", 1); err == nil {
+		t.Fatal("Expected error on missing table name")
+	}
+}
+
+func TestBuilderUpdateWithStruct(t *testing.T) {
+	type user struct {
+		Key    *datastore.Key `goloquent:"__key__"`
+		Name   string
+		Age    int
+		Active bool
+	}
+	u := user{Name: "Dummy"}
+	for _, tc := range []struct {
+		query *Query
+		raw   string
+		args  int
+	}{
+		{newTestQuery(new(mysql), "User"), "`Name` = ??", 1},
+		{newTestQuery(new(mysql), "User").Select("Active"), "`Active` = ??,`Name` = ??", 2},
+		{newTestQuery(new(mysql), "User").WithZeroValues(), "`Active` = ??,`Age` = ??,`Name` = ??", 3},
+	} {
+		cmd, err := newBuilder(tc.query.WithoutTimestamps()).updateWithStruct(u)
+		if err != nil {
+			t.Fatalf("Unexpected error, %v", err)
+		}
+		if raw := cmd.string(); raw != tc.raw {
+			t.Fatalf("Unexpected statement, %q", raw)
+		}
+		if len(cmd.arguments) != tc.args {
+			t.Fatalf("Unexpected arguments, %v", cmd.arguments)
+		}
+	}
+
+	if _, err := newBuilder(newTestQuery(new(mysql), "User").WithoutTimestamps()).updateWithStruct(user{}); err == nil {
+		t.Fatal("Expected error when there is no field to update")
+	}
+}
+
+func TestBuilderUpsertCreatedAt(t *testing.T) {
+	conn, err := sql.Open("goloquent_test", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	type user struct {
+		Key       *datastore.Key `goloquent:"__key__"`
+		Name      string
+		CreatedAt time.Time `goloquent:"created_at"`
+		UpdatedAt time.Time
+	}
+
+	for _, tc := range []struct {
+		dialect Dialect
+		clause  string
+	}{
+		{new(postgres), ` ON CONFLICT ("$Key") DO UPDATE SET "Name" = EXCLUDED."Name","UpdatedAt" = EXCLUDED."UpdatedAt";`},
+		{new(mysql), " ON DUPLICATE KEY UPDATE `Name`=VALUES(`Name`),`UpdatedAt`=VALUES(`UpdatedAt`);"},
+	} {
+		db := &DB{client: Client{sqlCommon: conn, dialect: tc.dialect}, dialect: tc.dialect}
+		testDriver.executed = nil
+		u := &user{Key: datastore.IDKey("user", 1, nil), Name: "Joe"}
+		if err := db.Upsert(u); err != nil {
+			t.Fatalf("Unexpected error, %v", err)
+		}
+		if len(testDriver.executed) != 1 || !strings.HasSuffix(testDriver.executed[0], tc.clause) {
+			t.Fatalf("Expected created_at should not be updated on conflict, %v", testDriver.executed)
+		}
+	}
+}
+
+func TestDialectOnConflictUpdate(t *testing.T) {
+	cols := []string{"Name", "Age"}
+	if s := new(postgres).OnConflictUpdate("User", nil, cols); s != `ON CONFLICT ("$Key") DO UPDATE SET "Name" = EXCLUDED."Name","Age" = EXCLUDED."Age"` {
+		t.Fatalf("Unexpected postgres statement, %q", s)
+	}
+	if s := new(postgres).OnConflictUpdate("User", []string{"Email", "Phone"}, cols); s != `ON CONFLICT ("Email","Phone") DO UPDATE SET "Name" = EXCLUDED."Name","Age" = EXCLUDED."Age"` {
+		t.Fatalf("Unexpected postgres statement, %q", s)
+	}
+	if s := new(mysql).OnConflictUpdate("User", []string{"Email"}, cols); s != "ON DUPLICATE KEY UPDATE `Name`=VALUES(`Name`),`Age`=VALUES(`Age`)" {
+		t.Fatalf("Unexpected mysql statement, %q", s)
+	}
+	// the updated value is the row proposed for insertion, the value of the existing row makes the update a no-op
+	if s := new(postgres).OnConflictUpdate("User", nil, []string{"Name"}); s != `ON CONFLICT ("$Key") DO UPDATE SET "Name" = EXCLUDED."Name"` {
+		t.Fatalf("Expected postgres to update using the excluded row, %q", s)
+	}
+
+	type user struct {
+		Key   *datastore.Key `goloquent:"__key__"`
+		Email string
+	}
+	b := newBuilder(newTestQuery(new(postgres), ""))
+	if err := b.upsert(&user{}, nil, "Phone"); err == nil {
+		t.Fatal("Expected error on unknown conflict field")
+	}
+}
+
+func TestDialectLockClause(t *testing.T) {
+	type user struct {
+		Key  *datastore.Key `goloquent:"__key__"`
+		Name string
+	}
+
+	for _, tc := range []struct {
+		dialect Dialect
+		mode    locked
+		raw     string
+	}{
+		{&mysql{sequel: sequel{dbName: "goloquent"}}, ReadLock, "SELECT * FROM `goloquent`.`user` WHERE `Name` = ? LOCK IN SHARE MODE;"},
+		{&mysql{sequel: sequel{dbName: "goloquent"}}, WriteLock, "SELECT * FROM `goloquent`.`user` WHERE `Name` = ? FOR UPDATE;"},
+		{new(postgres), ReadLock, `SELECT * FROM "user" WHERE "Name" = $1 FOR SHARE;`},
+		{new(postgres), WriteLock, `SELECT * FROM "user" WHERE "Name" = $1 FOR UPDATE;`},
+		{new(sqlite), ReadLock, `SELECT * FROM "user" WHERE "Name" = ?;`},
+		{new(sqlite), WriteLock, `SELECT * FROM "user" WHERE "Name" = ?;`},
+	} {
+		q := newTestQuery(tc.dialect, "").Where("Name", "=", "Joe").Lock(tc.mode)
+		raw, _, err := q.ToSQL(new(user))
+		if err != nil {
+			t.Fatalf("Unexpected error, %v", err)
+		}
+		if raw != tc.raw {
+			t.Fatalf("Unexpected statement, %q", raw)
+		}
+	}
+
+	for _, tc := range []struct {
+		query *Query
+		raw   string
+	}{
+		{newTestQuery(new(mysql), "user").LockForUpdate().SkipLocked(), "SELECT * FROM ``.`user` FOR UPDATE SKIP LOCKED;"},
+		{newTestQuery(new(mysql), "user").LockForUpdate().NoWait(), "SELECT * FROM ``.`user` FOR UPDATE NOWAIT;"},
+		{newTestQuery(new(postgres), "user").RLock().SkipLocked(), `SELECT * FROM "user" FOR SHARE SKIP LOCKED;`},
+		{newTestQuery(new(postgres), "user").LockForUpdate().NoWait(), `SELECT * FROM "user" FOR UPDATE NOWAIT;`},
+		{newTestQuery(new(mysql), "user").Lock(WriteLockSkipLocked), "SELECT * FROM ``.`user` FOR UPDATE SKIP LOCKED;"},
+		{newTestQuery(new(postgres), "user").Lock(WriteLockNoWait), `SELECT * FROM "user" FOR UPDATE NOWAIT;`},
+		{newTestQuery(&mysql{version: "10.6.5-MariaDB"}, "user").Lock(WriteLockNoWait), "SELECT * FROM ``.`user` FOR UPDATE NOWAIT;"},
+		{newTestQuery(new(postgres), "user").Lock(WriteLockSkipLocked).Lock(WriteLock), `SELECT * FROM "user" FOR UPDATE;`},
+	} {
+		raw, _, err := tc.query.ToSQL(new(user))
+		if err != nil {
+			t.Fatalf("Unexpected error, %v", err)
+		}
+		if raw != tc.raw {
+			t.Fatalf("Unexpected statement, %q", raw)
+		}
+	}
+
+	for _, q := range []*Query{
+		newTestQuery(new(mysql), "user").RLock().SkipLocked(),
+		newTestQuery(new(sqlite), "user").LockForUpdate().SkipLocked(),
+		newTestQuery(new(postgres), "user").NoWait(),
+		newTestQuery(&mysql{version: "5.7.31"}, "user").Lock(WriteLockSkipLocked),
+		newTestQuery(&mysql{version: "10.5.8-MariaDB"}, "user").Lock(WriteLockNoWait),
+	} {
+		if _, _, err := q.ToSQL(new(user)); err == nil {
+			t.Fatal("Expected error on unsupported lock option")
+		}
+	}
+
+	q := newTestQuery(new(postgres), "user").LockForUpdate()
+	q.SkipLocked()
+	q.NoWait()
+	if q.lockOption != 0 {
+		t.Fatal("Expected lock option should not modify the original query")
+	}
+}
+
+func TestSetTimestamp(t *testing.T) {
+	type model struct {
+		Name      string
+		CreatedAt time.Time `goloquent:"created_at"`
+		UpdatedAt *time.Time
+	}
+
+	now := time.Now().UTC()
+	m := new(model)
+	if err := setTimestamp(reflect.ValueOf(m), now, false, createdAtFields...); err != nil {
+		t.Fatalf("Unexpected error, %v", err)
+	}
+	if err := setTimestamp(reflect.ValueOf(m), now, false, updatedAtFields...); err != nil {
+		t.Fatalf("Unexpected error, %v", err)
+	}
+	if !m.CreatedAt.Equal(now) || m.UpdatedAt == nil || !m.UpdatedAt.Equal(now) {
+		t.Fatalf("Unexpected timestamps, %v", m)
+	}
+
+	later := now.Add(time.Hour)
+	if err := setTimestamp(reflect.ValueOf(m), later, false, createdAtFields...); err != nil {
+		t.Fatalf("Unexpected error, %v", err)
+	}
+	if !m.CreatedAt.Equal(now) {
+		t.Fatal("Expected non-zero `CreatedAt` should not be overwritten")
+	}
+	if err := setTimestamp(reflect.ValueOf(m), later, true, updatedAtFields...); err != nil {
+		t.Fatalf("Unexpected error, %v", err)
+	}
+	if !m.UpdatedAt.Equal(later) {
+		t.Fatal("Expected `UpdatedAt` should be overwritten")
+	}
+}
+
+func TestSeedFilters(t *testing.T) {
+	type model struct {
+		Name   string
+		Age    uint8
+		Status string `goloquent:"status"`
+		Email  *string
+	}
+
+	m := new(model)
+	q := newTestQuery(new(postgres), "User").
+		Where("Name", "=", "Joe").
+		WhereEqual("Age", 18).
+		WhereEqual("status", "ACTIVE").
+		Where("Email", ">", "a").
+		OrWhere("Name", "=", "Jane")
+	if err := seedFilters(m, q.filters); err != nil {
+		t.Fatalf("Unexpected error, %v", err)
+	}
+	if m.Name != "Joe" || m.Age != 18 || m.Status != "ACTIVE" || m.Email != nil {
+		t.Fatalf("Unexpected seeded model, %v", m)
+	}
+
+	q = newTestQuery(new(postgres), "User").WhereEqual("Name", 10)
+	if err := seedFilters(new(model), q.filters); err == nil {
+		t.Fatal("Expected error on unmatched data type")
+	}
+}
+
+func TestSoftDeleteScope(t *testing.T) {
+	build := func(q *Query) string {
+		b := newBuilder(q)
+		cmd, err := b.buildWhere(softDeleteScope(b.query, true))
+		if err != nil {
+			t.Fatalf("Unexpected error, %v", err)
+		}
+		return cmd.string()
+	}
+
+	q := newTestQuery(new(postgres), "User").Where("Age", ">", 10)
+	if w := build(q); w != ` WHERE "Age" > ?? AND "$Deleted" IS NULL` {
+		t.Fatalf("Unexpected statement, %q", w)
+	}
+	if w := build(q.WithTrashed()); w != ` WHERE "Age" > ??` {
+		t.Fatalf("Unexpected statement, %q", w)
+	}
+	if w := build(q.OnlyTrashed()); w != ` WHERE "Age" > ?? AND "$Deleted" IS NOT NULL` {
+		t.Fatalf("Unexpected statement, %q", w)
+	}
+	if w := build(q.Unscoped().OnlyTrashed()); w != ` WHERE "Age" > ?? AND "$Deleted" IS NOT NULL` {
+		t.Fatalf("Unexpected statement, %q", w)
+	}
+	if len(q.filters) != 1 {
+		t.Fatal("Expected soft delete scope should not modify the query")
+	}
+}
+
+func TestQueryToSQL(t *testing.T) {
+	type user struct {
+		Key     *datastore.Key `goloquent:"__key__"`
+		Name    string
+		Deleted SoftDelete
+	}
+
+	q := newTestQuery(&mysql{sequel: sequel{dbName: "goloquent"}}, "").
+		Where("Name", "=", "Joe").
+		WLock()
+	if _, _, err := q.ToSQL(); err == nil {
+		t.Fatal("Expected error on missing table name")
+	}
+
+	raw, args, err := q.ToSQL(new(user))
+	if err != nil {
+		t.Fatalf("Unexpected error, %v", err)
+	}
+	if raw != "SELECT * FROM `goloquent`.`user` WHERE `Name` = ? AND `$Deleted` IS NULL FOR UPDATE;" {
+		t.Fatalf("Unexpected statement, %q", raw)
+	}
+	if len(args) != 1 || args[0] != "Joe" {
+		t.Fatalf("Unexpected arguments, %v", args)
+	}
+	if q.table != "" || len(q.filters) != 1 {
+		t.Fatal("Expected `ToSQL` should not modify the query")
+	}
+
+	str, err := newTestQuery(new(postgres), "User").
+		Where("Name", "=", "O'Neil").
+		Where("Age", ">", 10).
+		ToSQLInterpolated()
+	if err != nil {
+		t.Fatalf("Unexpected error, %v", err)
+	}
+	if str != `SELECT * FROM "User" WHERE "Name" = 'O''Neil' AND "Age" > 10;` {
+		t.Fatalf("Unexpected interpolated statement, %q", str)
+	}
+}
+
+func TestQueryToWriteSQL(t *testing.T) {
+	q := newTestQuery(new(postgres), "User").
+		WithoutTimestamps().
+		Where("Age", ">", 10).
+		Limit(5)
+	raw, args, err := q.ToUpdateSQL(map[string]interface{}{"Name": "Joe"})
+	if err != nil {
+		t.Fatalf("Unexpected error, %v", err)
+	}
+	if raw != `UPDATE "User" SET "Name" = $1 WHERE "$Key" IN (SELECT "$Key" FROM "User" WHERE "Age" > $2 ORDER BY "$Key" ASC LIMIT 5);` {
+		t.Fatalf("Unexpected update statement, %q", raw)
+	}
+	if len(args) != 2 || args[0] != "Joe" || args[1] != int64(10) {
+		t.Fatalf("Unexpected arguments, %v", args)
+	}
+	if raw, args, err := q.ToUpdateSQL(map[string]interface{}{}); err != nil || raw != "" || args != nil {
+		t.Fatalf("Expected empty statement on nothing to update, %q, %v, %v", raw, args, err)
+	}
+	if _, _, err := q.ToUpdateSQL(nil); err == nil {
+		t.Fatal("Expected error on nil value")
+	}
+
+	raw, args, err = newTestQuery(&mysql{sequel: sequel{dbName: "goloquent"}}, "User").
+		Where("Age", "<", 18).
+		ToDeleteSQL()
+	if err != nil {
+		t.Fatalf("Unexpected error, %v", err)
+	}
+	if raw != "DELETE FROM `goloquent`.`User` WHERE `Age` < ?;" {
+		t.Fatalf("Unexpected delete statement, %q", raw)
+	}
+	if len(args) != 1 || args[0] != int64(18) {
+		t.Fatalf("Unexpected arguments, %v", args)
+	}
+	if _, _, err := newTestQuery(new(mysql), "").ToDeleteSQL(); err == nil {
+		t.Fatal("Expected error on missing table name")
+	}
+}
+
+func TestQueryTimeout(t *testing.T) {
+	q := newTestQuery(new(mysql), "user")
+	q.db.readers = []Client{{}, {}}
+	b := newBuilder(q.Timeout(time.Second))
+	if b.db.client.timeout != time.Second {
+		t.Fatalf("Unexpected client timeout, %v", b.db.client.timeout)
+	}
+	for _, r := range b.db.readers {
+		if r.timeout != time.Second {
+			t.Fatalf("Unexpected replica timeout, %v", r.timeout)
+		}
+	}
+	if q.timeout != 0 || q.db.readers[0].timeout != 0 {
+		t.Fatal("Expected `Timeout` should not modify the query")
+	}
+	if err := q.Timeout(-time.Second).getError(); err == nil {
+		t.Fatal("Expected error on negative timeout")
+	}
+
+	c := Client{timeout: time.Nanosecond}
+	ctx, cancel := c.context()
+	defer cancel()
+	<-ctx.Done()
+	err := wrapError(c.timeoutError(ctx, errors.New("canceling query")))
+	if x, isOk := err.(*TimeoutError); !isOk || x.Timeout != time.Nanosecond {
+		t.Fatalf("Expected timeout error, but get %v", err)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatal("Expected timeout error wraps the deadline exceeded")
+	}
+	if _, isOk := wrapError(context.DeadlineExceeded).(*TimeoutError); !isOk {
+		t.Fatal("Expected deadline exceeded to be timeout error")
+	}
+	if _, isOk := wrapError(errors.New("syntax error")).(*TimeoutError); isOk {
+		t.Fatal("Expected error other than deadline exceeded not to be timeout error")
+	}
+}
+
+func TestDBMigrateDryRun(t *testing.T) {
+	conn, err := sql.Open("goloquent_test", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	type user struct {
+		_     struct{}       `goloquent:"uniqueTogether:Email,Phone"`
+		Key   *datastore.Key `goloquent:"__key__"`
+		Email string
+		Phone string
+	}
+
+	d := new(sqlite)
+	client := Client{sqlCommon: conn, dialect: d}
+	d.SetDB(client)
+	db := &DB{client: client, dialect: d}
+	testDriver.executed = nil
+	stmts, err := db.MigrateDryRun(new(user))
+	if err != nil {
+		t.Fatalf("Unexpected error, %v", err)
+	}
+	if !reflect.DeepEqual(stmts, []string{
+		`CREATE TABLE IF NOT EXISTS "user" ("$Key" TEXT NOT NULL,"Email" TEXT NOT NULL DEFAULT '',"Phone" TEXT NOT NULL DEFAULT '',PRIMARY KEY ("$Key"));`,
+		`CREATE UNIQUE INDEX "user_Email_Phone_unique" ON "user" ("Email","Phone");`,
+	}) {
+		t.Fatalf("Unexpected statements, %v", stmts)
+	}
+	if len(testDriver.executed) > 0 {
+		t.Fatalf("Expected nothing to be executed, but end up with %v", testDriver.executed)
+	}
+	if err := db.Migrate(new(user)); err != nil {
+		t.Fatalf("Unexpected error, %v", err)
+	}
+	if !reflect.DeepEqual(testDriver.executed, stmts) {
+		t.Fatalf("Expected the statements to be executed, but end up with %v", testDriver.executed)
+	}
+}
+
+func TestBuilderAlterEnumColumn(t *testing.T) {
+	conn, err := sql.Open("goloquent_test", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	defer func() { testDriver.results = nil }()
+
+	type user struct {
+		Key    *datastore.Key `goloquent:"__key__"`
+		Status string         `goloquent:",enum=active|inactive|banned"`
+		Role   string
+	}
+
+	d := new(postgres)
+	db := &DB{client: Client{sqlCommon: conn, dialect: d}, dialect: d}
+	d.SetDB(db.client)
+	e, err := newEntity(new(user))
+	if err != nil {
+		t.Fatal(err)
+	}
+	testDriver.results = []*fakeRows{
+		{cols: []string{"column_name"}, vals: [][]driver.Value{{pkColumn}, {"Status"}, {"Role"}}},
+		{cols: []string{"indexname"}},
+		{cols: []string{"constraint_name"}},
+		{cols: []string{"constraint_name"}, vals: [][]driver.Value{{"user_Status_check"}, {"user_Role_check"}}},
+	}
+	stmts, err := d.AlterTable("user", e.columns, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw := strings.Join(stmts, " ")
+	// the enum values of the existing column are replaced, and the enum removed from the column is dropped
+	if !strings.Contains(raw, `DROP CONSTRAINT "user_Status_check",ADD CONSTRAINT "user_Status_check" CHECK ("Status" IN ('active','inactive','banned'))`) {
+		t.Fatalf("Expected enum constraint to be replaced, %q", raw)
+	}
+	if !strings.Contains(raw, `DROP CONSTRAINT "user_Role_check"`) {
+		t.Fatalf("Expected enum constraint to be dropped, %q", raw)
+	}
+}
+
+func TestBuilderKeyColumn(t *testing.T) {
+	type post struct {
+		Key     *datastore.Key `goloquent:"__key__"`
+		Title   string
+		Deleted SoftDelete
+	}
+	type taggedPost struct {
+		_   struct{}       `goloquent:"primaryKey:uid"`
+		Key *datastore.Key `goloquent:"__key__"`
+	}
+	type conflictPost struct {
+		Key       *datastore.Key `goloquent:"__key__"`
+		DeletedAt string         `goloquent:"deleted_at"`
+		Deleted   SoftDelete
+	}
+
+	d := new(postgres)
+	db := &DB{client: Client{pk: "id", deleted: "deleted_at"}, dialect: d}
+	d.SetDB(db.client)
+	key := datastore.NameKey("post", "a", nil)
+
+	ss, err := newBuilder(db.NewQuery().
+		WhereEqual(keyFieldName, key).
+		Order(keyFieldName)).getStmt(new(post))
+	if err != nil {
+		t.Fatalf("Unexpected error, %v", err)
+	}
+	if raw := ss.Raw(); raw != `SELECT * FROM "post" WHERE "id" = $1 AND "deleted_at" IS NULL ORDER BY "id" ASC;` {
+		t.Fatalf("Unexpected statement, %q", raw)
+	}
+
+	b := newBuilder(db.NewQuery())
+	p := &post{Key: key, Title: "hello"}
+	e, err := b.newEntity(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cols := e.Columns(); !reflect.DeepEqual(cols, []string{"id", "Title", "deleted_at"}) {
+		t.Fatalf("Unexpected columns, %v", cols)
+	}
+	names := make([]string, 0)
+	for _, c := range e.columns {
+		names = append(names, d.GetSchema(c)[0].Name)
+	}
+	if !reflect.DeepEqual(names, []string{"id", "Title", "deleted_at"}) {
+		t.Fatalf("Unexpected schema, %v", names)
+	}
+	for _, tc := range []struct {
+		build    func() (*stmt, error)
+		expected string
+	}{
+		{func() (*stmt, error) { return b.putStmt(nil, e) }, `INSERT INTO "post" ("id","Title","deleted_at") VALUES (??,??,??);`},
+		{func() (*stmt, error) { return b.deleteStmt(e, true) }, `UPDATE "post" SET "deleted_at" = ?? WHERE "id" IN (??);`},
+		{func() (*stmt, error) { return b.restoreStmt(e) }, `UPDATE "post" SET "deleted_at" = NULL WHERE "id" IN (??);`},
+	} {
+		cmd, err := tc.build()
+		if err != nil {
+			t.Fatalf("Unexpected error, %v", err)
+		}
+		if raw := cmd.string(); raw != tc.expected {
+			t.Fatalf("Unexpected statement, %q", raw)
+		}
+	}
+
+	// the primary key cannot be omitted
+	if omits := db.NewQuery().Omit("id", "Title").omits; !reflect.DeepEqual(omits, []string{"Title"}) {
+		t.Fatalf("Unexpected omits, %v", omits)
+	}
+	if omits := db.Omit("id", "Title").(*DB).omits; !reflect.DeepEqual(omits, []string{"Title"}) {
+		t.Fatalf("Unexpected omits, %v", omits)
+	}
+
+	// the primary key declared by the model has higher priority
+	if e, err := b.newEntity(new(taggedPost)); err != nil || e.pk != "uid" {
+		t.Fatalf("Unexpected primary key, %v", err)
+	}
+	if _, err := b.newEntity(new(conflictPost)); err == nil {
+		t.Fatal("Expected error on soft delete column conflicts with the field")
+	}
+	if err := b.dropColumn("post", "deleted_at"); err == nil {
+		t.Fatal("Expected soft delete column cannot be dropped")
+	}
+}
+
+func TestQueryFindMulti(t *testing.T) {
+	type user struct {
+		Key  *datastore.Key `goloquent:"__key__"`
+		Name string
+	}
+
+	q := newTestQuery(new(mysql), "")
+	users := []*user{{Name: "stale"}}
+	if err := q.FindMulti(nil, &users); err != nil || len(users) != 0 {
+		t.Fatalf("Expected empty result without keys, %v, %v", users, err)
+	}
+	if err := q.FindMulti([]*datastore.Key{nil}, &users); err == nil {
+		t.Fatal("Expected error on nil key")
+	}
+	if err := q.FindMulti([]*datastore.Key{datastore.IncompleteKey("user", nil)}, &users); err == nil {
+		t.Fatal("Expected error on incomplete key")
+	}
+	if err := q.FindMulti(nil, new(user)); err == nil {
+		t.Fatal("Expected error on non slice model")
+	}
+
+	err := NotFoundError{2: datastore.NameKey("user", "b", nil), 0: datastore.NameKey("user", "a", nil)}
+	if !errors.Is(err, ErrNoSuchEntity) {
+		t.Fatal("Expected NotFoundError matches ErrNoSuchEntity")
+	}
+	if msg := err.Error(); msg != `goloquent: 2 entity(s) not found, key 0: /user,a; key 2: /user,b` {
+		t.Fatalf("Unexpected error message, %q", msg)
+	}
+}
@@ -3,7 +3,6 @@ package goloquent
 import (
 	"encoding/base64"
 	"encoding/json"
-	"fmt"
 	"strings"
 
 	"cloud.google.com/go/datastore"
@@ -34,12 +33,15 @@ func DecodeCursor(c string) (Cursor, error) {
 	}
 	b, err := base64.URLEncoding.DecodeString(c)
 	if err != nil {
-		return Cursor{}, fmt.Errorf("goloquent: invalid cursor")
+		return Cursor{}, ErrInvalidCursor
 	}
 	cc := new(Cursor)
 	cc.cc = b
 	if err := json.Unmarshal(b, cc); err != nil {
-		return Cursor{}, fmt.Errorf("goloquent: invalid cursor")
+		return Cursor{}, ErrInvalidCursor
+	}
+	if cc.Key == nil || cc.Key.Incomplete() {
+		return Cursor{}, ErrInvalidCursor
 	}
 	return *cc, nil
 }
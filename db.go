@@ -2,11 +2,14 @@ package goloquent
 
 import (
 	"bytes"
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
+	"log"
 	"reflect"
 	"strings"
+	"sync"
 	"time"
 
 	"cloud.google.com/go/datastore"
@@ -18,6 +21,10 @@ type TransactionHandler func(*DB) error
 // LogHandler :
 type LogHandler func(*Stmt)
 
+// QueryHandler : will be called after every statement is executed, the `crud` is the operation
+// type of the statement, it can be "create", "read", "update", "delete" or empty for the others
+type QueryHandler func(crud string, sql string, d time.Duration, err error)
+
 // public constant variables :
 const (
 	pkLen            = 512
@@ -42,6 +49,20 @@ type Config struct {
 	UnixSocket string
 	CharSet    *CharSet
 	Logger     LogHandler
+	// OnQuery will be called after every statement is executed, such as collecting the metrics
+	OnQuery QueryHandler
+	// IsDebug will print every statement with its arguments to the standard logger
+	IsDebug bool
+	// MaxOpenConns is the maximum number of open connections, zero means unlimited
+	MaxOpenConns int
+	// MaxIdleConns is the maximum number of idle connections, zero will keep the driver default
+	MaxIdleConns int
+	// ConnMaxLifetime is the maximum amount of time a connection may be reused, zero means forever
+	ConnMaxLifetime time.Duration
+	// KeyColumn is the primary key column of the tables, default is `$Key`
+	KeyColumn string
+	// DeletedColumn is the soft delete column of the tables, default is `$Deleted`
+	DeletedColumn string
 }
 
 // Normalize :
@@ -51,6 +72,8 @@ func (c *Config) Normalize() {
 	c.Port = strings.TrimSpace(c.Port)
 	c.Database = strings.TrimSpace(c.Database)
 	c.UnixSocket = strings.TrimSpace(c.UnixSocket)
+	c.KeyColumn = strings.TrimSpace(c.KeyColumn)
+	c.DeletedColumn = strings.TrimSpace(c.DeletedColumn)
 	if c.CharSet != nil && c.CharSet.Encoding != "" && c.CharSet.Collation != "" {
 		c.CharSet.Collation = strings.TrimSpace(c.CharSet.Collation)
 		c.CharSet.Encoding = strings.TrimSpace(c.CharSet.Encoding)
@@ -63,6 +86,7 @@ func (c *Config) Normalize() {
 // Replacer :
 type Replacer interface {
 	Upsert(model interface{}, k ...*datastore.Key) error
+	UpsertOn(keys []string, model interface{}) error
 	Save(model interface{}) error
 }
 
@@ -73,12 +97,49 @@ type Client struct {
 	CharSet
 	dialect Dialect
 	logger  LogHandler
+	debug   bool
+	stmts   *stmtCache
+	timeout time.Duration
+	onQuery QueryHandler
+	// pk and deleted are the primary key and soft delete column of the tables, empty means the default
+	pk      string
+	deleted string
+}
+
+// keyColumn will return the primary key column of the tables, it's `$Key` unless it's configured
+func (c Client) keyColumn() string {
+	if c.pk != "" {
+		return c.pk
+	}
+	return pkColumn
+}
+
+// deletedColumn will return the soft delete column of the tables, it's `$Deleted` unless it's configured
+func (c Client) deletedColumn() string {
+	if c.deleted != "" {
+		return c.deleted
+	}
+	return softDeleteColumn
+}
+
+func (c Client) newStmt(s *stmt) *Stmt {
+	return &Stmt{
+		stmt:     *s,
+		crud:     crudOf(s.string()),
+		replacer: c.dialect,
+	}
 }
 
-func (c Client) consoleLog(s *Stmt) {
+func (c Client) consoleLog(s *Stmt, err error) {
+	if c.debug {
+		log.Printf("[%.3fms] %s", s.TimeElapse().Seconds()*1000, s.String())
+	}
 	if c.logger != nil {
 		c.logger(s)
 	}
+	if c.onQuery != nil {
+		c.onQuery(s.crud, s.Raw(), s.TimeElapse(), err)
+	}
 }
 
 func (c *Client) compileStmt(query string, args ...interface{}) *Stmt {
@@ -89,69 +150,174 @@ func (c *Client) compileStmt(query string, args ...interface{}) *Stmt {
 			statement: buf,
 			arguments: args,
 		},
+		crud:     crudOf(query),
 		replacer: c.dialect,
 	}
 	return ss
 }
 
-func (c Client) execStmt(s *stmt) error {
-	ss := &Stmt{
-		stmt:     *s,
-		replacer: c.dialect,
+// TimeoutError : the query is cancelled because it exceeds the `Timeout` of the query,
+// the `Err` is always `context.DeadlineExceeded`
+type TimeoutError struct {
+	Timeout time.Duration
+	Err     error
+}
+
+// Error :
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("goloquent: query exceeds the timeout %v, %v", e.Timeout, e.Err)
+}
+
+// Unwrap :
+func (e *TimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// wrapError will prefix the error, the timeout error is returned as it is so it's distinguishable
+func wrapError(err error) error {
+	if _, isOk := err.(*TimeoutError); isOk {
+		return err
+	}
+	if err == context.DeadlineExceeded {
+		return &TimeoutError{Err: err}
+	}
+	return fmt.Errorf("goloquent: %w", err)
+}
+
+// context will return the context of the statement execution, it has a deadline when the client has timeout
+func (c Client) context() (context.Context, context.CancelFunc) {
+	if c.timeout > 0 {
+		return context.WithTimeout(context.Background(), c.timeout)
+	}
+	return context.WithCancel(context.Background())
+}
+
+// timeoutError will replace the error with `TimeoutError` when the context is exceeded the deadline
+func (c Client) timeoutError(ctx context.Context, err error) error {
+	if err != nil && ctx.Err() == context.DeadlineExceeded {
+		return &TimeoutError{Timeout: c.timeout, Err: ctx.Err()}
 	}
+	return err
+}
+
+func (c Client) execStmt(s *stmt) error {
+	_, err := c.execResult(s)
+	return err
+}
+
+// execResult is same as `execStmt`, but it will return the result of the statement
+func (c Client) execResult(s *stmt) (result sql.Result, err error) {
+	ss := c.newStmt(s)
 	ss.startTrace()
 	defer func() {
 		ss.stopTrace()
-		c.consoleLog(ss)
+		c.consoleLog(ss, err)
 	}()
-	result, err := c.PrepareExec(ss.Raw(), ss.arguments...)
+	ctx, cancel := c.context()
+	defer cancel()
+	result, err = c.prepareExec(ctx, ss.Raw(), ss.arguments...)
 	if err != nil {
-		return err
+		return nil, c.timeoutError(ctx, err)
 	}
 	ss.Result = result
-	return nil
+	return result, nil
 }
 
-func (c Client) execQuery(s *stmt) (*sql.Rows, error) {
-	ss := &Stmt{
-		stmt:     *s,
-		replacer: c.dialect,
+// execAffected will return the number of rows affected by the statement
+func (c Client) execAffected(s *stmt) (int64, error) {
+	result, err := c.execResult(s)
+	if err != nil {
+		return 0, err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("goloquent: %w", err)
 	}
+	return n, nil
+}
+
+// execQuery will return the rows and the cancel func of the query context,
+// the cancel func must be called after the rows are closed
+func (c Client) execQuery(s *stmt) (rows *sql.Rows, cancel context.CancelFunc, err error) {
+	ss := c.newStmt(s)
 	ss.startTrace()
 	defer func() {
 		ss.stopTrace()
-		c.consoleLog(ss)
+		c.consoleLog(ss, err)
 	}()
-	var rows, err = c.Query(ss.Raw(), ss.arguments...)
+	ctx, cancel := c.context()
+	rows, err = c.sqlCommon.QueryContext(ctx, ss.Raw(), ss.arguments...)
 	if err != nil {
-		return nil, err
+		defer cancel()
+		return nil, nil, c.timeoutError(ctx, err)
 	}
-	return rows, nil
+	return rows, cancel, nil
 }
 
-func (c *Client) execQueryRow(s *stmt) *sql.Row {
-	ss := &Stmt{
-		stmt:     *s,
-		replacer: c.dialect,
-	}
+// execQueryRow will scan the first row into the `dest`
+func (c *Client) execQueryRow(s *stmt, dest ...interface{}) (err error) {
+	ss := c.newStmt(s)
 	ss.startTrace()
 	defer func() {
 		ss.stopTrace()
-		c.consoleLog(ss)
+		c.consoleLog(ss, err)
 	}()
-	return c.QueryRow(ss.Raw(), ss.arguments...)
+	ctx, cancel := c.context()
+	defer cancel()
+	if err = c.sqlCommon.QueryRowContext(ctx, ss.Raw(), ss.arguments...).Scan(dest...); err != nil {
+		return c.timeoutError(ctx, err)
+	}
+	return nil
 }
 
-// PrepareExec :
+// PrepareExec : the prepared statement will be reused from the cache if there is any
 func (c Client) PrepareExec(query string, args ...interface{}) (sql.Result, error) {
-	conn, err := c.sqlCommon.Prepare(query)
+	return c.prepareExec(context.Background(), query, args...)
+}
+
+func (c Client) prepareExec(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	if c.stmts != nil && c.stmts.enabled() {
+		return c.cachedExec(ctx, query, args...)
+	}
+	conn, err := c.sqlCommon.PrepareContext(ctx, query)
 	if err != nil {
-		return nil, fmt.Errorf("goloquent: unable to prepare sql statement : %v", err)
+		return nil, fmt.Errorf("goloquent: unable to prepare sql statement : %w", err)
 	}
 	defer conn.Close()
-	result, err := conn.Exec(args...)
+	result, err := conn.ExecContext(ctx, args...)
 	if err != nil {
-		return nil, fmt.Errorf("goloquent: %v", err)
+		return nil, fmt.Errorf("goloquent: %w", err)
+	}
+	return result, nil
+}
+
+func (c Client) cachedExec(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	var conn *sql.Stmt
+	switch vi := c.sqlCommon.(type) {
+	case *sql.Tx:
+		cs, err := c.stmts.acquire(query)
+		if err != nil {
+			return nil, fmt.Errorf("goloquent: unable to prepare sql statement : %w", err)
+		}
+		defer c.stmts.release(cs)
+		// the transaction specific statement will be closed when the transaction end
+		conn = vi.StmtContext(ctx, cs.stmt)
+		defer conn.Close()
+	default:
+		if c.sqlCommon != c.stmts.conn {
+			c.stmts = nil
+			return c.prepareExec(ctx, query, args...)
+		}
+		cs, err := c.stmts.acquire(query)
+		if err != nil {
+			return nil, fmt.Errorf("goloquent: unable to prepare sql statement : %w", err)
+		}
+		defer c.stmts.release(cs)
+		conn = cs.stmt
+	}
+	result, err := conn.ExecContext(ctx, args...)
+	if err != nil {
+		return nil, fmt.Errorf("goloquent: %w", err)
 	}
 	return result, nil
 }
@@ -160,7 +326,7 @@ func (c Client) PrepareExec(query string, args ...interface{}) (sql.Result, erro
 func (c Client) Exec(query string, args ...interface{}) (sql.Result, error) {
 	result, err := c.sqlCommon.Exec(query, args...)
 	if err != nil {
-		return nil, fmt.Errorf("goloquent: %v", err)
+		return nil, fmt.Errorf("goloquent: %w", err)
 	}
 	return result, nil
 }
@@ -169,7 +335,7 @@ func (c Client) Exec(query string, args ...interface{}) (sql.Result, error) {
 func (c Client) Query(query string, args ...interface{}) (*sql.Rows, error) {
 	rows, err := c.sqlCommon.Query(query, args...)
 	if err != nil {
-		return nil, fmt.Errorf("goloquent: %v", err)
+		return nil, fmt.Errorf("goloquent: %w", err)
 	}
 	return rows, nil
 }
@@ -188,36 +354,106 @@ type DB struct {
 	client  Client
 	dialect Dialect
 	omits   []string
+	// readers are the replica clients for read queries, they will be picked in round-robin
+	readers      []Client
+	next         *uint32
+	batchSize    int
+	noTimestamps bool
+	// noDrop will keep the columns and indexes which are not in the model on migration
+	noDrop bool
+	// txDepth is the nesting level of the savepoint within transaction
+	txDepth int
+	// columns caches the columns of the tables
+	columns *columnCache
+}
+
+// columnCache caches the columns of the tables, such as looking for the soft delete or `UpdatedAt` column,
+// so the table is not looked up on every query. The table is looked up again after it's migrated or dropped
+// through the connection.
+type columnCache struct {
+	sync.RWMutex
+	tables map[string][]string
+}
+
+func newColumnCache() *columnCache {
+	return &columnCache{tables: make(map[string][]string)}
+}
+
+// get will return false on the nil cache, so the connection without cache always looks up the table
+func (c *columnCache) get(table string) (columns []string, isOk bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.RLock()
+	defer c.RUnlock()
+	columns, isOk = c.tables[table]
+	return
+}
+
+func (c *columnCache) set(table string, columns []string) {
+	if c == nil {
+		return
+	}
+	c.Lock()
+	defer c.Unlock()
+	c.tables[table] = columns
 }
 
-// NewDB :
-func NewDB(driver string, charset CharSet, conn sqlCommon, dialect Dialect, logHandler LogHandler) *DB {
+// reset will remove the tables from the cache, or all the tables when nothing is specified
+func (c *columnCache) reset(tables ...string) {
+	if c == nil {
+		return
+	}
+	c.Lock()
+	defer c.Unlock()
+	if len(tables) <= 0 {
+		c.tables = make(map[string][]string)
+		return
+	}
+	for _, t := range tables {
+		delete(c.tables, t)
+	}
+}
+
+// NewDB : the read queries will be routed to the `replicas` if there is any
+func NewDB(driver string, charset CharSet, conn sqlCommon, dialect Dialect, logHandler LogHandler, replicas ...*sql.DB) *DB {
 	client := Client{
 		driver:    driver,
 		sqlCommon: conn,
 		CharSet:   charset,
 		dialect:   dialect,
 		logger:    logHandler,
+		stmts:     newStmtCache(conn, defaultStmtCacheSize),
 	}
 	dialect.SetDB(client)
-	return &DB{
+	db := &DB{
 		id:      fmt.Sprintf("%s:%d", driver, time.Now().UnixNano()),
 		driver:  driver,
 		name:    dialect.CurrentDB(),
 		client:  client,
 		dialect: dialect,
+		columns: newColumnCache(),
 	}
+	db.SetReplica(replicas...)
+	return db
 }
 
 // clone a new connection
 func (db *DB) clone() *DB {
 	return &DB{
-		id:      db.id,
-		driver:  db.driver,
-		name:    db.name,
-		replica: fmt.Sprintf("%d", time.Now().Unix()),
-		client:  db.client,
-		dialect: db.dialect,
+		id:           db.id,
+		driver:       db.driver,
+		name:         db.name,
+		replica:      fmt.Sprintf("%d", time.Now().Unix()),
+		client:       db.client,
+		dialect:      db.dialect,
+		readers:      db.readers,
+		next:         db.next,
+		batchSize:    db.batchSize,
+		noTimestamps: db.noTimestamps,
+		noDrop:       db.noDrop,
+		txDepth:      db.txDepth,
+		columns:      db.columns,
 	}
 }
 
@@ -231,6 +467,77 @@ func (db DB) Name() string {
 	return db.name
 }
 
+// SetReplica : route the read queries to the replica connections in round-robin, the primary connection
+// will be used when it's within transaction or the query is `UsePrimary`. Call without connection to remove the replicas.
+func (db *DB) SetReplica(conns ...*sql.DB) {
+	readers := make([]Client, 0, len(conns))
+	for _, conn := range conns {
+		if conn == nil {
+			continue
+		}
+		client := db.client
+		client.sqlCommon = conn
+		client.stmts = nil
+		readers = append(readers, client)
+	}
+	if len(readers) == 0 {
+		readers = nil
+	}
+	db.readers = readers
+	db.next = new(uint32)
+}
+
+// SetDebug : print every statement with its arguments to the standard logger, it works along with the `Logger`
+func (db *DB) SetDebug(isDebug bool) {
+	db.client.debug = isDebug
+	db.dialect.SetDB(db.client)
+	for i := range db.readers {
+		db.readers[i].debug = isDebug
+	}
+}
+
+// SetOnQuery : set the handler which will be called after every statement is executed
+func (db *DB) SetOnQuery(handler QueryHandler) {
+	db.client.onQuery = handler
+	db.dialect.SetDB(db.client)
+	for i := range db.readers {
+		db.readers[i].onQuery = handler
+	}
+}
+
+// SetKeyColumn : set the primary key column of the tables, default is `$Key`,
+// the model can still declare its own primary key column using `PrimaryKeyer`
+func (db *DB) SetKeyColumn(name string) {
+	db.client.pk = strings.TrimSpace(name)
+	db.dialect.SetDB(db.client)
+	for i := range db.readers {
+		db.readers[i].pk = db.client.pk
+	}
+}
+
+// SetDeletedColumn : set the soft delete column of the tables, default is `$Deleted`
+func (db *DB) SetDeletedColumn(name string) {
+	db.client.deleted = strings.TrimSpace(name)
+	db.dialect.SetDB(db.client)
+	for i := range db.readers {
+		db.readers[i].deleted = db.client.deleted
+	}
+}
+
+// SetBatchSize : set the maximum number of records per insert statement, default is 500.
+// The batches will be inserted within a transaction so the operation stays atomic.
+func (db *DB) SetBatchSize(size int) {
+	db.batchSize = size
+}
+
+// SetStmtCacheSize : set the maximum number of prepared statements to be cached, default is 100.
+// Set zero to disable the cache, the statement will be prepared and closed on every execution.
+func (db *DB) SetStmtCacheSize(size int) {
+	if db.client.stmts != nil {
+		db.client.stmts.resize(size)
+	}
+}
+
 // NewQuery :
 func (db *DB) NewQuery() *Query {
 	return newQuery(db)
@@ -251,23 +558,72 @@ func (db *DB) Table(name string) *Table {
 	return &Table{name, db}
 }
 
+// DropColumn : drop the column of the table if it's exists
+func (db *DB) DropColumn(table, column string) error {
+	return db.Table(table).DropColumn(column)
+}
+
+// RenameColumn : rename the column of the table, it does nothing when the column is renamed already
+func (db *DB) RenameColumn(table, from, to string) error {
+	return db.Table(table).RenameColumn(from, to)
+}
+
+// AddUniqueIndex : create the unique index across the fields of the model if it's not exists
+func (db *DB) AddUniqueIndex(model interface{}, fields ...string) error {
+	b := newBuilder(db.NewQuery())
+	e, err := b.newEntity(model)
+	if err != nil {
+		return err
+	}
+	return b.addUniqueIndex(e, fields)
+}
+
 // Migrate :
 func (db *DB) Migrate(model ...interface{}) error {
 	return newBuilder(db.NewQuery()).migrateMultiple(model)
 }
 
+// MigrateDryRun : same as `Migrate`, but the statements are returned instead of executed, so it can be reviewed first
+func (db *DB) MigrateDryRun(model ...interface{}) ([]string, error) {
+	return newBuilder(db.NewQuery()).migrateDryRun(model)
+}
+
 // Omit :
 func (db *DB) Omit(fields ...string) Replacer {
 	ff := newDictionary(fields)
 	clone := db.clone()
 	ff.delete(keyFieldName)
 	ff.delete(pkColumn)
+	ff.delete(db.client.keyColumn())
 	clone.omits = ff.keys()
 	return clone
 }
 
+// WithoutTimestamps : `CreatedAt` and `UpdatedAt` will not be maintained automatically
+func (db *DB) WithoutTimestamps() *DB {
+	clone := db.clone()
+	clone.noTimestamps = true
+	return clone
+}
+
+// WithoutDrop : `Migrate` will keep the columns and indexes which are not in the model instead of dropping them,
+// so the migration of an old deployment will not drop the columns added by the new one
+func (db *DB) WithoutDrop() *DB {
+	clone := db.clone()
+	clone.noDrop = true
+	return clone
+}
+
 // Create :
 func (db *DB) Create(model interface{}, parentKey ...*datastore.Key) error {
+	_, err := db.CreateWithResult(model, parentKey...)
+	return err
+}
+
+// CreateWithResult : same as `Create`, but it will return the result of the insert statement,
+// `LastInsertId` is the id generated for the first record when the table has an auto increment column,
+// it's only supported by the driver of mysql and sqlite, postgres has no `LastInsertId`
+func (db *DB) CreateWithResult(model interface{}, parentKey ...*datastore.Key) (sql.Result, error) {
 	if parentKey == nil {
 		return newBuilder(db.NewQuery()).put(model, nil)
 	}
@@ -282,58 +638,122 @@ func (db *DB) Upsert(model interface{}, parentKey ...*datastore.Key) error {
 	return newBuilder(db.NewQuery().Omit(db.omits...)).upsert(model, parentKey)
 }
 
-// Save :
+// UpsertOn : same as `Upsert`, but the records are updated when they conflict on the `keys`,
+// it's only effective on postgres, mysql always use the primary key or unique index
+func (db *DB) UpsertOn(keys []string, model interface{}) error {
+	return newBuilder(db.NewQuery().Omit(db.omits...)).upsert(model, nil, keys...)
+}
+
+// Save : the model can be a pointer of slice, every element will be updated within a transaction
 func (db *DB) Save(model interface{}) error {
-	if err := checkSinglePtr(model); err != nil {
-		return err
+	if v := reflect.ValueOf(model); v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Slice {
+		if err := checkSinglePtr(model); err != nil {
+			return err
+		}
 	}
 	return newBuilder(db.NewQuery().Omit(db.omits...)).save(model)
 }
 
 // Delete :
 func (db *DB) Delete(model interface{}) error {
+	_, err := db.DeleteCount(model)
+	return err
+}
+
+// DeleteCount : same as `Delete`, but it will return the number of affected records
+func (db *DB) DeleteCount(model interface{}) (int64, error) {
 	return newBuilder(db.NewQuery()).delete(model, true)
 }
 
 // Destroy :
 func (db *DB) Destroy(model interface{}) error {
+	_, err := db.DestroyCount(model)
+	return err
+}
+
+// DestroyCount : same as `Destroy`, but it will return the number of deleted records
+func (db *DB) DestroyCount(model interface{}) (int64, error) {
 	return newBuilder(db.NewQuery()).delete(model, false)
 }
 
+// Restore : restore the soft deleted entity, the entity can be either a pointer of struct or slice
+func (db *DB) Restore(model interface{}) error {
+	return newBuilder(db.NewQuery()).restore(model)
+}
+
 // Truncate :
 func (db *DB) Truncate(model ...interface{}) error {
 	ns := make([]string, 0, len(model))
 	for _, m := range model {
-		var table string
-		v := reflect.Indirect(reflect.ValueOf(m))
-		switch v.Type().Kind() {
-		case reflect.String:
-			table = v.String()
-		case reflect.Struct:
-			table = v.Type().Name()
-		default:
-			return errors.New("goloquent: unsupported model")
-		}
-
-		table = strings.TrimSpace(table)
-		if table == "" {
-			return errors.New("goloquent: missing table name")
+		table, err := modelTable(m)
+		if err != nil {
+			return err
 		}
 		ns = append(ns, table)
 	}
 	return newBuilder(db.NewQuery()).truncate(ns...)
 }
 
+// Count : count the records of the model, the soft deleted records are excluded,
+// the model can be either the table name or the struct
+func (db *DB) Count(model interface{}) (int64, error) {
+	table, err := modelTable(model)
+	if err != nil {
+		return 0, err
+	}
+	q := db.NewQuery()
+	q.table = table
+	return q.Count()
+}
+
+// modelTable will return the table name of the model, it's the type name or the `TableName` when the model is a struct
+func modelTable(model interface{}) (string, error) {
+	var table string
+	v := reflect.Indirect(reflect.ValueOf(model))
+	if !v.IsValid() {
+		return "", errors.New("goloquent: unsupported model")
+	}
+	switch v.Type().Kind() {
+	case reflect.String:
+		table = v.String()
+	case reflect.Struct:
+		table = tableName(v.Type())
+	default:
+		return "", errors.New("goloquent: unsupported model")
+	}
+
+	table = strings.TrimSpace(table)
+	if table == "" {
+		return "", errors.New("goloquent: missing table name")
+	}
+	return table, nil
+}
+
 // Select :
 func (db *DB) Select(fields ...string) *Query {
 	return db.NewQuery().Select(fields...)
 }
 
+// WithTrashed :
+func (db *DB) WithTrashed() *Query {
+	return db.NewQuery().WithTrashed()
+}
+
+// OnlyTrashed :
+func (db *DB) OnlyTrashed() *Query {
+	return db.NewQuery().OnlyTrashed()
+}
+
 // Find :
 func (db *DB) Find(key *datastore.Key, model interface{}) error {
 	return db.NewQuery().Find(key, model)
 }
 
+// FindMulti :
+func (db *DB) FindMulti(keys []*datastore.Key, model interface{}) error {
+	return db.NewQuery().FindMulti(keys, model)
+}
+
 // First :
 func (db *DB) First(model interface{}) error {
 	return db.NewQuery().First(model)
@@ -369,8 +789,33 @@ func (db *DB) RunInTransaction(cb TransactionHandler) error {
 	return newBuilder(db.NewQuery()).runInTransaction(cb)
 }
 
+// RunInTransactionWith : same as `RunInTransaction`, but the transaction is started with the options,
+// such as the isolation level. See `SupportIsolationLevel` of the dialect for the supported isolation levels.
+func (db *DB) RunInTransactionWith(opts *sql.TxOptions, cb TransactionHandler) error {
+	return newBuilder(db.NewQuery()).runInTransactionWith(context.Background(), opts, cb)
+}
+
+// RunInTransactionWithOptions : alias of `RunInTransactionWith`
+func (db *DB) RunInTransactionWithOptions(opts *sql.TxOptions, cb TransactionHandler) error {
+	return db.RunInTransactionWith(opts, cb)
+}
+
+// RunInTransactionRetry : same as `RunInTransaction`, but the transaction will be retried up to `attempts` times
+// when it's failed with retryable error, such as deadlock or serialization failure. It's not retried within transaction.
+func (db *DB) RunInTransactionRetry(attempts int, cb TransactionHandler) error {
+	return newBuilder(db.NewQuery()).runInTransactionRetry(attempts, cb)
+}
+
 // Close :
 func (db *DB) Close() error {
+	if db.client.stmts != nil {
+		db.client.stmts.close()
+	}
+	for _, r := range db.readers {
+		if x, isOk := r.sqlCommon.(*sql.DB); isOk {
+			x.Close()
+		}
+	}
 	x, isOk := db.client.sqlCommon.(*sql.DB)
 	if !isOk {
 		return nil
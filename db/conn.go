@@ -1,9 +1,11 @@
 package db
 
 import (
+	"database/sql"
 	"fmt"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/si3nloong/goloquent"
 )
@@ -23,6 +25,22 @@ type Config struct {
 	UnixSocket string
 	CharSet    *goloquent.CharSet
 	Logger     goloquent.LogHandler
+	// OnQuery will be called after every statement is executed, such as collecting the metrics
+	OnQuery goloquent.QueryHandler
+	// IsDebug will print every statement with its arguments to the standard logger
+	IsDebug bool
+	// MaxOpenConns is the maximum number of open connections, zero means unlimited
+	MaxOpenConns int
+	// MaxIdleConns is the maximum number of idle connections, zero will keep the driver default
+	MaxIdleConns int
+	// ConnMaxLifetime is the maximum amount of time a connection may be reused, zero means forever
+	ConnMaxLifetime time.Duration
+	// KeyColumn is the primary key column of the tables, default is `$Key`
+	KeyColumn string
+	// DeletedColumn is the soft delete column of the tables, default is `$Deleted`
+	DeletedColumn string
+	// Replicas are the read replica connections, read queries will be routed to them in round-robin
+	Replicas []Config
 }
 
 // Open :
@@ -36,6 +54,38 @@ func Open(driver string, conf Config) (*goloquent.DB, error) {
 	if p, isOk := connPool.Load(driver); isOk {
 		pool = p.(map[string]*goloquent.DB)
 	}
+	config := newConfig(conf)
+	conn, err := connect(driver, dialect, config)
+	if err != nil {
+		return nil, err
+	}
+	replicas := make([]*sql.DB, 0, len(conf.Replicas))
+	for _, rc := range conf.Replicas {
+		// the replica is opened with its own dialect, so the version detected is kept per connection
+		rd, _ := goloquent.GetDialect(driver)
+		replica, err := connect(driver, rd, newConfig(rc))
+		if err != nil {
+			conn.Close()
+			for _, r := range replicas {
+				r.Close()
+			}
+			return nil, err
+		}
+		replicas = append(replicas, replica)
+	}
+	db := goloquent.NewDB(driver, *config.CharSet, conn, dialect, conf.Logger, replicas...)
+	db.SetDebug(config.IsDebug)
+	db.SetOnQuery(config.OnQuery)
+	db.SetKeyColumn(config.KeyColumn)
+	db.SetDeletedColumn(config.DeletedColumn)
+	pool[conf.Database] = db
+	connPool.Store(driver, pool)
+	// Override defaultDB wheneve initialise a new connection
+	defaultDB = db
+	return db, nil
+}
+
+func newConfig(conf Config) goloquent.Config {
 	config := goloquent.Config{
 		Username:   conf.Username,
 		Password:   conf.Password,
@@ -45,19 +95,34 @@ func Open(driver string, conf Config) (*goloquent.DB, error) {
 		UnixSocket: conf.UnixSocket,
 		CharSet:    conf.CharSet,
 		Logger:     conf.Logger,
+		OnQuery:    conf.OnQuery,
+		IsDebug:    conf.IsDebug,
+
+		KeyColumn:     conf.KeyColumn,
+		DeletedColumn: conf.DeletedColumn,
+
+		MaxOpenConns:    conf.MaxOpenConns,
+		MaxIdleConns:    conf.MaxIdleConns,
+		ConnMaxLifetime: conf.ConnMaxLifetime,
 	}
 	config.Normalize()
+	return config
+}
+
+// connect will open the connection with the pool settings and make sure the server is reachable
+func connect(driver string, dialect goloquent.Dialect, config goloquent.Config) (*sql.DB, error) {
 	conn, err := dialect.Open(config)
 	if err != nil {
 		return nil, err
 	}
+	conn.SetMaxOpenConns(config.MaxOpenConns)
+	if config.MaxIdleConns > 0 {
+		conn.SetMaxIdleConns(config.MaxIdleConns)
+	}
+	conn.SetConnMaxLifetime(config.ConnMaxLifetime)
 	if err := conn.Ping(); err != nil {
+		conn.Close()
 		return nil, fmt.Errorf("goloquent: %s server has not response", driver)
 	}
-	db := goloquent.NewDB(driver, *config.CharSet, conn, dialect, conf.Logger)
-	pool[conf.Database] = db
-	connPool.Store(driver, pool)
-	// Override defaultDB wheneve initialise a new connection
-	defaultDB = db
-	return db, nil
+	return conn, nil
 }
@@ -36,11 +36,26 @@ func Table(name string) *goloquent.Table {
 	return defaultDB.Table(name)
 }
 
+// AddUniqueIndex :
+func AddUniqueIndex(model interface{}, fields ...string) error {
+	return defaultDB.AddUniqueIndex(model, fields...)
+}
+
 // Migrate :
 func Migrate(model ...interface{}) error {
 	return defaultDB.Migrate(model...)
 }
 
+// WithoutDrop :
+func WithoutDrop() *goloquent.DB {
+	return defaultDB.WithoutDrop()
+}
+
+// MigrateDryRun :
+func MigrateDryRun(model ...interface{}) ([]string, error) {
+	return defaultDB.MigrateDryRun(model...)
+}
+
 // Omit :
 func Omit(fields ...string) goloquent.Replacer {
 	return defaultDB.Omit(fields...)
@@ -54,6 +69,14 @@ func Create(model interface{}, parentKey ...*datastore.Key) error {
 	return defaultDB.Create(model, parentKey...)
 }
 
+// CreateWithResult :
+func CreateWithResult(model interface{}, parentKey ...*datastore.Key) (sql.Result, error) {
+	if parentKey == nil {
+		return defaultDB.CreateWithResult(model)
+	}
+	return defaultDB.CreateWithResult(model, parentKey...)
+}
+
 // Upsert :
 func Upsert(model interface{}, parentKey ...*datastore.Key) error {
 	if parentKey == nil {
@@ -62,16 +85,36 @@ func Upsert(model interface{}, parentKey ...*datastore.Key) error {
 	return defaultDB.Upsert(model, parentKey...)
 }
 
+// UpsertOn :
+func UpsertOn(keys []string, model interface{}) error {
+	return defaultDB.UpsertOn(keys, model)
+}
+
 // Delete :
 func Delete(model interface{}) error {
 	return defaultDB.Delete(model)
 }
 
+// DeleteCount :
+func DeleteCount(model interface{}) (int64, error) {
+	return defaultDB.DeleteCount(model)
+}
+
 // Destroy :
 func Destroy(model interface{}) error {
 	return defaultDB.Destroy(model)
 }
 
+// DestroyCount :
+func DestroyCount(model interface{}) (int64, error) {
+	return defaultDB.DestroyCount(model)
+}
+
+// Restore :
+func Restore(model interface{}) error {
+	return defaultDB.Restore(model)
+}
+
 // Save :
 func Save(model interface{}) error {
 	return defaultDB.Save(model)
@@ -82,6 +125,11 @@ func Find(key *datastore.Key, model interface{}) error {
 	return defaultDB.Find(key, model)
 }
 
+// FindMulti :
+func FindMulti(keys []*datastore.Key, model interface{}) error {
+	return defaultDB.FindMulti(keys, model)
+}
+
 // First :
 func First(model interface{}) error {
 	return defaultDB.First(model)
@@ -122,6 +170,16 @@ func Unscoped() *goloquent.Query {
 	return defaultDB.NewQuery().Unscoped()
 }
 
+// WithTrashed :
+func WithTrashed() *goloquent.Query {
+	return defaultDB.WithTrashed()
+}
+
+// OnlyTrashed :
+func OnlyTrashed() *goloquent.Query {
+	return defaultDB.OnlyTrashed()
+}
+
 // DistinctOn :
 func DistinctOn(fields ...string) *goloquent.Query {
 	return defaultDB.NewQuery().DistinctOn(fields...)
@@ -177,7 +235,37 @@ func RunInTransaction(cb goloquent.TransactionHandler) error {
 	return defaultDB.RunInTransaction(cb)
 }
 
+// RunInTransactionRetry :
+func RunInTransactionRetry(attempts int, cb goloquent.TransactionHandler) error {
+	return defaultDB.RunInTransactionRetry(attempts, cb)
+}
+
+// RunInTransactionWith :
+func RunInTransactionWith(opts *sql.TxOptions, cb goloquent.TransactionHandler) error {
+	return defaultDB.RunInTransactionWith(opts, cb)
+}
+
+// RunInTransactionWithOptions :
+func RunInTransactionWithOptions(opts *sql.TxOptions, cb goloquent.TransactionHandler) error {
+	return defaultDB.RunInTransactionWithOptions(opts, cb)
+}
+
 // Truncate :
 func Truncate(model ...interface{}) error {
 	return defaultDB.Truncate(model...)
 }
+
+// DropColumn :
+func DropColumn(table, column string) error {
+	return defaultDB.DropColumn(table, column)
+}
+
+// RenameColumn :
+func RenameColumn(table, from, to string) error {
+	return defaultDB.RenameColumn(table, from, to)
+}
+
+// Count :
+func Count(model interface{}) (int64, error) {
+	return defaultDB.Count(model)
+}
@@ -17,18 +17,30 @@ type Dialect interface {
 	Bind(i uint) string
 	FilterJSON(f Filter) (s string, args []interface{}, err error)
 	JSONMarshal(i interface{}) (b json.RawMessage)
+	SetJSON(name string, paths []string) string
+	FilterMatch(names []string) (string, error)
 	Value(v interface{}) string
+	EscapeLike(v string) string
 	GetSchema(c Column) []Schema
 	DataType(s Schema) string
 	HasTable(tb string) bool
 	HasIndex(tb, idx string) bool
+	HasColumn(tb, col string) (bool, error)
 	GetColumns(tb string) (cols []string)
+	GetColumnTypes(tb string) (types map[string]string)
 	GetIndexes(tb string) (idxs []string)
-	CreateTable(tb string, cols []Column) error
-	AlterTable(tb string, cols []Column) error
-	OnConflictUpdate(tb string, cols []string) string
+	CreateTable(tb string, cols []Column) (stmts []string, err error)
+	AlterTable(tb string, cols []Column, allowDrop bool) (stmts []string, err error)
+	DropIndex(tb, idx string) (stmt string)
+	RenameColumn(tb, from, to string) (stmt string, err error)
+	OnConflictUpdate(tb string, keys, cols []string) string
 	UpdateWithLimit() bool
-	ReplaceInto(src, dst string) error
+	IsDuplicate(err error) bool
+	LockClause(mode locked, opt lockOption) (string, error)
+	UnionSelect(s string) string
+	SupportIsolationLevel(level sql.IsolationLevel) bool
+	IsRetryable(err error) bool
+	ReplaceInto(tb string, cols []string, query string) (stmt string)
 }
 
 var (
@@ -3,20 +3,39 @@ package goloquent
 import (
 	"bytes"
 	"database/sql"
+	"encoding/json"
 	"fmt"
-	"log"
-	"reflect"
 	"regexp"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 )
 
 type mysql struct {
 	sequel
+	// version is the version of the server detected on `Open`, it's empty when the server is unreachable
+	version string
 }
 
 const minVersion = "5.7"
 
+// lockOptionVersion is the minimum version of mysql (or mariadb) which supports `SKIP LOCKED` and `NOWAIT`
+const (
+	lockOptionVersion        = "8.0"
+	mariaDBLockOptionVersion = "10.6"
+)
+
+// jsonDefaultVersion is the minimum version of mysql which supports the expression default on json column
+const jsonDefaultVersion = "8.0.13"
+
+var (
+	versionRegexp     = regexp.MustCompile(`\d+\.\d+`)
+	fullVersionRegexp = regexp.MustCompile(`\d+(\.\d+)+`)
+)
+
+var jsonLiteralReplacer = strings.NewReplacer(`\`, `\\`, `'`, `''`)
+
 var _ Dialect = new(mysql)
 
 func init() {
@@ -43,22 +62,55 @@ func (s *mysql) Open(conf Config) (*sql.DB, error) {
 	buf.WriteString(fmt.Sprintf("/%s", conf.Database))
 	buf.WriteString("?parseTime=true")
 	buf.WriteString("&charset=utf8mb4&collation=utf8mb4_unicode_ci")
-	log.Println("Connection String :", buf.String())
 	client, err := sql.Open("mysql", buf.String())
 	if err != nil {
 		return nil, err
 	}
+	// the version is only verified when the server is reachable, the connection is checked by the caller
+	var version string
+	if err := client.QueryRow("SELECT VERSION();").Scan(&version); err == nil {
+		if err := checkVersion(version); err != nil {
+			client.Close()
+			return nil, err
+		}
+	}
+	// the version is overwritten on every `Open`, so it's never the version of the previous connection
+	s.version = version
 	return client, nil
 }
 
+// LockClause : `SKIP LOCKED` and `NOWAIT` require MySQL 8.0 or MariaDB 10.6,
+// the version is only verified when it's detected on `Open`
+func (s mysql) LockClause(mode locked, opt lockOption) (string, error) {
+	if opt > 0 && s.version != "" {
+		min := lockOptionVersion
+		if strings.Contains(strings.ToLower(s.version), "mariadb") {
+			min = mariaDBLockOptionVersion
+		}
+		// `compareVersion` returns 1 when the server version is lower than the minimum version
+		if compareVersion(versionRegexp.FindString(s.version), min) > 0 {
+			return "", fmt.Errorf("goloquent: lock option requires at least %s version of mysql, but the server is %s", min, s.version)
+		}
+	}
+	return s.sequel.LockClause(mode, opt)
+}
+
+// checkVersion will return error when the version of the server is lower than `minVersion`
+func checkVersion(version string) error {
+	found := versionRegexp.FindString(version)
+	if found == "" {
+		return fmt.Errorf("goloquent: unable to detect the version of mysql from %q", version)
+	}
+	// `compareVersion` returns 1 when the found version is lower than the minimum version
+	if compareVersion(found, minVersion) > 0 {
+		return fmt.Errorf("goloquent: require at least %s version of mysql, but the server is %s", minVersion, version)
+	}
+	return nil
+}
+
 // Version :
 func (s mysql) Version() (version string) {
-	verRgx := regexp.MustCompile(`(\d\.\d)`)
 	s.db.QueryRow("SELECT VERSION();").Scan(&version)
-	log.Println("MySQL version :", version)
-	if compareVersion(verRgx.FindStringSubmatch(version)[0], minVersion) > 0 {
-		panic(fmt.Errorf("require at least %s version of mysql", minVersion))
-	}
 	return
 }
 
@@ -75,7 +127,11 @@ func (s mysql) Bind(uint) string {
 // DataType :
 func (s mysql) DataType(sc Schema) string {
 	buf := new(bytes.Buffer)
-	buf.WriteString(sc.DataType)
+	if len(sc.Enum) > 0 {
+		buf.WriteString(fmt.Sprintf("enum(%s)", sc.enumValues()))
+	} else {
+		buf.WriteString(sc.DataType)
+	}
 	if sc.IsUnsigned {
 		buf.WriteString(" UNSIGNED")
 	}
@@ -86,15 +142,40 @@ func (s mysql) DataType(sc Schema) string {
 	}
 	if !sc.IsNullable {
 		buf.WriteString(" NOT NULL")
-		t := reflect.TypeOf(sc.DefaultValue)
-		if t != reflect.TypeOf(OmitDefault(nil)) {
-			buf.WriteString(fmt.Sprintf(" DEFAULT %s", s.ToString(sc.DefaultValue)))
+		if !s.omitDefault(sc) {
+			buf.WriteString(fmt.Sprintf(" DEFAULT %s", sc.defaultValue(s.ToString)))
 		}
 	}
 	return buf.String()
 }
 
-func (s mysql) OnConflictUpdate(table string, cols []string) string {
+// omitDefault will omit the default of the json column when the server doesn't support the expression default,
+// the records are always inserted with all the columns, so the column doesn't rely on the default
+func (s mysql) omitDefault(sc Schema) bool {
+	if sc.IsOmitEmpty() {
+		return true
+	}
+	switch sc.DefaultValue.(type) {
+	case json.RawMessage, []interface{}, map[string]interface{}:
+		return !s.supportJSONDefault()
+	}
+	return false
+}
+
+// supportJSONDefault will return true when the version is not detected, mariadb doesn't support `CAST AS JSON`
+func (s mysql) supportJSONDefault() bool {
+	if s.version == "" {
+		return true
+	}
+	if strings.Contains(strings.ToLower(s.version), "mariadb") {
+		return false
+	}
+	// `compareVersion` returns 1 when the server version is lower than the minimum version
+	return compareVersion(fullVersionRegexp.FindString(s.version), jsonDefaultVersion) <= 0
+}
+
+// OnConflictUpdate : the conflict `keys` are ignored, it's always the primary key or unique index
+func (s mysql) OnConflictUpdate(table string, keys, cols []string) string {
 	buf := new(bytes.Buffer)
 	buf.WriteString("ON DUPLICATE KEY UPDATE ")
 	for _, c := range cols {
@@ -104,65 +185,172 @@ func (s mysql) OnConflictUpdate(table string, cols []string) string {
 	return buf.String()
 }
 
-func (s mysql) CreateTable(table string, columns []Column) error {
+// CreateTable : will return the statement to create the table, it's not executed
+func (s mysql) CreateTable(table string, columns []Column) ([]string, error) {
+	fks := make([]string, 0)
 	buf := new(bytes.Buffer)
 	buf.WriteString(fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (", s.GetTable(table)))
 	for _, c := range columns {
 		for _, ss := range s.GetSchema(c) {
 			buf.WriteString(fmt.Sprintf("%s %s,", s.Quote(ss.Name), s.DataType(ss)))
-			if ss.IsIndexed {
-				idx := fmt.Sprintf("%s_%s_%s", table, ss.Name, "Idx")
+			switch {
+			case ss.IsUnique:
+				idx := indexName(table, []string{ss.Name}, uniqueIdx)
+				buf.WriteString(fmt.Sprintf("UNIQUE INDEX %s (%s),", s.Quote(idx), s.Quote(ss.Name)))
+			case ss.IsIndexed:
+				idx := indexName(table, []string{ss.Name}, bTreeIdx)
 				buf.WriteString(fmt.Sprintf("INDEX %s (%s),", s.Quote(idx), s.Quote(ss.Name)))
 			}
+			if ss.IsFullText {
+				idx := fmt.Sprintf("%s_%s_%s", table, ss.Name, "fulltext")
+				buf.WriteString(fmt.Sprintf("FULLTEXT INDEX %s (%s),", s.Quote(idx), s.Quote(ss.Name)))
+			}
+			if ss.ForeignKey != nil {
+				fks = append(fks, ","+foreignKeyConstraint(&s, table, ss))
+			}
 		}
 	}
-	buf.WriteString(fmt.Sprintf("PRIMARY KEY (%s)", s.Quote(pkColumn)))
+	buf.WriteString(fmt.Sprintf("PRIMARY KEY (%s)", s.Quote(primaryKeyColumn(columns))))
+	buf.WriteString(strings.Join(fks, ""))
 	buf.WriteString(fmt.Sprintf(") ENGINE=InnoDB DEFAULT CHARSET=%s COLLATE=%s;",
 		s.Quote(s.db.CharSet.Encoding), s.Quote(s.db.CharSet.Collation)))
-	return s.db.execStmt(&stmt{statement: buf})
+	return []string{buf.String()}, nil
 }
 
-func (s *mysql) AlterTable(table string, columns []Column) error {
+// AlterTable : will return the statement to alter the table according to the existing columns and indexes,
+// the columns and indexes which are not in the model are dropped when `allowDrop` is true
+func (s *mysql) AlterTable(table string, columns []Column, allowDrop bool) ([]string, error) {
 	cols := newDictionary(s.GetColumns(table))
 	idxs := newDictionary(s.GetIndexes(table))
+	fks := newDictionary(s.foreignKeys(table))
+	return []string{s.alterTableStmt(table, columns, cols, idxs, fks, allowDrop).string()}, nil
+}
 
-	buf := new(bytes.Buffer)
-	buf.WriteString(fmt.Sprintf("ALTER TABLE %s ", s.GetTable(table)))
+// alterTableStmt will build the alter statement, the `cols`, `idxs` and `fks` are the existing columns,
+// indexes and foreign keys of the table, the foreign keys are never dropped, and the existing column
+// declared by the `oldname` of the tag is renamed instead of being dropped
+func (s *mysql) alterTableStmt(table string, columns []Column, cols, idxs, fks dictionary, allowDrop bool) *stmt {
+	specs := make([]string, 0)
 	suffix := "FIRST"
 	for _, c := range columns {
 		for _, ss := range s.GetSchema(c) {
-			action := "ADD"
+			action := "ADD " + s.Quote(ss.Name)
 			if cols.has(ss.Name) {
-				action = "MODIFY"
+				action = "MODIFY " + s.Quote(ss.Name)
+			} else if old := c.oldName(); old != "" && cols.has(old) {
+				// the renamed column is removed from `cols`, so it's never dropped
+				action = fmt.Sprintf("CHANGE COLUMN %s %s", s.Quote(old), s.Quote(ss.Name))
+				cols.delete(old)
 			}
-			buf.WriteString(fmt.Sprintf("%s %s %s %s,",
-				action, s.Quote(ss.Name), s.DataType(ss), suffix))
+			specs = append(specs, fmt.Sprintf("%s %s %s",
+				action, s.DataType(ss), suffix))
 			suffix = fmt.Sprintf("AFTER %s", s.Quote(ss.Name))
 
-			if ss.IsIndexed {
-				idx := fmt.Sprintf("%s_%s_%s", table, ss.Name, "idx")
+			switch {
+			case ss.IsUnique:
+				idx := indexName(table, []string{ss.Name}, uniqueIdx)
 				if idxs.has(idx) {
 					idxs.delete(idx)
 				} else {
-					buf.WriteString(fmt.Sprintf(" ADD INDEX %s (%s),",
+					specs = append(specs, fmt.Sprintf("ADD UNIQUE INDEX %s (%s)",
+						s.Quote(idx), s.Quote(ss.Name)))
+				}
+			case ss.IsIndexed:
+				idx := indexName(table, []string{ss.Name}, bTreeIdx)
+				if found, isOk := foldIndex(idxs, idx); isOk {
+					idxs.delete(found)
+				} else {
+					specs = append(specs, fmt.Sprintf("ADD INDEX %s (%s)",
 						s.Quote(idx), s.Quote(ss.Name)))
 				}
 			}
+			if ss.IsFullText {
+				idx := fmt.Sprintf("%s_%s_%s", table, ss.Name, "fulltext")
+				if idxs.has(idx) {
+					idxs.delete(idx)
+				} else {
+					specs = append(specs, fmt.Sprintf("ADD FULLTEXT INDEX %s (%s)",
+						s.Quote(idx), s.Quote(ss.Name)))
+				}
+			}
+			if ss.ForeignKey != nil {
+				fk := foreignKeyName(table, ss.Name)
+				// mysql creates the index of the foreign key using the constraint name when it's required
+				idxs.delete(fk)
+				if !fks.has(fk) {
+					specs = append(specs, "ADD "+foreignKeyConstraint(s, table, ss))
+				}
+			}
 			cols.delete(ss.Name)
 		}
 	}
 
-	for _, col := range cols.keys() {
-		buf.WriteString(fmt.Sprintf("DROP COLUMN %s,", s.Quote(col)))
+	if allowDrop {
+		drops := cols.keys()
+		sort.Strings(drops)
+		for _, col := range drops {
+			specs = append(specs, fmt.Sprintf("DROP COLUMN %s", s.Quote(col)))
+		}
+		drops = idxs.keys()
+		sort.Strings(drops)
+		for _, idx := range drops {
+			// the unique indexes are diffed by the builder, as the composite unique indexes are declared by the model
+			if strings.HasSuffix(idx, "_unique") {
+				continue
+			}
+			specs = append(specs, fmt.Sprintf("DROP INDEX %s", s.Quote(idx)))
+		}
+	}
+	specs = append(specs, fmt.Sprintf("CHARACTER SET %s COLLATE %s",
+		s.Quote(s.db.CharSet.Encoding), s.Quote(s.db.CharSet.Collation)))
+
+	buf := new(bytes.Buffer)
+	buf.WriteString(fmt.Sprintf("ALTER TABLE %s %s;", s.GetTable(table), strings.Join(specs, ", ")))
+	return &stmt{statement: buf}
+}
+
+// foldIndex will return the existing index of the same name, the index name of mysql is case insensitive,
+// so the index created as `<table>_<column>_Idx` by the former version is the same index
+func foldIndex(idxs dictionary, idx string) (string, bool) {
+	if idxs.has(idx) {
+		return idx, true
+	}
+	for k := range idxs {
+		if strings.EqualFold(k, idx) {
+			return k, true
+		}
+	}
+	return "", false
+}
+
+// DropIndex :
+func (s *mysql) DropIndex(table, idx string) string {
+	return fmt.Sprintf("DROP INDEX %s ON %s;", s.Quote(idx), s.GetTable(table))
+}
+
+// RenameColumn : `CHANGE COLUMN` with the existing definition of the column, as `RENAME COLUMN` requires MySQL 8.0
+func (s *mysql) RenameColumn(table, from, to string) (string, error) {
+	var name, ddl string
+	if err := s.db.QueryRow(fmt.Sprintf("SHOW CREATE TABLE %s;", s.GetTable(table))).Scan(&name, &ddl); err != nil {
+		return "", err
 	}
-	for _, idx := range idxs.keys() {
-		buf.WriteString(fmt.Sprintf("DROP INDEX %s,", s.Quote(idx)))
+	def := columnDefinition(ddl, s.Quote(from))
+	if def == "" {
+		return "", fmt.Errorf("goloquent: table %q has no column %q", table, from)
 	}
+	return fmt.Sprintf("ALTER TABLE %s CHANGE COLUMN %s %s %s;", s.GetTable(table), s.Quote(from), s.Quote(to), def), nil
+}
 
-	buf.WriteString(fmt.Sprintf("CHARACTER SET %s ", s.Quote(s.db.CharSet.Encoding)))
-	buf.WriteString(fmt.Sprintf("COLLATE %s", s.Quote(s.db.CharSet.Collation)))
-	buf.WriteString(";")
-	return s.db.execStmt(&stmt{statement: buf})
+// columnDefinition will return the definition of the quoted column from the `CREATE TABLE` statement,
+// such as `varchar(191) NOT NULL`, it's empty when the column is not found
+func columnDefinition(ddl, column string) string {
+	for _, line := range strings.Split(ddl, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, column+" ") {
+			return strings.TrimSuffix(strings.TrimPrefix(line, column+" "), ",")
+		}
+	}
+	return ""
 }
 
 func (s mysql) ToString(it interface{}) string {
@@ -182,11 +370,14 @@ func (s mysql) ToString(it interface{}) string {
 		v = strconv.FormatFloat(vi, 'f', -1, 64)
 	case time.Time:
 		v = fmt.Sprintf(`"%s"`, vi.Format("2006-01-02 15:04:05"))
-	// case json.RawMessage:
-	case []interface{}:
-		v = fmt.Sprintf(`"%s"`, "[]")
-	case map[string]interface{}:
-		v = fmt.Sprintf(`"%s"`, "{}")
+	case json.RawMessage:
+		v = s.jsonDefault(vi)
+	case []interface{}, map[string]interface{}:
+		b, err := json.Marshal(vi)
+		if err != nil {
+			b = []byte("null")
+		}
+		v = s.jsonDefault(b)
 	case nil:
 		v = "NULL"
 	default:
@@ -195,19 +386,28 @@ func (s mysql) ToString(it interface{}) string {
 	return v
 }
 
+// jsonDefault will render the json value as an expression default, which
+// requires mysql 8.0.13 and above, mysql 5.7 doesn't support default on json column
+func (s mysql) jsonDefault(b []byte) string {
+	if len(bytes.TrimSpace(b)) <= 0 {
+		b = []byte("null")
+	}
+	// mysql processes the backslash escapes in string literal, so the escapes of the json are kept
+	return fmt.Sprintf("(CAST('%s' AS JSON))", jsonLiteralReplacer.Replace(string(b)))
+}
+
+// IsRetryable : deadlock (1213) and lock wait timeout (1205) are retryable
+func (s mysql) IsRetryable(err error) bool {
+	code, isOk := mysqlErrorNumber(err)
+	return isOk && (code == 1213 || code == 1205)
+}
+
 func (s mysql) UpdateWithLimit() bool {
 	return true
 }
 
-func (s mysql) ReplaceInto(src, dst string) error {
-	src, dst = s.GetTable(src), s.GetTable(dst)
-	buf := new(bytes.Buffer)
-	buf.WriteString("REPLACE INTO ")
-	buf.WriteString(dst + " ")
-	buf.WriteString("SELECT * FROM ")
-	buf.WriteString(src)
-	buf.WriteString(";")
-	return s.db.execStmt(&stmt{
-		statement: buf,
-	})
+// IsDuplicate : duplicate entry (1062) is returned when the unique index is violated
+func (s mysql) IsDuplicate(err error) bool {
+	code, isOk := mysqlErrorNumber(err)
+	return isOk && code == 1062
 }
@@ -0,0 +1,333 @@
+package goloquent
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/datastore"
+)
+
+func TestMySQLToString(t *testing.T) {
+	s := new(mysql)
+	for _, tc := range []struct {
+		value  interface{}
+		expect string
+	}{
+		{[]interface{}{}, "(CAST('[]' AS JSON))"},
+		{[]interface{}{"a", 1, true}, `(CAST('["a",1,true]' AS JSON))`},
+		{map[string]interface{}{}, "(CAST('{}' AS JSON))"},
+		{map[string]interface{}{"name": "O'Neil", "tags": []interface{}{"x"}}, `(CAST('{"name":"O''Neil","tags":["x"]}' AS JSON))`},
+		{json.RawMessage(`{"a":1}`), `(CAST('{"a":1}' AS JSON))`},
+		{json.RawMessage(nil), "(CAST('null' AS JSON))"},
+		{map[string]interface{}{"quote": "it's \"hi\"\n"}, `(CAST('{"quote":"it''s \\"hi\\"\\n"}' AS JSON))`},
+		{nil, "NULL"},
+		{int64(10), "10"},
+	} {
+		if v := s.ToString(tc.value); v != tc.expect {
+			t.Fatalf("Expected %s, but get %s", tc.expect, v)
+		}
+	}
+}
+
+func TestMySQLDataTypeJSONDefault(t *testing.T) {
+	type config struct {
+		Tags []string
+	}
+
+	codec, err := getStructCodec(new(config))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := new(mysql)
+	sc := s.GetSchema(getColumns(nil, codec)[0])[0]
+	if sc.DataType != "json" {
+		t.Fatalf("Unexpected data type, %s", sc.DataType)
+	}
+	if dt := s.DataType(sc); dt != "json NOT NULL" {
+		t.Fatalf("Unexpected default on json column, %s", dt)
+	}
+
+	sc.DefaultValue = map[string]interface{}{"tags": []interface{}{"a", "b"}}
+	if dt := s.DataType(sc); dt != `json NOT NULL DEFAULT (CAST('{"tags":["a","b"]}' AS JSON))` {
+		t.Fatalf("Unexpected json default, %s", dt)
+	}
+
+	// the expression default is only supported by mysql 8.0.13 and above
+	for _, tc := range []struct {
+		version string
+		expect  string
+	}{
+		{"5.7.30-log", "json NOT NULL"},
+		{"8.0.12", "json NOT NULL"},
+		{"10.6.4-MariaDB", "json NOT NULL"},
+		{"8.0.13", `json NOT NULL DEFAULT (CAST('{"tags":["a","b"]}' AS JSON))`},
+		{"8.4.0", `json NOT NULL DEFAULT (CAST('{"tags":["a","b"]}' AS JSON))`},
+	} {
+		s.version = tc.version
+		if dt := s.DataType(sc); dt != tc.expect {
+			t.Fatalf("Unexpected json default on version %s, %s", tc.version, dt)
+		}
+	}
+}
+
+func TestMySQLDataTypeDecimal(t *testing.T) {
+	type invoice struct {
+		Amount   float64 `goloquent:",type=decimal(18,2)"`
+		Total    string  `goloquent:"column:Total;type:DECIMAL(18,2)"`
+		Discount float64 `goloquent:",unsigned,datatype=numeric(5,2)"`
+		Quantity int     `goloquent:",datatype=varchar(20)"`
+	}
+
+	codec, err := getStructCodec(new(invoice))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := new(mysql)
+	cols := getColumns(nil, codec)
+	for i, expected := range []string{
+		"decimal(18,2) NOT NULL DEFAULT 0",
+		`decimal(18,2) NOT NULL DEFAULT "0"`,
+		"numeric(5,2) UNSIGNED NOT NULL DEFAULT 0",
+		"int NOT NULL DEFAULT 0", // only the decimal data type is applicable for the numeric field
+	} {
+		if dt := s.DataType(s.GetSchema(cols[i])[0]); dt != expected {
+			t.Fatalf("Unexpected data type, %s", dt)
+		}
+	}
+
+	// the decimal value is kept as it is when it's a string
+	f := cols[1].field
+	it, err := valueToInterface(f.typeOf, []byte("12345678901234567.10"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if it != "12345678901234567.10" {
+		t.Fatalf("Unexpected decimal value, %v", it)
+	}
+	var empty string
+	if it, _ := saveField(f, reflect.ValueOf(empty)); it != "0" {
+		t.Fatalf("Unexpected decimal value, %v", it)
+	}
+}
+
+func TestMySQLDataTypeRawDefault(t *testing.T) {
+	type user struct {
+		Name      string
+		CreatedAt time.Time `goloquent:",default=CURRENT_TIMESTAMP"`
+		UUID      string    `goloquent:"column:uuid;datatype:char(36);default:(UUID())"`
+		Remark    string    `goloquent:",default='N/A, it''s empty'"`
+		Label     string    `goloquent:",default=CONCAT('a',')'),longtext"`
+	}
+
+	codec, err := getStructCodec(new(user))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cols := getColumns(nil, codec)
+	s := new(mysql)
+	for i, expected := range []string{
+		"varchar(191) CHARACTER SET `utf8mb4` COLLATE `utf8mb4_unicode_ci` NOT NULL DEFAULT \"\"",
+		"datetime NOT NULL DEFAULT CURRENT_TIMESTAMP",
+		"char(36) CHARACTER SET `utf8mb4` COLLATE `utf8mb4_unicode_ci` NOT NULL DEFAULT (UUID())",
+		"varchar(191) CHARACTER SET `utf8mb4` COLLATE `utf8mb4_unicode_ci` NOT NULL DEFAULT \"N/A, it's empty\"",
+		"text CHARACTER SET `utf8mb4` COLLATE `utf8mb4_unicode_ci` NOT NULL DEFAULT CONCAT('a',')')",
+	} {
+		if dt := s.DataType(s.GetSchema(cols[i])[0]); dt != expected {
+			t.Fatalf("Unexpected data type, %s", dt)
+		}
+	}
+
+	// the literal default is quoted, even it's the same as the expression
+	sc := Schema{DataType: "varchar(20)", DefaultValue: "CURRENT_TIMESTAMP"}
+	if dt := s.DataType(sc); dt != `varchar(20) NOT NULL DEFAULT "CURRENT_TIMESTAMP"` {
+		t.Fatalf("Unexpected data type, %s", dt)
+	}
+	sc.DefaultValue = RawDefault("CURRENT_TIMESTAMP")
+	for _, tc := range []struct {
+		dialect Dialect
+		raw     string
+	}{
+		{s, "varchar(20) NOT NULL DEFAULT CURRENT_TIMESTAMP"},
+		{new(postgres), "varchar(20) NOT NULL DEFAULT CURRENT_TIMESTAMP"},
+		{new(sqlite), "TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP"},
+	} {
+		if dt := tc.dialect.DataType(sc); dt != tc.raw {
+			t.Fatalf("Unexpected data type, %s", dt)
+		}
+	}
+}
+
+func TestMySQLDataTypeEnum(t *testing.T) {
+	type user struct {
+		Status string  `goloquent:",enum=active|inactive|banned"`
+		Role   *string `goloquent:"column:role;enum:Admin | Member's"`
+	}
+
+	codec, err := getStructCodec(new(user))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cols := getColumns(nil, codec)
+	s := new(mysql)
+	for i, expected := range []string{
+		"enum('active','inactive','banned') CHARACTER SET `utf8mb4` COLLATE `utf8mb4_unicode_ci` NOT NULL DEFAULT \"active\"",
+		"enum('Admin','Member''s') CHARACTER SET `utf8mb4` COLLATE `utf8mb4_unicode_ci`",
+	} {
+		if dt := s.DataType(s.GetSchema(cols[i])[0]); dt != expected {
+			t.Fatalf("Unexpected data type, %s", dt)
+		}
+	}
+
+	// the dialect without native enum restricts the values using the `CHECK` constraint
+	p := new(postgres)
+	if dt := p.DataType(p.GetSchema(cols[0])[0]); dt != `varchar(191) CHECK ("Status" IN ('active','inactive','banned')) NOT NULL DEFAULT 'active'` {
+		t.Fatalf("Unexpected data type, %s", dt)
+	}
+}
+
+func TestMySQLCheckVersion(t *testing.T) {
+	for _, v := range []string{"5.7.26", "5.7.26-log", "8.0.21", "10.4.12-MariaDB", "5.10.1"} {
+		if err := checkVersion(v); err != nil {
+			t.Fatalf("Unexpected error on version %q, %v", v, err)
+		}
+	}
+	for _, v := range []string{"5.6.45", "5.1", "", "unknown"} {
+		if err := checkVersion(v); err == nil {
+			t.Fatalf("Expected error on version %q", v)
+		}
+	}
+}
+
+func TestMySQLAlterTableStmt(t *testing.T) {
+	type user struct {
+		Key   *datastore.Key `goloquent:"__key__"`
+		Name  string
+		Email string `goloquent:",index"`
+	}
+
+	e, err := newEntity(new(user))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &mysql{sequel: sequel{dbName: "goloquent", db: Client{CharSet: utf8mb4CharSet}}}
+	cols := newDictionary([]string{pkColumn, "Name", "Age"})
+	idxs := newDictionary([]string{"user_Age_idx", "user_Name_Email_unique"})
+	cmd := s.alterTableStmt("user", e.columns, cols, idxs, newDictionary(nil), true)
+	expected := "ALTER TABLE `goloquent`.`user` " +
+		"MODIFY `$Key` varchar(512) CHARACTER SET `latin1` COLLATE `latin1_bin` FIRST, " +
+		"MODIFY `Name` varchar(191) CHARACTER SET `utf8mb4` COLLATE `utf8mb4_unicode_ci` NOT NULL DEFAULT \"\" AFTER `$Key`, " +
+		"ADD `Email` varchar(191) CHARACTER SET `utf8mb4` COLLATE `utf8mb4_unicode_ci` NOT NULL DEFAULT \"\" AFTER `Name`, " +
+		"ADD INDEX `user_Email_idx` (`Email`), " +
+		"DROP COLUMN `Age`, " +
+		"DROP INDEX `user_Age_idx`, " +
+		"CHARACTER SET `utf8mb4` COLLATE `utf8mb4_unicode_ci`;"
+	if raw := cmd.string(); raw != expected {
+		t.Fatalf("Unexpected alter statement, %q", raw)
+	}
+
+	// the column and index which are not in the model are kept
+	cols = newDictionary([]string{pkColumn, "Name", "Age"})
+	idxs = newDictionary([]string{"user_Age_idx", "user_Name_Email_unique"})
+	cmd = s.alterTableStmt("user", e.columns, cols, idxs, newDictionary(nil), false)
+	expected = strings.Replace(expected, "DROP COLUMN `Age`, DROP INDEX `user_Age_idx`, ", "", 1)
+	if raw := cmd.string(); raw != expected {
+		t.Fatalf("Unexpected alter statement, %q", raw)
+	}
+
+	// the index created with the table is matched on the next migrations without dropping
+	ss, err := s.CreateTable("user", e.columns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(ss[0], "INDEX `user_Email_idx` (`Email`)") {
+		t.Fatalf("Unexpected create statement, %q", ss[0])
+	}
+	for _, idx := range []string{"user_Email_idx", "user_Email_Idx"} {
+		for i := 0; i < 2; i++ {
+			cols = newDictionary([]string{pkColumn, "Name", "Email"})
+			idxs = newDictionary([]string{idx})
+			raw := s.alterTableStmt("user", e.columns, cols, idxs, newDictionary(nil), false).string()
+			if strings.Contains(raw, "ADD INDEX") {
+				t.Fatalf("Expected existing index %s should not be added again, %q", idx, raw)
+			}
+		}
+	}
+}
+
+func TestMySQLAlterTableRename(t *testing.T) {
+	type user struct {
+		Key      *datastore.Key `goloquent:"__key__"`
+		FullName string         `goloquent:",oldname=Name"`
+		Nickname string         `goloquent:",oldname=Alias"`
+	}
+
+	e, err := newEntity(new(user))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &mysql{sequel: sequel{dbName: "goloquent", db: Client{CharSet: utf8mb4CharSet}}}
+	cols := newDictionary([]string{pkColumn, "Name", "Nickname", "Alias"})
+	cmd := s.alterTableStmt("user", e.columns, cols, newDictionary(nil), newDictionary(nil), true)
+	// the old column is only renamed when the new column doesn't exist
+	expected := "ALTER TABLE `goloquent`.`user` " +
+		"MODIFY `$Key` varchar(512) CHARACTER SET `latin1` COLLATE `latin1_bin` FIRST, " +
+		"CHANGE COLUMN `Name` `FullName` varchar(191) CHARACTER SET `utf8mb4` COLLATE `utf8mb4_unicode_ci` NOT NULL DEFAULT \"\" AFTER `$Key`, " +
+		"MODIFY `Nickname` varchar(191) CHARACTER SET `utf8mb4` COLLATE `utf8mb4_unicode_ci` NOT NULL DEFAULT \"\" AFTER `FullName`, " +
+		"DROP COLUMN `Alias`, " +
+		"CHARACTER SET `utf8mb4` COLLATE `utf8mb4_unicode_ci`;"
+	if raw := cmd.string(); raw != expected {
+		t.Fatalf("Unexpected alter statement, %q", raw)
+	}
+}
+
+func TestMySQLAlterTableFullText(t *testing.T) {
+	type post struct {
+		Key   *datastore.Key `goloquent:"__key__"`
+		Title string         `goloquent:",fulltext"`
+		Body  string         `goloquent:",longtext,fulltext"`
+	}
+
+	e, err := newEntity(new(post))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &mysql{sequel: sequel{dbName: "goloquent", db: Client{CharSet: utf8mb4CharSet}}}
+	cols := newDictionary([]string{pkColumn, "Title", "Body"})
+	idxs := newDictionary([]string{"post_Title_fulltext"})
+	raw := s.alterTableStmt("post", e.columns, cols, idxs, newDictionary(nil), true).string()
+	if !strings.Contains(raw, "ADD FULLTEXT INDEX `post_Body_fulltext` (`Body`)") {
+		t.Fatalf("Expected fulltext index to be added, %q", raw)
+	}
+	if strings.Contains(raw, "post_Title_fulltext") {
+		t.Fatalf("Expected existing fulltext index to be kept, %q", raw)
+	}
+}
+
+func TestMySQLForeignKey(t *testing.T) {
+	type comment struct {
+		Key    *datastore.Key `goloquent:"__key__"`
+		Post   *datastore.Key `goloquent:"PostKey,references=Post,ondelete=cascade"`
+		Author string         `goloquent:",references=User(Username)"`
+	}
+
+	e, err := newEntity(new(comment))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &mysql{sequel: sequel{dbName: "goloquent", db: Client{CharSet: utf8mb4CharSet}}}
+	cols := newDictionary([]string{pkColumn, "PostKey", "Author"})
+	idxs := newDictionary([]string{"comment_PostKey_idx", "comment_Author_fk"})
+	fks := newDictionary([]string{"comment_Author_fk"})
+	raw := s.alterTableStmt("comment", e.columns, cols, idxs, fks, true).string()
+	if !strings.Contains(raw, "ADD CONSTRAINT `comment_PostKey_fk` FOREIGN KEY (`PostKey`) "+
+		"REFERENCES `goloquent`.`Post` (`$Key`) ON DELETE CASCADE") {
+		t.Fatalf("Expected foreign key to be added, %q", raw)
+	}
+	// the existing foreign key and its index are kept
+	if strings.Contains(raw, "comment_Author_fk") {
+		t.Fatalf("Expected existing foreign key to be kept, %q", raw)
+	}
+}
@@ -5,7 +5,6 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
-	"log"
 	"reflect"
 	"strings"
 	"time"
@@ -44,7 +43,6 @@ func (p *postgres) Open(conf Config) (*sql.DB, error) {
 	}
 	buf.WriteString(fmt.Sprintf("dbname='%s' ", p.escapeSingleQuote(conf.Database)))
 	buf.WriteString("sslmode=disable")
-	log.Println("Connection String :", buf.String())
 	client, err := sql.Open("postgres", buf.String())
 	if err != nil {
 		return nil, err
@@ -88,6 +86,18 @@ func (p postgres) SplitJSON(name string) string {
 		`'`+strings.Join(vv, p.Value(`->`))+`'`)
 }
 
+// FilterMatch : the full-text search is using the text search of postgres
+func (p postgres) FilterMatch(names []string) (string, error) {
+	return fmt.Sprintf("to_tsvector(concat_ws(' ', %s)) @@ plainto_tsquery(%s)",
+		strings.Join(names, ", "), variable), nil
+}
+
+// SetJSON :
+func (p postgres) SetJSON(name string, paths []string) string {
+	return fmt.Sprintf("jsonb_set((%s)::jsonb, '{%s}', %s::jsonb)",
+		p.Quote(name), strings.Join(paths, ","), variable)
+}
+
 func (p postgres) JSONMarshal(v interface{}) (b json.RawMessage) {
 	switch vi := v.(type) {
 	case json.RawMessage:
@@ -169,6 +179,18 @@ func (p postgres) FilterJSON(f Filter) (string, []interface{}, error) {
 		buf.Truncate(buf.Len() - 1)
 		buf.WriteString("]")
 		return buf.String(), args, nil
+	case JSONContains:
+		buf.WriteString(fmt.Sprintf("(%s)::jsonb @> %s::jsonb", name, variable))
+		args = append(args, p.JSONMarshal(vv))
+		return buf.String(), args, nil
+	case JSONLength:
+		op, err := f.Comparison()
+		if err != nil {
+			return "", nil, err
+		}
+		buf.WriteString(fmt.Sprintf("jsonb_array_length((%s)::jsonb) %s %s", name, op, variable))
+		args = append(args, vv)
+		return buf.String(), args, nil
 	case IsType:
 		args = append(args, vv)
 		buf.WriteString(fmt.Sprintf("jsonb_typeof((%s)::jsonb) = LOWER(%s)", name, variable))
@@ -202,6 +224,11 @@ func (p postgres) Value(it interface{}) string {
 	return str
 }
 
+// EscapeLike :
+func (p postgres) EscapeLike(v string) string {
+	return escapeLike(v)
+}
+
 // DataType :
 func (p postgres) DataType(sc Schema) string {
 	buf := new(bytes.Buffer)
@@ -209,21 +236,33 @@ func (p postgres) DataType(sc Schema) string {
 	if sc.IsUnsigned {
 		buf.WriteString(fmt.Sprintf(" CHECK (%s >= 0)", p.Quote(sc.Name)))
 	}
+	// postgres enum is a custom type, so the values are restricted using the `CHECK` constraint instead
+	if len(sc.Enum) > 0 {
+		buf.WriteString(" " + enumCheck(&p, sc))
+	}
 	if !sc.IsNullable {
 		buf.WriteString(" NOT NULL")
 		t := reflect.TypeOf(sc.DefaultValue)
 		if t != reflect.TypeOf(OmitDefault(nil)) {
-			buf.WriteString(fmt.Sprintf(" DEFAULT %s", p.ToString(sc.DefaultValue)))
+			buf.WriteString(fmt.Sprintf(" DEFAULT %s", sc.defaultValue(p.ToString)))
 		}
 	}
 	return buf.String()
 }
 
-func (p postgres) OnConflictUpdate(table string, cols []string) string {
+// OnConflictUpdate : the conflict target is the primary key unless the `keys` is provided
+func (p postgres) OnConflictUpdate(table string, keys, cols []string) string {
+	target := []string{p.Quote(p.db.keyColumn())}
+	if len(keys) > 0 {
+		target = make([]string, len(keys))
+		for i, k := range keys {
+			target[i] = p.Quote(k)
+		}
+	}
 	buf := new(bytes.Buffer)
-	buf.WriteString(fmt.Sprintf("ON CONFLICT (%s) DO UPDATE SET ", p.Quote(pkColumn)))
+	buf.WriteString(fmt.Sprintf("ON CONFLICT (%s) DO UPDATE SET ", strings.Join(target, ",")))
 	for _, c := range cols {
-		buf.WriteString(fmt.Sprintf("%s = %s.%s,", p.Quote(c), p.GetTable(table), p.Quote(c)))
+		buf.WriteString(fmt.Sprintf("%s = EXCLUDED.%s,", p.Quote(c), p.Quote(c)))
 	}
 	buf.Truncate(buf.Len() - 1)
 	return buf.String()
@@ -239,9 +278,17 @@ func (p postgres) GetSchema(c Column) []Schema {
 		}
 	}
 
+	fk, _ := f.foreignKey()
+	// `$Key` references the primary key column of the connection
+	if fk != nil && fk.Column == pkColumn {
+		fk.Column = p.db.keyColumn()
+	}
 	sc := Schema{
-		Name:       c.Name(),
+		Name:       c.column(),
 		IsNullable: f.isPtrChild,
+		IsIndexed:  f.IsIndex(),
+		IsUnique:   f.IsUnique(),
+		ForeignKey: fk,
 	}
 
 	if t.Kind() == reflect.Ptr {
@@ -249,7 +296,7 @@ func (p postgres) GetSchema(c Column) []Schema {
 		if t == typeOfPtrKey {
 			if f.name == keyFieldName {
 				return []Schema{
-					Schema{pkColumn, fmt.Sprintf("varchar(%d)", pkLen), OmitDefault(nil), false, false, false, latin1CharSet},
+					Schema{c.primaryKey(), fmt.Sprintf("varchar(%d)", pkLen), OmitDefault(nil), false, false, false, false, false, latin1CharSet, nil, nil},
 				}
 			}
 			sc.IsIndexed = true
@@ -287,6 +334,11 @@ func (p postgres) GetSchema(c Column) []Schema {
 				sc.DefaultValue = nil
 				sc.DataType = "text"
 			}
+			// the zero value isn't one of the enum, so the default is the first value
+			if enum := f.enum(); len(enum) > 0 {
+				sc.Enum = enum
+				sc.DefaultValue = enum[0]
+			}
 		case reflect.Bool:
 			sc.DefaultValue = false
 			sc.DataType = "bool"
@@ -333,6 +385,11 @@ func (p postgres) GetSchema(c Column) []Schema {
 		}
 	}
 
+	overrideDataType(&sc, f, t)
+	if def := f.defaultValue(); def != nil {
+		sc.DefaultValue = def
+	}
+
 	return []Schema{sc}
 }
 
@@ -348,6 +405,45 @@ func (p *postgres) GetColumns(table string) (columns []string) {
 	return
 }
 
+// SupportIsolationLevel : Postgres supports `READ COMMITTED` (default), `REPEATABLE READ` and `SERIALIZABLE`,
+// `READ UNCOMMITTED` is accepted but it behaves like `READ COMMITTED`
+func (p postgres) SupportIsolationLevel(level sql.IsolationLevel) bool {
+	switch level {
+	case sql.LevelDefault, sql.LevelReadUncommitted, sql.LevelReadCommitted,
+		sql.LevelRepeatableRead, sql.LevelSerializable:
+		return true
+	}
+	return false
+}
+
+// IsRetryable : serialization failure (40001) and deadlock (40P01) are retryable
+func (p postgres) IsRetryable(err error) bool {
+	switch sqlState(err) {
+	case "40001", "40P01":
+		return true
+	}
+	return false
+}
+
+// GetColumnTypes : return the lower case data type of the columns
+func (p *postgres) GetColumnTypes(table string) map[string]string {
+	types := make(map[string]string)
+	stmt := "SELECT column_name, data_type FROM INFORMATION_SCHEMA.columns WHERE table_schema = CURRENT_SCHEMA() AND table_name = $1;"
+	rows, err := p.db.Query(stmt, table)
+	if err != nil {
+		return types
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name, dataType string
+		if err := rows.Scan(&name, &dataType); err != nil {
+			continue
+		}
+		types[name] = strings.ToLower(dataType)
+	}
+	return types
+}
+
 // GetIndexes :
 func (p *postgres) GetIndexes(table string) (idxs []string) {
 	stmt := "SELECT indexname FROM pg_indexes WHERE schemaname = CURRENT_SCHEMA() AND tablename = $1;"
@@ -360,12 +456,47 @@ func (p *postgres) GetIndexes(table string) (idxs []string) {
 	return
 }
 
+// foreignKeys will return the constraint names of the foreign keys of the table
+func (p *postgres) foreignKeys(table string) (fks []string) {
+	return p.constraints(table, "FOREIGN KEY")
+}
+
+// constraints will return the constraint names of the table with the type, such as `CHECK`
+func (p *postgres) constraints(table, kind string) (names []string) {
+	stmt := "SELECT constraint_name FROM INFORMATION_SCHEMA.table_constraints WHERE table_schema = CURRENT_SCHEMA() AND table_name = $1 AND constraint_type = $2;"
+	rows, err := p.db.Query(stmt, table, kind)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+	for i := 0; rows.Next(); i++ {
+		names = append(names, "")
+		rows.Scan(&names[i])
+	}
+	return
+}
+
 func (p *postgres) HasTable(table string) bool {
 	var count int
 	p.db.QueryRow("SELECT count(*) FROM INFORMATION_SCHEMA.tables WHERE table_type = 'BASE TABLE' AND table_schema = CURRENT_SCHEMA() AND table_name = $1;", table).Scan(&count)
 	return count > 0
 }
 
+// HasColumn :
+func (p *postgres) HasColumn(table, column string) (bool, error) {
+	var count int
+	if err := p.db.QueryRow("SELECT count(*) FROM INFORMATION_SCHEMA.columns WHERE table_schema = CURRENT_SCHEMA() AND table_name = $1 AND column_name = $2;",
+		table, column).Scan(&count); err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// RenameColumn :
+func (p postgres) RenameColumn(table, from, to string) (string, error) {
+	return fmt.Sprintf("ALTER TABLE %s RENAME COLUMN %s TO %s;", p.GetTable(table), p.Quote(from), p.Quote(to)), nil
+}
+
 func (p *postgres) HasIndex(table, idx string) bool {
 	var count int
 	p.db.QueryRow("SELECT count(*) FROM pg_indexes WHERE tablename = $1 AND indexname = $2 AND schemaname = CURRENT_SCHEMA()", table, idx).Scan(&count)
@@ -399,15 +530,10 @@ func (p *postgres) ToString(it interface{}) string {
 	return v
 }
 
-func (p *postgres) CreateTable(table string, columns []Column) error {
+// CreateTable : will return the statements to create the table and its indexes, they are not executed
+func (p *postgres) CreateTable(table string, columns []Column) ([]string, error) {
 	idxs := make([]string, 0, len(columns))
-	conn := p.db.sqlCommon.(*sql.DB)
-	tx, err := conn.Begin()
-	if err != nil {
-		return err
-	}
-	defer tx.Rollback()
-
+	fks := make([]string, 0)
 	buf := new(bytes.Buffer)
 	buf.WriteString(fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (", p.GetTable(table)))
 	for _, c := range columns {
@@ -416,45 +542,61 @@ func (p *postgres) CreateTable(table string, columns []Column) error {
 				p.Quote(ss.Name),
 				p.DataType(ss)))
 
-			if ss.IsIndexed {
-				idx := fmt.Sprintf("%s_%s_%s", table, ss.Name, "Idx")
+			switch {
+			case ss.IsUnique:
+				idx := indexName(table, []string{ss.Name}, uniqueIdx)
+				stmt := fmt.Sprintf("CREATE UNIQUE INDEX %s ON %s (%s);",
+					p.Quote(idx), p.GetTable(table), p.Quote(ss.Name))
+				idxs = append(idxs, stmt)
+			case ss.IsIndexed:
+				idx := indexName(table, []string{ss.Name}, bTreeIdx)
 				stmt := fmt.Sprintf("CREATE INDEX %s ON %s (%s);",
 					p.Quote(idx), p.GetTable(table), p.Quote(ss.Name))
 				idxs = append(idxs, stmt)
 			}
+			if ss.ForeignKey != nil {
+				fks = append(fks, ","+foreignKeyConstraint(p, table, ss))
+			}
 		}
 	}
-	buf.WriteString(fmt.Sprintf("PRIMARY KEY (%s)", p.Quote(pkColumn)))
+	buf.WriteString(fmt.Sprintf("PRIMARY KEY (%s)", p.Quote(primaryKeyColumn(columns))))
+	buf.WriteString(strings.Join(fks, ""))
 	buf.WriteString(");")
-	log.Println(buf.String())
-	if _, err := tx.Exec(buf.String()); err != nil {
-		return err
-	}
-
-	for _, idx := range idxs {
-		if _, err := tx.Exec(idx); err != nil {
-			return err
-		}
-	}
-
-	return tx.Commit()
+	return append([]string{buf.String()}, idxs...), nil
 }
 
-func (p *postgres) AlterTable(table string, columns []Column) error {
+// AlterTable : will return the statements to alter the table according to the existing columns and indexes,
+// the columns which are not in the model are dropped when `allowDrop` is true, the existing column declared
+// by the `oldname` of the tag is renamed before the table is altered
+func (p *postgres) AlterTable(table string, columns []Column, allowDrop bool) ([]string, error) {
 	cols := newDictionary(p.GetColumns(table))
 	idxs := newDictionary(p.GetIndexes(table))
 	idxs.delete(fmt.Sprintf("%s_pkey", table))
+	fks := newDictionary(p.foreignKeys(table))
+	checks := newDictionary(p.constraints(table, "CHECK"))
+	renames := make([]string, 0)
+	uniques := make([]string, 0)
 	buf := new(bytes.Buffer)
 	buf.WriteString(fmt.Sprintf("ALTER TABLE %s ", p.GetTable(table)))
 	for _, c := range columns {
 		for _, ss := range p.GetSchema(c) {
+			// postgres doesn't allow `RENAME COLUMN` along with the other actions, so it's a separate statement
+			if old := c.oldName(); old != "" && !cols.has(ss.Name) && cols.has(old) {
+				renames = append(renames, fmt.Sprintf("ALTER TABLE %s RENAME COLUMN %s TO %s;",
+					p.GetTable(table), p.Quote(old), p.Quote(ss.Name)))
+				cols.delete(old)
+				cols.add(ss.Name)
+			}
 			if !cols.has(ss.Name) {
 				buf.WriteString(fmt.Sprintf("ADD COLUMN %s %s", p.Quote(ss.Name), ss.DataType))
+				if len(ss.Enum) > 0 {
+					buf.WriteString(" " + enumCheck(p, ss))
+				}
 				if !ss.IsNullable {
 					buf.WriteString(" NOT NULL")
 					if !ss.IsOmitEmpty() {
 						buf.WriteString(fmt.Sprintf(" DEFAULT %s",
-							p.ToString(ss.DefaultValue)))
+							ss.defaultValue(p.ToString)))
 					}
 				}
 				buf.WriteString(",")
@@ -466,13 +608,28 @@ func (p *postgres) AlterTable(table string, columns []Column) error {
 					buf.WriteString(prefix + " SET NOT NULL,")
 					if !ss.IsOmitEmpty() {
 						buf.WriteString(fmt.Sprintf("%s SET DEFAULT %s,",
-							prefix, p.ToString(ss.DefaultValue)))
+							prefix, ss.defaultValue(p.ToString)))
 					}
 				}
+				// the `CHECK` of the enum is named by postgres as `table_column_check`, it's replaced
+				// as the values may be changed, and it's dropped when the column is no longer an enum
+				check := fmt.Sprintf("%s_%s_check", table, ss.Name)
+				if checks.has(check) && !ss.IsUnsigned {
+					buf.WriteString(fmt.Sprintf("DROP CONSTRAINT %s,", p.Quote(check)))
+				}
+				if len(ss.Enum) > 0 {
+					buf.WriteString(fmt.Sprintf("ADD CONSTRAINT %s %s,", p.Quote(check), enumCheck(p, ss)))
+				}
 			}
 
-			if ss.IsIndexed {
-				idx := fmt.Sprintf("%s_%s_%s", table, ss.Name, "idx")
+			if ss.IsUnique {
+				idx := indexName(table, []string{ss.Name}, uniqueIdx)
+				if !idxs.has(idx) {
+					uniques = append(uniques, fmt.Sprintf("CREATE UNIQUE INDEX %s ON %s (%s);",
+						p.Quote(idx), p.GetTable(table), p.Quote(ss.Name)))
+				}
+			} else if ss.IsIndexed {
+				idx := indexName(table, []string{ss.Name}, bTreeIdx)
 				if idxs.has(idx) {
 					idxs.delete(idx)
 				} else {
@@ -483,21 +640,23 @@ func (p *postgres) AlterTable(table string, columns []Column) error {
 					// 	p.Quote(ss.Name)))
 				}
 			}
+			// the existing foreign keys are never dropped
+			if ss.ForeignKey != nil && !fks.has(foreignKeyName(table, ss.Name)) {
+				buf.WriteString("ADD " + foreignKeyConstraint(p, table, ss) + ",")
+			}
 			cols.delete(ss.Name)
 		}
 	}
 
-	for _, col := range cols.keys() {
-		buf.WriteString(fmt.Sprintf(" DROP COLUMN %s,", p.Quote(col)))
+	if allowDrop {
+		for _, col := range cols.keys() {
+			buf.WriteString(fmt.Sprintf(" DROP COLUMN %s,", p.Quote(col)))
+		}
 	}
 
 	buf.Truncate(buf.Len() - 1)
 	buf.WriteString(";")
-
-	log.Println(idxs.keys())
-	return p.db.execStmt(&stmt{
-		statement: buf,
-	})
+	return append(append(renames, buf.String()), uniques...), nil
 
 	// for _, idx := range idxs.keys() {
 	// 	buff := new(bytes.Buffer)
@@ -516,30 +675,39 @@ func (p *postgres) AlterTable(table string, columns []Column) error {
 	// }
 }
 
-func (p *postgres) ReplaceInto(src, dst string) error {
-	cols := p.GetColumns(src)
-	pk := p.Quote(pkColumn)
-	src, dst = p.GetTable(src), p.GetTable(dst)
-	buf := new(bytes.Buffer)
-	buf.WriteString("WITH patch AS (")
-	buf.WriteString("UPDATE " + dst + " SET ")
+// DropIndex :
+func (p postgres) DropIndex(table, idx string) string {
+	return fmt.Sprintf("DROP INDEX IF EXISTS %s;", p.Quote(idx))
+}
+
+// IsDuplicate : unique violation (23505) is returned when the unique index is violated
+func (p postgres) IsDuplicate(err error) bool {
+	return sqlState(err) == "23505"
+}
+
+// LockClause :
+func (p postgres) LockClause(mode locked, opt lockOption) (string, error) {
+	switch mode {
+	case ReadLock:
+		return "FOR SHARE" + lockOptionClause(opt), nil
+	case WriteLock:
+		return "FOR UPDATE" + lockOptionClause(opt), nil
+	}
+	return "", nil
+}
+
+// ReplaceInto : postgres has no `REPLACE`, so the conflicted records are updated using `ON CONFLICT` instead
+func (p *postgres) ReplaceInto(table string, cols []string, query string) string {
+	pk := p.db.keyColumn()
+	updates := make([]string, 0, len(cols))
 	for _, c := range cols {
-		if c == pkColumn {
-			continue
+		if c != pk {
+			updates = append(updates, c)
 		}
-		cc := p.Quote(c)
-		buf.WriteString(cc + " = " + src + "." + cc + ",")
 	}
-	buf.Truncate(buf.Len() - 1)
-	buf.WriteString(" FROM " + src + " ")
-	buf.WriteString("WHERE " + src + "." + pk + " = " + dst + "." + pk + " ")
-	buf.WriteString("RETURNING " + src + "." + pk + ") ")
-	buf.WriteString("INSERT INTO " + dst + " ")
-	buf.WriteString("SELECT * FROM " + src + " ")
-	buf.WriteString("WHERE NOT EXISTS ")
-	buf.WriteString("(SELECT 1 FROM patch WHERE " + pk + " = " + src + "." + pk + ")")
-	buf.WriteString(";")
-	return p.db.execStmt(&stmt{
-		statement: buf,
-	})
+	conflict := fmt.Sprintf("ON CONFLICT (%s) DO NOTHING", p.Quote(pk))
+	if len(updates) > 0 {
+		conflict = p.OnConflictUpdate(table, nil, updates)
+	}
+	return fmt.Sprintf("INSERT INTO %s (%s) %s %s;", p.GetTable(table), quoteColumns(p.Quote, cols), query, conflict)
 }
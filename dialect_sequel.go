@@ -2,6 +2,7 @@ package goloquent
 
 import (
 	"bytes"
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
@@ -25,6 +26,9 @@ type sqlCommon interface {
 	Exec(query string, args ...interface{}) (sql.Result, error)
 	Query(query string, args ...interface{}) (*sql.Rows, error)
 	QueryRow(query string, args ...interface{}) *sql.Row
+	PrepareContext(ctx context.Context, query string) (*sql.Stmt, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
 }
 
 type sqlExtra interface {
@@ -101,6 +105,18 @@ func (s *sequel) SplitJSON(name string) string {
 		fmt.Sprintf("$.%s", strings.TrimSpace(paths[1])))
 }
 
+// FilterMatch : the `names` are quoted columns, they must be covered by the same `FULLTEXT` index
+func (s sequel) FilterMatch(names []string) (string, error) {
+	return fmt.Sprintf("MATCH(%s) AGAINST (%s IN NATURAL LANGUAGE MODE)",
+		strings.Join(names, ","), variable), nil
+}
+
+// SetJSON : the value of the path is replaced by the json value of the variable
+func (s *sequel) SetJSON(name string, paths []string) string {
+	return fmt.Sprintf("JSON_SET(%s, '$.%s', CAST(%s AS JSON))",
+		s.Quote(name), strings.Join(paths, "."), variable)
+}
+
 func (s sequel) JSONMarshal(v interface{}) (b json.RawMessage) {
 	switch vi := v.(type) {
 	case json.RawMessage:
@@ -182,6 +198,16 @@ func (s sequel) FilterJSON(f Filter) (string, []interface{}, error) {
 		buf.Truncate(buf.Len() - 4)
 		buf.WriteString(")")
 		return buf.String(), args, nil
+	case JSONContains:
+		buf.WriteString(fmt.Sprintf("JSON_CONTAINS(%s, %s)", name, variable))
+		args = append(args, s.JSONMarshal(vv))
+		return buf.String(), args, nil
+	case JSONLength:
+		op, err := f.Comparison()
+		if err != nil {
+			return "", nil, err
+		}
+		buf.WriteString(fmt.Sprintf("JSON_LENGTH(%s) %s %s", name, op, variable))
 	case IsType:
 		buf.WriteString(fmt.Sprintf("JSON_TYPE(%s) = UPPER(%s)", name, variable))
 	case IsObject:
@@ -217,6 +243,11 @@ func (s *sequel) Value(it interface{}) string {
 	return str
 }
 
+// EscapeLike :
+func (s *sequel) EscapeLike(v string) string {
+	return escapeLike(v)
+}
+
 // DataType :
 func (s *sequel) DataType(sc Schema) string {
 	buf := new(bytes.Buffer)
@@ -232,7 +263,7 @@ func (s *sequel) DataType(sc Schema) string {
 	if !sc.IsNullable {
 		buf.WriteString(" NOT NULL")
 		if !sc.IsOmitEmpty() {
-			buf.WriteString(fmt.Sprintf(" DEFAULT %s", s.ToString(sc.DefaultValue)))
+			buf.WriteString(fmt.Sprintf(" DEFAULT %s", sc.defaultValue(s.ToString)))
 		}
 	}
 	return buf.String()
@@ -274,10 +305,18 @@ func (s *sequel) GetSchema(c Column) []Schema {
 		}
 	}
 
+	fk, _ := f.foreignKey()
+	// `$Key` references the primary key column of the connection
+	if fk != nil && fk.Column == pkColumn {
+		fk.Column = s.db.keyColumn()
+	}
 	sc := Schema{
-		Name:       c.Name(),
+		Name:       c.column(),
 		IsNullable: f.isPtrChild,
 		IsIndexed:  f.IsIndex(),
+		IsUnique:   f.IsUnique(),
+		IsFullText: f.IsFullText(),
+		ForeignKey: fk,
 	}
 	if t.Kind() == reflect.Ptr {
 		sc.IsNullable = true
@@ -286,9 +325,11 @@ func (s *sequel) GetSchema(c Column) []Schema {
 			sc.DataType = fmt.Sprintf("varchar(%d)", pkLen)
 			sc.CharSet = latin1CharSet
 			if f.name == keyFieldName {
-				sc.Name = pkColumn
+				sc.Name = c.primaryKey()
 				sc.DefaultValue = OmitDefault(nil)
 				sc.IsIndexed = false
+				sc.IsUnique = false
+				sc.IsFullText = false
 			}
 			return []Schema{sc}
 		}
@@ -322,8 +363,10 @@ func (s *sequel) GetSchema(c Column) []Schema {
 				sc.DefaultValue = nil
 				sc.DataType = "text"
 			}
-			if f.Get("datatype") != "" {
-				sc.DataType = f.Get("datatype")
+			// the zero value isn't one of the enum, so the default is the first value
+			if enum := f.enum(); len(enum) > 0 {
+				sc.Enum = enum
+				sc.DefaultValue = enum[0]
 			}
 			sc.CharSet = utf8mb4CharSet
 			charset := f.Get("charset")
@@ -385,9 +428,31 @@ func (s *sequel) GetSchema(c Column) []Schema {
 		}
 	}
 
+	overrideDataType(&sc, f, t)
+	if def := f.defaultValue(); def != nil {
+		sc.DefaultValue = def
+	}
+
 	return []Schema{sc}
 }
 
+// overrideDataType will use the data type declared by the tag verbatim, it's only applicable for the string field,
+// and the decimal data type of the numeric field. The decimal column has no charset, and the default value of
+// the string field is `0` instead of the empty string
+func overrideDataType(sc *Schema, f field, t reflect.Type) {
+	dt := f.Get("datatype")
+	if dt == "" || (t.Kind() != reflect.String && !f.IsDecimal()) {
+		return
+	}
+	sc.DataType = dt
+	if f.IsDecimal() {
+		sc.CharSet = CharSet{}
+		if t.Kind() == reflect.String {
+			sc.DefaultValue = "0"
+		}
+	}
+}
+
 // GetColumns :
 func (s *sequel) GetColumns(table string) (columns []string) {
 	stmt := "SELECT COLUMN_NAME FROM INFORMATION_SCHEMA.COLUMNS WHERE TABLE_SCHEMA = ? AND TABLE_NAME = ?;"
@@ -400,6 +465,25 @@ func (s *sequel) GetColumns(table string) (columns []string) {
 	return
 }
 
+// GetColumnTypes : return the lower case data type of the columns
+func (s *sequel) GetColumnTypes(table string) map[string]string {
+	types := make(map[string]string)
+	stmt := "SELECT COLUMN_NAME, DATA_TYPE FROM INFORMATION_SCHEMA.COLUMNS WHERE TABLE_SCHEMA = ? AND TABLE_NAME = ?;"
+	rows, err := s.db.Query(stmt, s.CurrentDB(), table)
+	if err != nil {
+		return types
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name, dataType string
+		if err := rows.Scan(&name, &dataType); err != nil {
+			continue
+		}
+		types[name] = strings.ToLower(dataType)
+	}
+	return types
+}
+
 // GetIndexes :
 func (s *sequel) GetIndexes(table string) (idxs []string) {
 	stmt := "SELECT DISTINCT INDEX_NAME FROM INFORMATION_SCHEMA.STATISTICS WHERE TABLE_SCHEMA = ? AND TABLE_NAME = ? AND INDEX_NAME <> ?;"
@@ -412,6 +496,21 @@ func (s *sequel) GetIndexes(table string) (idxs []string) {
 	return
 }
 
+// foreignKeys will return the constraint names of the foreign keys of the table
+func (s *sequel) foreignKeys(table string) (fks []string) {
+	stmt := "SELECT CONSTRAINT_NAME FROM INFORMATION_SCHEMA.TABLE_CONSTRAINTS WHERE TABLE_SCHEMA = ? AND TABLE_NAME = ? AND CONSTRAINT_TYPE = ?;"
+	rows, err := s.db.Query(stmt, s.CurrentDB(), table, "FOREIGN KEY")
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+	for i := 0; rows.Next(); i++ {
+		fks = append(fks, "")
+		rows.Scan(&fks[i])
+	}
+	return
+}
+
 func (s *sequel) HasTable(table string) bool {
 	var count int
 	s.db.QueryRow("SELECT count(*) FROM INFORMATION_SCHEMA.TABLES WHERE TABLE_SCHEMA = ? AND TABLE_NAME = ?", s.CurrentDB(), table).Scan(&count)
@@ -420,12 +519,12 @@ func (s *sequel) HasTable(table string) bool {
 
 func (s *sequel) HasIndex(table, idx string) bool {
 	var count int
-	s.db.QueryRow("SELECT count(*) FROM INFORMATION_SCHEMA.TABLES WHERE TABLE_SCHEMA = ? AND TABLE_NAME = ? AND INDEX_NAME = ?", s.CurrentDB(), table, idx).Scan(&count)
+	s.db.QueryRow("SELECT count(*) FROM INFORMATION_SCHEMA.STATISTICS WHERE TABLE_SCHEMA = ? AND TABLE_NAME = ? AND INDEX_NAME = ?", s.CurrentDB(), table, idx).Scan(&count)
 	return count > 0
 }
 
-// OnConflictUpdate :
-func (s *sequel) OnConflictUpdate(table string, cols []string) string {
+// OnConflictUpdate : the conflict `keys` are ignored, it's always the primary key or unique index
+func (s *sequel) OnConflictUpdate(table string, keys, cols []string) string {
 	buf := new(bytes.Buffer)
 	buf.WriteString("ON DUPLICATE KEY UPDATE ")
 	for _, c := range cols {
@@ -435,18 +534,91 @@ func (s *sequel) OnConflictUpdate(table string, cols []string) string {
 	return buf.String()
 }
 
-func (s *sequel) CreateTable(string, []Column) error {
-	return nil
+func (s *sequel) CreateTable(string, []Column) ([]string, error) {
+	return nil, nil
+}
+
+func (s *sequel) AlterTable(string, []Column, bool) ([]string, error) {
+	return nil, nil
 }
 
-func (s *sequel) AlterTable(string, []Column) error {
-	return nil
+// DropIndex :
+func (s *sequel) DropIndex(table, idx string) string {
+	return fmt.Sprintf("DROP INDEX %s ON %s;", s.Quote(idx), s.GetTable(table))
+}
+
+// HasColumn :
+func (s *sequel) HasColumn(table, column string) (bool, error) {
+	var count int
+	if err := s.db.QueryRow("SELECT count(*) FROM INFORMATION_SCHEMA.COLUMNS WHERE TABLE_SCHEMA = ? AND TABLE_NAME = ? AND COLUMN_NAME = ?",
+		s.CurrentDB(), table, column).Scan(&count); err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// RenameColumn :
+func (s *sequel) RenameColumn(table, from, to string) (string, error) {
+	return fmt.Sprintf("ALTER TABLE %s RENAME COLUMN %s TO %s;", s.GetTable(table), s.Quote(from), s.Quote(to)), nil
 }
 
 func (s sequel) UpdateWithLimit() bool {
 	return false
 }
 
-func (s sequel) ReplaceInto(src, dst string) error {
-	return nil
+// IsDuplicate : the generic dialect doesn't know the driver error codes, so no error is a duplicate
+func (s sequel) IsDuplicate(err error) bool {
+	return false
+}
+
+func lockOptionClause(opt lockOption) string {
+	switch opt {
+	case lockSkipLocked:
+		return " SKIP LOCKED"
+	case lockNoWait:
+		return " NOWAIT"
+	}
+	return ""
+}
+
+// UnionSelect : the select of the union is wrapped in parentheses, so it can have its own `ORDER BY` and `LIMIT`
+func (s sequel) UnionSelect(ss string) string {
+	return "(" + ss + ")"
+}
+
+// LockClause : `SKIP LOCKED` and `NOWAIT` require MySQL 8.0, and they are not
+// supported by `LOCK IN SHARE MODE`
+func (s sequel) LockClause(mode locked, opt lockOption) (string, error) {
+	switch mode {
+	case ReadLock:
+		if opt > 0 {
+			return "", fmt.Errorf("goloquent: lock option is not supported by read lock")
+		}
+		return "LOCK IN SHARE MODE", nil
+	case WriteLock:
+		return "FOR UPDATE" + lockOptionClause(opt), nil
+	}
+	return "", nil
+}
+
+// SupportIsolationLevel : MySQL (InnoDB) supports `READ UNCOMMITTED`, `READ COMMITTED`,
+// `REPEATABLE READ` (default) and `SERIALIZABLE`
+func (s sequel) SupportIsolationLevel(level sql.IsolationLevel) bool {
+	switch level {
+	case sql.LevelDefault, sql.LevelReadUncommitted, sql.LevelReadCommitted,
+		sql.LevelRepeatableRead, sql.LevelSerializable:
+		return true
+	}
+	return false
+}
+
+// IsRetryable : the generic dialect doesn't know the driver error codes, so nothing is retried
+func (s sequel) IsRetryable(err error) bool {
+	return false
+}
+
+// ReplaceInto : will return the statement to replace the `cols` of the table using the select `query`,
+// the existing records with the same primary key are deleted before the new records are inserted
+func (s sequel) ReplaceInto(table string, cols []string, query string) string {
+	return fmt.Sprintf("REPLACE INTO %s (%s) %s;", s.GetTable(table), quoteColumns(s.Quote, cols), query)
 }
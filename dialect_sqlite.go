@@ -0,0 +1,478 @@
+package goloquent
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type sqlite struct {
+	sequel
+}
+
+var _ Dialect = new(sqlite)
+
+func init() {
+	RegisterDialect("sqlite3", new(sqlite))
+}
+
+// Open : the database is the file path, it will be an in-memory database
+// shared by all the connections if the database is empty or `:memory:`
+func (s *sqlite) Open(conf Config) (*sql.DB, error) {
+	dsn := strings.TrimSpace(conf.Database)
+	if dsn == "" || dsn == ":memory:" {
+		dsn = "file::memory:?cache=shared"
+	}
+	client, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+// GetTable :
+func (s sqlite) GetTable(name string) string {
+	return s.Quote(name)
+}
+
+// Version :
+func (s *sqlite) Version() (version string) {
+	s.db.QueryRow("SELECT sqlite_version();").Scan(&version)
+	return
+}
+
+// CurrentDB :
+func (s *sqlite) CurrentDB() (name string) {
+	return "main"
+}
+
+// Quote :
+func (s sqlite) Quote(n string) string {
+	return fmt.Sprintf(`"%s"`, n)
+}
+
+// Bind :
+func (s sqlite) Bind(uint) string {
+	return "?"
+}
+
+// Value :
+func (s sqlite) Value(it interface{}) string {
+	var str string
+	switch vi := it.(type) {
+	case nil:
+		str = "NULL"
+	case json.RawMessage:
+		str = fmt.Sprintf(`'%s'`, escapeSingleQuote(string(vi)))
+	case string:
+		str = fmt.Sprintf(`'%s'`, escapeSingleQuote(vi))
+	case []byte:
+		str = fmt.Sprintf(`'%s'`, escapeSingleQuote(string(vi)))
+	case float32:
+		str = strconv.FormatFloat(float64(vi), 'f', -1, 64)
+	case float64:
+		str = strconv.FormatFloat(vi, 'f', -1, 64)
+	default:
+		str = fmt.Sprintf("%v", vi)
+	}
+	return str
+}
+
+// EscapeLike :
+func (s sqlite) EscapeLike(v string) string {
+	return escapeLike(v)
+}
+
+// SplitJSON :
+func (s sqlite) SplitJSON(name string) string {
+	paths := strings.SplitN(name, ">", 2)
+	if len(paths) <= 1 {
+		return s.Quote(paths[0])
+	}
+	return fmt.Sprintf("json_extract(%s, '$.%s')",
+		s.Quote(strings.TrimSpace(paths[0])),
+		escapeSingleQuote(strings.TrimSpace(paths[1])))
+}
+
+// FilterMatch : sqlite requires the virtual table of fts5 for the full-text search
+func (s sqlite) FilterMatch(names []string) (string, error) {
+	return "", fmt.Errorf("goloquent: full-text search is not supported by sqlite")
+}
+
+// SetJSON :
+func (s sqlite) SetJSON(name string, paths []string) string {
+	return fmt.Sprintf("json_set(%s, '$.%s', json(%s))",
+		s.Quote(name), strings.Join(paths, "."), variable)
+}
+
+// FilterJSON : the json functions require the json1 extension of sqlite
+func (s sqlite) FilterJSON(f Filter) (string, []interface{}, error) {
+	vv, err := f.Interface()
+	if err != nil {
+		return "", nil, err
+	}
+	name := s.SplitJSON(f.Field())
+	buf, args := new(bytes.Buffer), make([]interface{}, 0)
+	switch f.operator {
+	case Equal:
+		if vv == nil {
+			buf.WriteString(fmt.Sprintf("%s IS NULL", name))
+			return buf.String(), args, nil
+		}
+		buf.WriteString(fmt.Sprintf("%s = %s", name, variable))
+	case NotEqual:
+		if vv == nil {
+			buf.WriteString(fmt.Sprintf("%s IS NOT NULL", name))
+			return buf.String(), args, nil
+		}
+		buf.WriteString(fmt.Sprintf("%s <> %s", name, variable))
+	case GreaterThan:
+		buf.WriteString(fmt.Sprintf("%s > %s", name, variable))
+	case GreaterEqual:
+		buf.WriteString(fmt.Sprintf("%s >= %s", name, variable))
+	case In, NotIn:
+		x, isOk := vv.([]interface{})
+		if !isOk {
+			x = append(x, vv)
+		}
+		if len(x) <= 0 {
+			return "", nil, fmt.Errorf(`goloquent: value for "In" operator cannot be empty`)
+		}
+		op := "IN"
+		if f.operator == NotIn {
+			op = "NOT IN"
+		}
+		buf.WriteString(fmt.Sprintf("%s %s (%s)", name, op,
+			strings.TrimRight(strings.Repeat(variable+",", len(x)), ",")))
+		args = append(args, x...)
+		return buf.String(), args, nil
+	case ContainAny:
+		x, isOk := vv.([]interface{})
+		if !isOk {
+			x = append(x, vv)
+		}
+		if len(x) <= 0 {
+			return "", nil, fmt.Errorf(`goloquent: value for "ContainAny" operator cannot be empty`)
+		}
+		buf.WriteString(fmt.Sprintf("EXISTS (SELECT 1 FROM json_each(%s) WHERE value IN (%s))", name,
+			strings.TrimRight(strings.Repeat(variable+",", len(x)), ",")))
+		args = append(args, x...)
+		return buf.String(), args, nil
+	case JSONContains:
+		// sqlite has no containment function, the value must be the document itself or one of its elements
+		buf.WriteString(fmt.Sprintf("(json(%s) = json(%s) OR EXISTS (SELECT 1 FROM json_each(%s) WHERE json_quote(value) = json(%s)))",
+			name, variable, name, variable))
+		b := s.JSONMarshal(vv)
+		args = append(args, b, b)
+		return buf.String(), args, nil
+	case JSONLength:
+		op, err := f.Comparison()
+		if err != nil {
+			return "", nil, err
+		}
+		buf.WriteString(fmt.Sprintf("json_array_length(%s) %s %s", name, op, variable))
+	case IsType:
+		buf.WriteString(fmt.Sprintf("json_type(%s) = LOWER(%s)", name, variable))
+	case IsObject:
+		vv = "object"
+		buf.WriteString(fmt.Sprintf("json_type(%s) = %s", name, variable))
+	case IsArray:
+		vv = "array"
+		buf.WriteString(fmt.Sprintf("json_type(%s) = %s", name, variable))
+	default:
+		return "", nil, fmt.Errorf("unsupported operator")
+	}
+
+	args = append(args, vv)
+	return buf.String(), args, nil
+}
+
+// storageClass will map the data type of the schema to the storage class of sqlite
+func (s sqlite) storageClass(dataType string) string {
+	dataType = strings.ToLower(dataType)
+	switch {
+	case strings.Contains(dataType, "int"), dataType == "boolean":
+		return "INTEGER"
+	case dataType == "double", dataType == "float", dataType == "real":
+		return "REAL"
+	case strings.Contains(dataType, "blob"):
+		return "BLOB"
+	}
+	return "TEXT"
+}
+
+// DataType :
+func (s sqlite) DataType(sc Schema) string {
+	buf := new(bytes.Buffer)
+	buf.WriteString(s.storageClass(sc.DataType))
+	if len(sc.Enum) > 0 {
+		buf.WriteString(" " + enumCheck(&s, sc))
+	}
+	// sqlite allows null on the primary key which is not an integer
+	if sc.Name == s.db.keyColumn() {
+		buf.WriteString(" NOT NULL")
+		return buf.String()
+	}
+	if !sc.IsNullable {
+		buf.WriteString(" NOT NULL")
+		if !sc.IsOmitEmpty() {
+			buf.WriteString(fmt.Sprintf(" DEFAULT %s", sc.defaultValue(s.ToString)))
+		}
+	}
+	return buf.String()
+}
+
+// ToString :
+func (s sqlite) ToString(it interface{}) string {
+	var v string
+	switch vi := it.(type) {
+	case nil:
+		v = "NULL"
+	case string:
+		v = fmt.Sprintf(`'%s'`, escapeSingleQuote(vi))
+	case bool:
+		v = "0"
+		if vi {
+			v = "1"
+		}
+	case time.Time:
+		v = fmt.Sprintf(`'%s'`, vi.Format("2006-01-02 15:04:05"))
+	case json.RawMessage:
+		v = fmt.Sprintf(`'%s'`, escapeSingleQuote(string(vi)))
+	case []interface{}, map[string]interface{}:
+		b, err := json.Marshal(vi)
+		if err != nil {
+			b = []byte("null")
+		}
+		v = fmt.Sprintf(`'%s'`, escapeSingleQuote(string(b)))
+	default:
+		v = fmt.Sprintf("%v", vi)
+	}
+	return v
+}
+
+// GetColumns :
+func (s *sqlite) GetColumns(table string) (columns []string) {
+	rows, err := s.db.Query("SELECT name FROM pragma_table_info(?);", table)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+	for i := 0; rows.Next(); i++ {
+		columns = append(columns, "")
+		rows.Scan(&columns[i])
+	}
+	return
+}
+
+// GetColumnTypes : return the lower case storage class of the columns
+func (s *sqlite) GetColumnTypes(table string) map[string]string {
+	types := make(map[string]string)
+	rows, err := s.db.Query("SELECT name, type FROM pragma_table_info(?);", table)
+	if err != nil {
+		return types
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name, dataType string
+		if err := rows.Scan(&name, &dataType); err != nil {
+			continue
+		}
+		types[name] = strings.ToLower(dataType)
+	}
+	return types
+}
+
+// GetIndexes : only the indexes created by `CREATE INDEX` are returned
+func (s *sqlite) GetIndexes(table string) (idxs []string) {
+	rows, err := s.db.Query("SELECT name FROM pragma_index_list(?) WHERE origin = 'c';", table)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+	for i := 0; rows.Next(); i++ {
+		idxs = append(idxs, "")
+		rows.Scan(&idxs[i])
+	}
+	return
+}
+
+// HasTable :
+func (s *sqlite) HasTable(table string) bool {
+	var count int
+	s.db.QueryRow("SELECT count(*) FROM sqlite_master WHERE type = 'table' AND name = ?;", table).Scan(&count)
+	return count > 0
+}
+
+// HasIndex :
+func (s *sqlite) HasIndex(table, idx string) bool {
+	var count int
+	s.db.QueryRow("SELECT count(*) FROM sqlite_master WHERE type = 'index' AND tbl_name = ? AND name = ?;", table, idx).Scan(&count)
+	return count > 0
+}
+
+// DropIndex :
+func (s sqlite) DropIndex(table, idx string) string {
+	return fmt.Sprintf("DROP INDEX IF EXISTS %s;", s.Quote(idx))
+}
+
+// HasColumn :
+func (s *sqlite) HasColumn(table, column string) (bool, error) {
+	var count int
+	if err := s.db.QueryRow("SELECT count(*) FROM pragma_table_info(?) WHERE name = ?;", table, column).Scan(&count); err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// RenameColumn : `RENAME COLUMN` requires SQLite 3.25
+func (s sqlite) RenameColumn(table, from, to string) (string, error) {
+	return fmt.Sprintf("ALTER TABLE %s RENAME COLUMN %s TO %s;", s.GetTable(table), s.Quote(from), s.Quote(to)), nil
+}
+
+// OnConflictUpdate : the conflict target is the primary key unless the `keys` is provided
+func (s sqlite) OnConflictUpdate(table string, keys, cols []string) string {
+	target := []string{s.Quote(s.db.keyColumn())}
+	if len(keys) > 0 {
+		target = make([]string, len(keys))
+		for i, k := range keys {
+			target[i] = s.Quote(k)
+		}
+	}
+	buf := new(bytes.Buffer)
+	buf.WriteString(fmt.Sprintf("ON CONFLICT (%s) DO UPDATE SET ", strings.Join(target, ",")))
+	for _, c := range cols {
+		buf.WriteString(fmt.Sprintf("%s = excluded.%s,", s.Quote(c), s.Quote(c)))
+	}
+	buf.Truncate(buf.Len() - 1)
+	return buf.String()
+}
+
+// createIndex will return the statement to create the index of the schema, it's empty if the schema has no index
+func (s sqlite) createIndex(table string, sc Schema) (string, string) {
+	switch {
+	case sc.IsUnique:
+		idx := fmt.Sprintf("%s_%s_%s", table, sc.Name, "unique")
+		return idx, fmt.Sprintf("CREATE UNIQUE INDEX IF NOT EXISTS %s ON %s (%s);",
+			s.Quote(idx), s.GetTable(table), s.Quote(sc.Name))
+	case sc.IsIndexed:
+		idx := fmt.Sprintf("%s_%s_%s", table, sc.Name, "idx")
+		return idx, fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s ON %s (%s);",
+			s.Quote(idx), s.GetTable(table), s.Quote(sc.Name))
+	}
+	return "", ""
+}
+
+// CreateTable : will return the statements to create the table and its indexes, they are not executed
+func (s *sqlite) CreateTable(table string, columns []Column) ([]string, error) {
+	idxs := make([]string, 0, len(columns))
+	pk := primaryKeyColumn(columns)
+	fks := make([]string, 0)
+	buf := new(bytes.Buffer)
+	buf.WriteString(fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (", s.GetTable(table)))
+	for _, c := range columns {
+		for _, ss := range s.GetSchema(c) {
+			dataType := s.DataType(ss)
+			// sqlite allows null on the primary key which is not an integer
+			if ss.Name == pk {
+				dataType = s.storageClass(ss.DataType) + " NOT NULL"
+			}
+			buf.WriteString(fmt.Sprintf("%s %s,", s.Quote(ss.Name), dataType))
+			if _, stmt := s.createIndex(table, ss); stmt != "" {
+				idxs = append(idxs, stmt)
+			}
+			if ss.ForeignKey != nil {
+				fks = append(fks, ","+foreignKeyConstraint(s, table, ss))
+			}
+		}
+	}
+	buf.WriteString(fmt.Sprintf("PRIMARY KEY (%s)", s.Quote(pk)))
+	buf.WriteString(strings.Join(fks, ""))
+	buf.WriteString(");")
+	return append([]string{buf.String()}, idxs...), nil
+}
+
+// AlterTable : sqlite only supports adding and renaming column, so the existing columns are neither modified
+// nor dropped regardless of `allowDrop`, the `NOT NULL` column without default is added as nullable
+func (s *sqlite) AlterTable(table string, columns []Column, allowDrop bool) ([]string, error) {
+	cols := newDictionary(s.GetColumns(table))
+	idxs := newDictionary(s.GetIndexes(table))
+	stmts := make([]string, 0)
+	for _, c := range columns {
+		for _, ss := range s.GetSchema(c) {
+			if old := c.oldName(); old != "" && !cols.has(ss.Name) && cols.has(old) {
+				stmts = append(stmts, fmt.Sprintf("ALTER TABLE %s RENAME COLUMN %s TO %s;",
+					s.GetTable(table), s.Quote(old), s.Quote(ss.Name)))
+				cols.delete(old)
+				cols.add(ss.Name)
+			}
+			// sqlite cannot add the constraint to the existing column without rebuilding the table,
+			// so the foreign key is only added along with the new column, and it must be nullable
+			if !cols.has(ss.Name) {
+				if !ss.IsNullable && (ss.IsOmitEmpty() || ss.ForeignKey != nil) {
+					ss.IsNullable = true
+				}
+				def := s.DataType(ss)
+				if ss.ForeignKey != nil {
+					def += " " + ss.ForeignKey.references(s)
+				}
+				stmts = append(stmts, fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s;",
+					s.GetTable(table), s.Quote(ss.Name), def))
+			}
+			if idx, stmt := s.createIndex(table, ss); stmt != "" && !idxs.has(idx) {
+				stmts = append(stmts, stmt)
+			}
+		}
+	}
+	return stmts, nil
+}
+
+// SupportIsolationLevel : sqlite transactions are always `SERIALIZABLE`
+func (s sqlite) SupportIsolationLevel(level sql.IsolationLevel) bool {
+	switch level {
+	case sql.LevelDefault, sql.LevelSerializable:
+		return true
+	}
+	return false
+}
+
+// IsRetryable : the database is busy when it's locked by another connection
+func (s sqlite) IsRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+	msg := err.Error()
+	for _, m := range []string{"database is locked", "database table is locked"} {
+		if strings.Contains(msg, m) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsDuplicate : the unique index is violated when the constraint is failed
+func (s sqlite) IsDuplicate(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "UNIQUE constraint failed")
+}
+
+// LockClause : sqlite locks the whole database, there is no `FOR UPDATE` or `LOCK IN SHARE MODE`
+func (s sqlite) LockClause(mode locked, opt lockOption) (string, error) {
+	if opt > 0 {
+		return "", fmt.Errorf("goloquent: lock option is not supported by sqlite")
+	}
+	return "", nil
+}
+
+// UnionSelect : sqlite doesn't allow the parentheses on the select of the union, so it's selected as a sub query
+func (s sqlite) UnionSelect(ss string) string {
+	return "SELECT * FROM (" + ss + ")"
+}
+
+// ReplaceInto : sqlite uses `INSERT OR REPLACE`, which is the same as the `REPLACE` of mysql
+func (s *sqlite) ReplaceInto(table string, cols []string, query string) string {
+	return fmt.Sprintf("INSERT OR REPLACE INTO %s (%s) %s;", s.GetTable(table), quoteColumns(s.Quote, cols), query)
+}
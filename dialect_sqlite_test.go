@@ -0,0 +1,119 @@
+package goloquent
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/datastore"
+)
+
+func TestSQLiteDataType(t *testing.T) {
+	type user struct {
+		Key       *datastore.Key `goloquent:"__key__"`
+		Name      string
+		Age       uint8
+		Active    bool
+		Balance   float64
+		Avatar    []byte
+		Tags      []string
+		Nickname  *string
+		CreatedAt time.Time
+		Status    string `goloquent:",enum=active|inactive"`
+	}
+
+	codec, err := getStructCodec(new(user))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := new(sqlite)
+	expected := []string{
+		"TEXT NOT NULL",
+		"TEXT NOT NULL DEFAULT ''",
+		"INTEGER NOT NULL DEFAULT 0",
+		"INTEGER NOT NULL DEFAULT 0",
+		"REAL NOT NULL DEFAULT 0",
+		"BLOB NOT NULL",
+		"TEXT NOT NULL",
+		"TEXT",
+		"TEXT NOT NULL DEFAULT '0001-01-01 00:00:00'",
+		`TEXT CHECK ("Status" IN ('active','inactive')) NOT NULL DEFAULT 'active'`,
+	}
+	for i, c := range getColumns(nil, codec) {
+		if dt := s.DataType(s.GetSchema(c)[0]); dt != expected[i] {
+			t.Fatalf("Unexpected data type of %q, expected %q, but get %q", c.Name(), expected[i], dt)
+		}
+	}
+}
+
+func TestSQLiteStatement(t *testing.T) {
+	q := newTestQuery(new(sqlite), "User").
+		Where("Name", "=", "O'Neil").
+		WhereJSONLength("Tags", ">", 1).
+		WLock()
+	raw, args, err := q.ToSQL()
+	if err != nil {
+		t.Fatalf("Unexpected error, %v", err)
+	}
+	if raw != `SELECT * FROM "User" WHERE "Name" = ? AND json_array_length("Tags") > ?;` {
+		t.Fatalf("Unexpected statement, %q", raw)
+	}
+	if len(args) != 2 {
+		t.Fatalf("Unexpected arguments, %v", args)
+	}
+
+	str, err := q.ToSQLInterpolated()
+	if err != nil {
+		t.Fatalf("Unexpected error, %v", err)
+	}
+	if str != `SELECT * FROM "User" WHERE "Name" = 'O''Neil' AND json_array_length("Tags") > 1;` {
+		t.Fatalf("Unexpected interpolated statement, %q", str)
+	}
+
+	s := new(sqlite)
+	if c := s.OnConflictUpdate("User", nil, []string{"Name", "Age"}); c != `ON CONFLICT ("$Key") DO UPDATE SET "Name" = excluded."Name","Age" = excluded."Age"` {
+		t.Fatalf("Unexpected conflict statement, %q", c)
+	}
+	if c, _ := s.LockClause(WriteLock, 0); c != "" {
+		t.Fatal("Expected sqlite doesn't support row lock")
+	}
+	if _, isOk := GetDialect("sqlite3"); !isOk {
+		t.Fatal("Expected sqlite dialect to be registered")
+	}
+}
+
+func TestSQLiteForeignKey(t *testing.T) {
+	type comment struct {
+		Key  *datastore.Key `goloquent:"__key__"`
+		Post *datastore.Key `goloquent:"PostKey,references=Post,ondelete=cascade"`
+	}
+	e, err := newEntity(new(comment))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := new(sqlite)
+	stmts, err := s.CreateTable("comment", e.columns)
+	if err != nil {
+		t.Fatalf("Unexpected error, %v", err)
+	}
+	if len(stmts) != 2 || stmts[0] != `CREATE TABLE IF NOT EXISTS "comment" (`+
+		`"$Key" TEXT NOT NULL,"PostKey" TEXT,PRIMARY KEY ("$Key"),`+
+		`CONSTRAINT "comment_PostKey_fk" FOREIGN KEY ("PostKey") REFERENCES "Post" ("$Key") ON DELETE CASCADE);` ||
+		stmts[1] != `CREATE INDEX IF NOT EXISTS "comment_PostKey_idx" ON "comment" ("PostKey");` {
+		t.Fatalf("Unexpected statements, %v", stmts)
+	}
+}
+
+func TestSQLiteJSONContains(t *testing.T) {
+	q := newTestQuery(new(sqlite), "User").WhereJSONContains("Nicknames", "Joe")
+	raw, args, err := q.ToSQL()
+	if err != nil {
+		t.Fatalf("Unexpected error, %v", err)
+	}
+	if raw != `SELECT * FROM "User" WHERE (json("Nicknames") = json(?) OR EXISTS (SELECT 1 FROM json_each("Nicknames") WHERE json_quote(value) = json(?)));` {
+		t.Fatalf("Unexpected statement, %q", raw)
+	}
+	if len(args) != 2 || fmt.Sprintf("%s", args[0]) != `"Joe"` || fmt.Sprintf("%s", args[1]) != `"Joe"` {
+		t.Fatalf("Unexpected arguments, %v", args)
+	}
+}
@@ -284,6 +284,10 @@ func saveField(f field, v reflect.Value) (interface{}, error) {
 		switch t.Kind() {
 		case reflect.String:
 			it = v.String()
+			// the empty string isn't a valid decimal value
+			if it == "" && f.IsDecimal() {
+				it = "0"
+			}
 		case reflect.Bool:
 			it = v.Bool()
 		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
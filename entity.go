@@ -3,6 +3,7 @@ package goloquent
 import (
 	"fmt"
 	"reflect"
+	"sort"
 	"strings"
 )
 
@@ -10,6 +11,9 @@ import (
 type Column struct {
 	names []string
 	field field
+	pk    string
+	// alias is the column name in the table when it's different from the field name, such as the soft delete column
+	alias string
 }
 
 // Name :
@@ -17,6 +21,37 @@ func (c Column) Name() string {
 	return strings.Join(c.names, ".")
 }
 
+// column will return the column name in the table
+func (c Column) column() string {
+	if c.alias != "" {
+		return c.alias
+	}
+	return c.Name()
+}
+
+// oldName will return the column name before it's renamed, which is declared by the `oldname` of the tag
+func (c Column) oldName() string {
+	return c.field.Get("oldname")
+}
+
+// primaryKey will return the column name of the primary key, it's `$Key` unless the model declared it
+func (c Column) primaryKey() string {
+	if c.pk != "" {
+		return c.pk
+	}
+	return pkColumn
+}
+
+// primaryKeyColumn will return the primary key column name of the columns
+func primaryKeyColumn(columns []Column) string {
+	for _, c := range columns {
+		if c.Name() == keyFieldName {
+			return c.primaryKey()
+		}
+	}
+	return pkColumn
+}
+
 func getColumns(prefix []string, codec *StructCodec) []Column {
 	columns := make([]Column, 0)
 	for _, f := range codec.fields {
@@ -35,6 +70,72 @@ func getColumns(prefix []string, codec *StructCodec) []Column {
 	return columns
 }
 
+// Tabler : the model can implement `TableName` when the table name is different from the struct name,
+// the table name set using `Table` always has the highest priority
+type Tabler interface {
+	TableName() string
+}
+
+// tableName will return the table name of the struct type, it's the `TableName` if it implements `Tabler`
+func tableName(t reflect.Type) string {
+	if t.Kind() == reflect.Struct {
+		if tb, isOk := reflect.New(t).Interface().(Tabler); isOk {
+			if name := strings.TrimSpace(tb.TableName()); name != "" {
+				return name
+			}
+		}
+	}
+	return t.Name()
+}
+
+// uniqueGroups will return the composite unique indexes of the columns which are tagged with
+// the same unique group, such as `unique=email_tenant`, the groups are sorted by name
+func uniqueGroups(columns []Column) [][]string {
+	groups := make(map[string][]string)
+	names := make([]string, 0)
+	for _, c := range columns {
+		g := c.field.Get("unique")
+		if g == "" {
+			continue
+		}
+		if _, isExist := groups[g]; !isExist {
+			names = append(names, g)
+		}
+		groups[g] = append(groups[g], c.Name())
+	}
+	sort.Strings(names)
+	idxs := make([][]string, len(names))
+	for i, g := range names {
+		idxs[i] = groups[g]
+	}
+	return idxs
+}
+
+// PrimaryKeyer : the model can implement `PrimaryKey` when the primary key column is not `$Key`,
+// it can be declared using the blank field `_ struct{}` with the tag `goloquent:"primaryKey:id"` as well
+type PrimaryKeyer interface {
+	PrimaryKey() string
+}
+
+// primaryKey will return the primary key column of the struct type, the `PrimaryKey` of the `PrimaryKeyer`
+// has higher priority than the tag, and it's empty when it's not declared
+func primaryKey(t reflect.Type) string {
+	if t.Kind() != reflect.Struct {
+		return ""
+	}
+	if pk, isOk := reflect.New(t).Interface().(PrimaryKeyer); isOk {
+		if name := strings.TrimSpace(pk.PrimaryKey()); name != "" {
+			return name
+		}
+	}
+	for _, name := range blankFieldTags(t, "primaryKey") {
+		if name = strings.TrimSpace(name); name != "" {
+			return name
+		}
+	}
+	return ""
+}
+
 // convertMulti will convert any single model to pointer of []model
 func convertMulti(v reflect.Value) reflect.Value {
 	vi := reflect.MakeSlice(reflect.SliceOf(v.Type()), 1, 1)
@@ -52,10 +153,19 @@ type entity struct {
 	codec      *StructCodec
 	fields     map[string]Column
 	columns    []Column
+	uniques    [][]string
+	pk         string
+	deleted    string
 }
 
-// TODO: check primary key must present
 func newEntity(it interface{}) (*entity, error) {
+	return newEntityWith(it, pkColumn, softDeleteColumn)
+}
+
+// newEntityWith will create the entity using `pk` as the primary key column unless the model declared
+// its own, and `deleted` as the soft delete column
+// TODO: check primary key must present
+func newEntityWith(it interface{}, pk, deleted string) (*entity, error) {
 	v := reflect.ValueOf(it)
 	if v.Kind() != reflect.Ptr {
 		return nil, fmt.Errorf("goloquent: model is not addressable")
@@ -85,24 +195,43 @@ func newEntity(it interface{}) (*entity, error) {
 		return nil, err
 	}
 
+	if name := primaryKey(t); name != "" {
+		pk = name
+	}
 	fields := make(map[string]Column)
 	cols := getColumns(nil, codec)
-	for _, c := range cols {
-		fields[c.Name()] = c
+	for i, c := range cols {
+		switch c.Name() {
+		case keyFieldName:
+			cols[i].pk = pk
+		case softDeleteColumn:
+			cols[i].alias = deleted
+		}
+		fields[c.Name()] = cols[i]
 	}
 
 	if _, hasKey := fields[keyFieldName]; !hasKey {
 		return nil, fmt.Errorf("goloquent: entity %v doesn't has primary key property", t)
 	}
+	if _, isExist := fields[pk]; isExist {
+		return nil, fmt.Errorf("goloquent: entity %v has field %q conflicts with the primary key", t, pk)
+	}
+	_, hasSoftDelete := fields[softDeleteColumn]
+	if _, isExist := fields[deleted]; isExist && hasSoftDelete && deleted != softDeleteColumn {
+		return nil, fmt.Errorf("goloquent: entity %v has field %q conflicts with the soft delete column", t, deleted)
+	}
 
 	return &entity{
-		name:       t.Name(),
+		name:       tableName(t),
 		typeOf:     t,
 		isMultiPtr: isMultiPtr,
 		codec:      codec,
 		slice:      v,
 		fields:     fields,
 		columns:    cols,
+		uniques:    append(uniqueTogether(t), uniqueGroups(cols)...),
+		pk:         pk,
+		deleted:    deleted,
 	}, nil
 }
 
@@ -129,11 +258,19 @@ func (e *entity) Name() string {
 func (e *entity) Columns() (cols []string) {
 	cols = make([]string, 0, len(e.columns))
 	for _, c := range e.columns {
-		if c.Name() == keyFieldName {
-			cols = append(cols, pkColumn)
-			continue
-		}
-		cols = append(cols, c.Name())
+		cols = append(cols, e.column(c.Name()))
 	}
 	return
 }
+
+// column will return the column name in the table of the field, the primary key and
+// soft delete column are renamed according to the entity
+func (e *entity) column(name string) string {
+	switch name {
+	case keyFieldName:
+		return e.pk
+	case softDeleteColumn:
+		return e.deleted
+	}
+	return name
+}
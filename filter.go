@@ -1,6 +1,7 @@
 package goloquent
 
 import (
+	"encoding/json"
 	"fmt"
 	"reflect"
 	"time"
@@ -14,6 +15,12 @@ type Filter struct {
 	operator operator
 	value    interface{}
 	isJSON   bool
+	group    []Filter
+	or       bool     // join with the preceding condition using `OR` instead of `AND`
+	cmp      operator // comparison operator of `JSONLength`
+	fields   []string // columns of the full-text search `Match`
+	escape   string   // the pattern of the escaped value of `Like`, such as `%s%%`, the wildcards in the value are matched literally
+	column   string   // the right hand side column of `WhereColumn`, nothing is bound
 }
 
 // Field :
@@ -26,6 +33,25 @@ func (f Filter) IsJSON() bool {
 	return f.isJSON
 }
 
+// Comparison : the comparison operator of `JSONLength` filter
+func (f Filter) Comparison() (string, error) {
+	switch f.cmp {
+	case Equal:
+		return "=", nil
+	case NotEqual:
+		return "<>", nil
+	case GreaterThan:
+		return ">", nil
+	case GreaterEqual:
+		return ">=", nil
+	case LessThan:
+		return "<", nil
+	case LessEqual:
+		return "<=", nil
+	}
+	return "", fmt.Errorf("goloquent: invalid comparison operator")
+}
+
 // JSON :
 type JSON struct {
 }
@@ -62,6 +88,8 @@ func normalizeValue(val interface{}) (interface{}, error) {
 		it = vi
 	case datastore.GeoPoint:
 		it = geoLocation{vi.Lat, vi.Lng}
+	case json.RawMessage:
+		it = vi
 	case time.Time:
 		it = vi
 	case Date:
@@ -2,6 +2,7 @@ package goloquent
 
 import (
 	"bytes"
+	"database/sql"
 	"encoding/json"
 	"fmt"
 	"reflect"
@@ -21,9 +22,31 @@ type Saver interface {
 	Save() error
 }
 
+// BeforeCreateHook : will be called before the entity is inserted
+type BeforeCreateHook interface {
+	BeforeCreate() error
+}
+
+// AfterCreateHook : will be called after the entity is inserted, the primary key is populated
+type AfterCreateHook interface {
+	AfterCreate() error
+}
+
+// BeforeUpdateHook : will be called before the entity is saved
+type BeforeUpdateHook interface {
+	BeforeUpdate() error
+}
+
+// AfterUpdateHook : will be called after the entity is saved
+type AfterUpdateHook interface {
+	AfterUpdate() error
+}
+
 // Iterator :
 type Iterator struct {
 	table    string
+	pk       string
+	deleted  string
 	stmt     *Stmt
 	sign     string
 	position int // current record position
@@ -32,12 +55,16 @@ type Iterator struct {
 }
 
 func (it *Iterator) patchKey() {
+	pk := it.pk
+	if pk == "" {
+		pk = pkColumn
+	}
 	pos := len(it.results) - 1
 	l := it.results[pos]
-	if _, isOk := l[pkColumn]; !isOk {
+	if _, isOk := l[pk]; !isOk {
 		return
 	}
-	paths := bytes.Split(l[pkColumn], []byte(keyDelimeter))
+	paths := bytes.Split(l[pk], []byte(keyDelimeter))
 	last := len(paths) - 1
 	kk := paths[last]
 	paths = paths[:last]
@@ -75,6 +102,28 @@ func (it *Iterator) put(pos int, k string, v interface{}) error {
 	return nil
 }
 
+// scanRow will scan the current row of `rows` into the position
+func (it *Iterator) scanRow(rows *sql.Rows, pos int) error {
+	m := make([]interface{}, len(it.columns))
+	for j := range it.columns {
+		m[j] = &m[j]
+	}
+
+	if err := rows.Scan(m...); err != nil {
+		return err
+	}
+
+	for j, name := range it.columns {
+		// the soft delete column is loaded into the `SoftDelete` field when it's renamed
+		if it.deleted != "" && name == it.deleted {
+			name = softDeleteColumn
+		}
+		it.put(pos, name, m[j])
+	}
+	it.patchKey()
+	return nil
+}
+
 // First :
 func (it *Iterator) First() *Iterator {
 	it.position = 0
@@ -172,7 +221,7 @@ func (it *Iterator) scan(src interface{}) (map[string]interface{}, error) {
 
 	if l, isOk := nv.Interface().(Loader); isOk {
 		if err := l.Load(); err != nil {
-			return nil, fmt.Errorf("goloquent: %v", err)
+			return nil, fmt.Errorf("goloquent: %w", err)
 		}
 	}
 
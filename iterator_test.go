@@ -0,0 +1,68 @@
+package goloquent
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+type loaderModel struct {
+	Name    string
+	Display string `goloquent:"-"`
+}
+
+func (m *loaderModel) Load() error {
+	if m.Name == "" {
+		return errors.New("name is empty")
+	}
+	m.Display = strings.ToUpper(m.Name)
+	return nil
+}
+
+func TestIteratorLoader(t *testing.T) {
+	it := &Iterator{
+		position: 0,
+		results: []map[string][]byte{
+			{"Name": []byte("joe")},
+			{"Name": nil},
+		},
+	}
+
+	m := new(loaderModel)
+	if err := it.Scan(m); err != nil {
+		t.Fatalf("Unexpected error, %v", err)
+	}
+	if m.Name != "joe" || m.Display != "JOE" {
+		t.Fatalf("Unexpected result from `Load`, %v", m)
+	}
+
+	it.Next()
+	m = new(loaderModel)
+	if err := it.Scan(m); err == nil {
+		t.Fatal("Expected error from `Load` should abort the scan")
+	}
+	if m.Name != "" || m.Display != "" {
+		t.Fatal("Expected model should remain untouched when `Load` failed")
+	}
+}
+
+func TestIteratorScanProjection(t *testing.T) {
+	it := &Iterator{
+		position: 0,
+		results: []map[string][]byte{
+			{"Status": []byte("ACTIVE"), "Total": []byte("12")},
+		},
+	}
+
+	var result struct {
+		Status string
+		Total  int64
+		Avg    float64
+	}
+	if err := it.Scan(&result); err != nil {
+		t.Fatalf("Unexpected error, %v", err)
+	}
+	if result.Status != "ACTIVE" || result.Total != 12 || result.Avg != 0 {
+		t.Fatalf("Unexpected result, %v", result)
+	}
+}
@@ -2,10 +2,13 @@ package goloquent
 
 import (
 	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"reflect"
+	"sort"
 	"strings"
+	"time"
 
 	"cloud.google.com/go/datastore"
 )
@@ -31,8 +34,23 @@ const (
 	IsObject
 	IsArray
 	IsType
+	Between
+	NotBetween
+	JSONContains
+	JSONLength
+	Match
 )
 
+// columnOperators are the operators which are able to compare two columns
+var columnOperators = map[operator]string{
+	Equal:        "=",
+	NotEqual:     "<>",
+	GreaterThan:  ">",
+	GreaterEqual: ">=",
+	LessThan:     "<",
+	LessEqual:    "<=",
+}
+
 type sortDirection int
 
 const (
@@ -47,10 +65,22 @@ type order struct {
 
 type locked int
 
-// lock mode
+// lock mode, `WriteLockSkipLocked` and `WriteLockNoWait` are the write lock
+// with `SkipLocked` and `NoWait` option respectively
 const (
 	ReadLock locked = iota + 1
 	WriteLock
+	WriteLockSkipLocked
+	WriteLockNoWait
+)
+
+type lockOption int
+
+// lock option, the rows locked by other transaction will be skipped,
+// or the statement will fail immediately instead of waiting
+const (
+	lockSkipLocked lockOption = iota + 1
+	lockNoWait
 )
 
 const (
@@ -70,24 +100,64 @@ func checkSinglePtr(it interface{}) error {
 	return nil
 }
 
+// rawSelect is the raw expression of the projection, it will be placed before the projection at `index`
+type rawSelect struct {
+	index int
+	expr  string
+	args  []interface{}
+}
+
+type join struct {
+	kind       string
+	table      string
+	localCol   string
+	foreignCol string
+}
+
+// union is the query combined with `Union` or `UnionAll`
+type union struct {
+	all   bool
+	query scope
+}
+
 type group struct {
 	isGroup bool
 	data    []interface{}
 }
 
+// filterGroup will collect the filters within `WhereGroup`, it's shared by all the derived queries
+type filterGroup struct {
+	filters []Filter
+	errs    []error
+}
+
 type scope struct {
-	table      string
-	distinctOn []string
-	projection []string
-	omits      []string
-	ancestors  []group
-	filters    []Filter
-	orders     []order
-	limit      int32
-	offset     int32
-	errs       []error
-	noScope    bool
-	lockMode   locked
+	table          string
+	pk             string
+	distinctOn     []string
+	projection     []string
+	rawSelects     []rawSelect
+	joins          []join
+	unions         []union
+	omits          []string
+	ancestors      []group
+	ancestorDepth  int
+	filters        []Filter
+	groupBy        []string
+	havings        []Filter
+	orders         []order
+	limit          int32
+	offset         int32
+	errs           []error
+	noScope        bool
+	onlyTrashed    bool
+	lockMode       locked
+	lockOption     lockOption
+	usePrimary     bool
+	timeout        time.Duration
+	noTimestamps   bool
+	withZeroValues bool
+	group          *filterGroup
 }
 
 // Query :
@@ -100,8 +170,9 @@ func newQuery(db *DB) *Query {
 	return &Query{
 		db: db.clone(),
 		scope: scope{
-			limit:  -1,
-			offset: -1,
+			limit:        -1,
+			offset:       -1,
+			noTimestamps: db.noTimestamps,
 		},
 	}
 }
@@ -121,6 +192,37 @@ func (q *Query) append(query *Query) *Query {
 	return q
 }
 
+// enclose will wrap the filters into a group when any of them is joined by `OR`,
+// so the filters appended afterward apply to all of them instead of the last `OR` condition
+func (q *Query) enclose() *Query {
+	q = q.clone()
+	q.filters = encloseFilters(q.filters)
+	return q
+}
+
+func encloseFilters(filters []Filter) []Filter {
+	for _, f := range filters {
+		if f.or {
+			return []Filter{{group: filters}}
+		}
+	}
+	return filters
+}
+
+func (q *Query) addFilter(f Filter) {
+	q.filters = append(q.filters, f)
+	if q.group != nil {
+		q.group.filters = append(q.group.filters, f)
+	}
+}
+
+func (q *Query) addError(err error) {
+	q.errs = append(q.errs, err)
+	if q.group != nil {
+		q.group.errs = append(q.group.errs, err)
+	}
+}
+
 func (q *Query) getError() error {
 	if len(q.errs) > 0 {
 		buf := new(bytes.Buffer)
@@ -148,6 +250,27 @@ func (q *Query) Select(fields ...string) *Query {
 	return q
 }
 
+// SelectRaw : select the raw expression without quoting, such as `COUNT(*) AS total`.
+// Use `?` as the placeholder of the arguments.
+func (q *Query) SelectRaw(expr string, args ...interface{}) *Query {
+	q = q.clone()
+	expr = strings.TrimSpace(expr)
+	if expr == "" {
+		q.addError(fmt.Errorf("goloquent: invalid `SelectRaw` value %q", expr))
+		return q
+	}
+	if n := strings.Count(expr, "?"); n != len(args) {
+		q.addError(fmt.Errorf("goloquent: `SelectRaw` expect %d arguments, but get %d", n, len(args)))
+		return q
+	}
+	q.rawSelects = append(q.rawSelects, rawSelect{
+		index: len(q.projection),
+		expr:  strings.Replace(expr, "?", variable, -1),
+		args:  args,
+	})
+	return q
+}
+
 // DistinctOn :
 func (q *Query) DistinctOn(fields ...string) *Query {
 	q = q.clone()
@@ -180,6 +303,7 @@ func (q *Query) Omit(fields ...string) *Query {
 	dict := newDictionary(append(q.projection, arr...))
 	dict.delete(keyFieldName)
 	dict.delete(pkColumn)
+	dict.delete(q.db.client.keyColumn())
 	q.omits = dict.keys()
 	return q
 }
@@ -190,6 +314,21 @@ func (q *Query) Unscoped() *Query {
 	return q
 }
 
+// WithTrashed : include the soft deleted records
+func (q *Query) WithTrashed() *Query {
+	q = q.clone()
+	q.noScope = true
+	q.onlyTrashed = false
+	return q
+}
+
+// OnlyTrashed : only the soft deleted records will be retrieved
+func (q *Query) OnlyTrashed() *Query {
+	q = q.clone()
+	q.onlyTrashed = true
+	return q
+}
+
 // Find :
 func (q *Query) Find(key *datastore.Key, model interface{}) error {
 	if err := q.getError(); err != nil {
@@ -201,10 +340,33 @@ func (q *Query) Find(key *datastore.Key, model interface{}) error {
 	if key == nil || key.Incomplete() {
 		return fmt.Errorf("goloquent: find action with invalid key value, %q", key)
 	}
-	q = q.Where(keyFieldName, "=", key).Limit(1)
+	q = q.enclose().Where(keyFieldName, "=", key).Limit(1)
 	return newBuilder(q).get(model, true)
 }
 
+// FindMulti : get the records of the keys, the records are in the same order as the keys. The element of
+// the missing record is left as zero value (nil for slice of pointer), and the missing keys are returned
+// as `NotFoundError`, the records of the other keys are still loaded
+func (q *Query) FindMulti(keys []*datastore.Key, model interface{}) error {
+	if err := q.getError(); err != nil {
+		return err
+	}
+	v := reflect.ValueOf(model)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("goloquent: model must be pointer of slice")
+	}
+	for _, k := range keys {
+		if k == nil || k.Incomplete() {
+			return fmt.Errorf("goloquent: find action with invalid key value, %q", k)
+		}
+	}
+	q = q.enclose()
+	if len(keys) > 0 {
+		q = q.WhereIn(keyFieldName, keys)
+	}
+	return newBuilder(q).findMulti(keys, model)
+}
+
 // First :
 func (q *Query) First(model interface{}) error {
 	q = q.clone()
@@ -218,6 +380,50 @@ func (q *Query) First(model interface{}) error {
 	return newBuilder(q).get(model, false)
 }
 
+// FirstOrCreate : retrieve the first record which match the query and the attributes, or create
+// the record when there is no such record. The attributes and the equal filters of the query will
+// be set to the new record. Soft deleted records are not matched unless the query is `Unscoped`.
+// The lookup is locked for update, and the table requires an unique index on the attributes, so the
+// record created concurrently is rejected and retrieved instead of being created twice.
+func (q *Query) FirstOrCreate(model interface{}, attrs map[string]interface{}) error {
+	keys := make([]string, 0, len(attrs))
+	for k := range attrs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	q = q.enclose()
+	for _, k := range keys {
+		q = q.Where(k, "=", attrs[k])
+	}
+	if err := q.getError(); err != nil {
+		return err
+	}
+	if err := checkSinglePtr(model); err != nil {
+		return err
+	}
+	return newBuilder(q).firstOrCreate(model)
+}
+
+// UpdateOrCreate : update the first record which match the query and the attributes with the values,
+// or create the record with the attributes and the values when there is no such record. It returns
+// the primary key of the updated or created record, the table must be set using `Table`. Same as
+// `FirstOrCreate`, the table requires an unique index on the attributes.
+func (q *Query) UpdateOrCreate(attrs, values map[string]interface{}) (*datastore.Key, error) {
+	keys := make([]string, 0, len(attrs))
+	for k := range attrs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	q = q.enclose()
+	for _, k := range keys {
+		q = q.Where(k, "=", attrs[k])
+	}
+	if err := q.getError(); err != nil {
+		return nil, err
+	}
+	return newBuilder(q).updateOrCreate(attrs, values)
+}
+
 // Get :
 func (q *Query) Get(model interface{}) error {
 	q = q.clone()
@@ -227,12 +433,83 @@ func (q *Query) Get(model interface{}) error {
 	return newBuilder(q).getMulti(model)
 }
 
+// ToSQL : return the select statement and the arguments of the query without executing it.
+// The table is resolved from the model if it's provided, and the soft delete scope
+// only applies when the model has soft delete.
+func (q *Query) ToSQL(model ...interface{}) (string, []interface{}, error) {
+	if err := q.getError(); err != nil {
+		return "", nil, err
+	}
+	ss, err := newBuilder(q).getStmt(model...)
+	if err != nil {
+		return "", nil, err
+	}
+	return ss.Raw(), ss.Arguments(), nil
+}
+
+// ToSQLInterpolated : same as `ToSQL`, but the arguments are interpolated into the statement,
+// it's only meant for debugging
+func (q *Query) ToSQLInterpolated(model ...interface{}) (string, error) {
+	if err := q.getError(); err != nil {
+		return "", err
+	}
+	ss, err := newBuilder(q).getStmt(model...)
+	if err != nil {
+		return "", err
+	}
+	return ss.String(), nil
+}
+
+// ToUpdateSQL : return the statement and the arguments of `Update` without executing it,
+// the statement is empty when there is nothing to update. The columns of the table are read
+// to maintain `UpdatedAt` when updating using map, unless `WithoutTimestamps`.
+func (q *Query) ToUpdateSQL(v interface{}) (string, []interface{}, error) {
+	if err := q.getError(); err != nil {
+		return "", nil, err
+	}
+	q = q.Order(keyFieldName)
+	b := newBuilder(q)
+	cmd, err := b.updateStmt(v)
+	if err != nil || cmd == nil {
+		return "", nil, err
+	}
+	ss := &Stmt{stmt: *cmd, replacer: b.db.dialect}
+	return ss.Raw(), ss.Arguments(), nil
+}
+
+// ToDeleteSQL : return the statement and the arguments of `Flush` without executing it
+func (q *Query) ToDeleteSQL() (string, []interface{}, error) {
+	if err := q.getError(); err != nil {
+		return "", nil, err
+	}
+	if q.table == "" {
+		return "", nil, fmt.Errorf("goloquent: unable to perform delete without table name")
+	}
+	b := newBuilder(q)
+	cmd, err := b.deleteByQueryStmt()
+	if err != nil {
+		return "", nil, err
+	}
+	ss := &Stmt{stmt: *cmd, replacer: b.db.dialect}
+	return ss.Raw(), ss.Arguments(), nil
+}
+
+// GetMaps : get the records as maps keyed by column name, the table must be set using `Table`.
+// Byte values are returned as string, and NULL as nil.
+func (q *Query) GetMaps(dest *[]map[string]interface{}) error {
+	q = q.clone()
+	if err := q.getError(); err != nil {
+		return err
+	}
+	return newBuilder(q).getMaps(dest)
+}
+
 // Paginate :
 func (q *Query) Paginate(p *Pagination, model interface{}) error {
 	if err := q.getError(); err != nil {
 		return err
 	}
-	q = q.clone()
+	q = q.enclose()
 	if p.query != nil {
 		q = q.append(p.query)
 	}
@@ -242,16 +519,117 @@ func (q *Query) Paginate(p *Pagination, model interface{}) error {
 		p.Limit = defaultLimit
 	}
 	q = q.Limit(int(p.Limit) + 1)
-	if len(q.orders) > 0 {
-		if q.orders[len(q.orders)-1].field != pkColumn {
-			q = q.Order(pkColumn)
-		}
-	} else {
-		q = q.Order(pkColumn)
+	// the primary key must be the last sorting field, so the cursor is stable
+	if n := len(q.orders); n <= 0 ||
+		(q.orders[n-1].field != pkColumn && q.orders[n-1].field != keyFieldName) {
+		q = q.Order(keyFieldName)
 	}
 	return newBuilder(q).paginate(p, model)
 }
 
+// Each : scan the records one by one without buffering all the records, `model` must be pointer of struct.
+// A new struct of the model type is passed to `fn` for every record, the iteration will stop when `fn` return error.
+// The connection is held until the iteration end, so avoid running query within `fn` inside transaction.
+func (q *Query) Each(model interface{}, fn func(model interface{}) error) error {
+	if err := q.getError(); err != nil {
+		return err
+	}
+	if err := checkSinglePtr(model); err != nil {
+		return err
+	}
+	return newBuilder(q).each(model, fn)
+}
+
+// Chunk : retrieve the records in batches of `size` using `LIMIT` and `OFFSET`, `model` will be filled with
+// each batch before passing to `fn`. The records are sorted by `$Key` after the existing orders, so the paging is stable.
+// The existing `Offset` is where the iteration start, and the existing `Limit` is the total number of records to retrieve.
+// The iteration will stop when `fn` return error or the batch has fewer records than `size`.
+func (q *Query) Chunk(model interface{}, size int, fn func(model interface{}) error) error {
+	if err := q.getError(); err != nil {
+		return err
+	}
+	if size <= 0 {
+		return fmt.Errorf("goloquent: chunk size must be greater than zero, but get %d", size)
+	}
+	if v := reflect.ValueOf(model); v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("goloquent: model must be pointer of slice")
+	}
+	q = q.clone()
+	if n := len(q.orders); n <= 0 ||
+		(q.orders[n-1].field != pkColumn && q.orders[n-1].field != keyFieldName) {
+		q = q.Order(keyFieldName)
+	}
+	start, remain := 0, -1
+	if q.offset > 0 {
+		start = int(q.offset)
+	}
+	if q.limit >= 0 {
+		remain = int(q.limit)
+	}
+	for offset := start; remain != 0; offset += size {
+		limit := size
+		if remain > 0 && remain < limit {
+			limit = remain
+		}
+		if err := newBuilder(q.Limit(limit).Offset(offset)).getMulti(model); err != nil {
+			return err
+		}
+		n := reflect.Indirect(reflect.ValueOf(model)).Len()
+		if n <= 0 {
+			return nil
+		}
+		if err := fn(model); err != nil {
+			return err
+		}
+		if n < limit {
+			return nil
+		}
+		if remain > 0 {
+			remain -= n
+		}
+	}
+	return nil
+}
+
+// Join : join the table on `localCol` = `foreignCol`, the column can be qualified as `table.column`
+func (q *Query) Join(table, localCol, foreignCol string) *Query {
+	return q.join("JOIN", table, localCol, foreignCol)
+}
+
+// LeftJoin :
+func (q *Query) LeftJoin(table, localCol, foreignCol string) *Query {
+	return q.join("LEFT JOIN", table, localCol, foreignCol)
+}
+
+func (q *Query) join(kind, table, localCol, foreignCol string) *Query {
+	q = q.clone()
+	table = strings.TrimSpace(table)
+	localCol, foreignCol = strings.TrimSpace(localCol), strings.TrimSpace(foreignCol)
+	if table == "" || localCol == "" || foreignCol == "" {
+		q.addError(fmt.Errorf("goloquent: invalid join on table %q", table))
+		return q
+	}
+	q.joins = append(q.joins, join{
+		kind:       kind,
+		table:      table,
+		localCol:   localCol,
+		foreignCol: foreignCol,
+	})
+	return q
+}
+
+func (q scope) hasTable(table string) bool {
+	if table == q.table {
+		return true
+	}
+	for _, j := range q.joins {
+		if j.table == table {
+			return true
+		}
+	}
+	return false
+}
+
 // Ancestor :
 func (q *Query) Ancestor(ancestor *datastore.Key) *Query {
 	if ancestor == nil {
@@ -267,6 +645,18 @@ func (q *Query) Ancestor(ancestor *datastore.Key) *Query {
 	return q
 }
 
+// AncestorDepth : restrict the descendants of the `Ancestor` to the depth, the depth `1` will only
+// match the direct children, and zero (default) will match the descendants at any depth
+func (q *Query) AncestorDepth(depth int) *Query {
+	q = q.clone()
+	if depth < 0 {
+		q.errs = append(q.errs, fmt.Errorf("goloquent: ancestor depth cannot be negative, %d", depth))
+		return q
+	}
+	q.ancestorDepth = depth
+	return q
+}
+
 // AnyOfAncestor :
 func (q *Query) AnyOfAncestor(ancestors ...*datastore.Key) *Query {
 	if len(ancestors) <= 0 {
@@ -290,63 +680,74 @@ func (q *Query) AnyOfAncestor(ancestors ...*datastore.Key) *Query {
 	return q
 }
 
-func (q *Query) where(field, op string, value interface{}, isJSON bool) *Query {
+func parseOperator(op string, isJSON bool) (operator, error) {
 	op = strings.TrimSpace(strings.ToLower(op))
-	var optr operator
-
 	switch op {
 	case "=", "eq", "$eq", "equal":
-		optr = Equal
+		return Equal, nil
 	case "!=", "<>", "ne", "$ne", "notequal", "not equal":
-		optr = NotEqual
+		return NotEqual, nil
 	case ">", "!<", "gt", "$gt":
-		optr = GreaterThan
+		return GreaterThan, nil
 	case "<", "!>", "lt", "$lt":
-		optr = LessThan
+		return LessThan, nil
 	case ">=", "gte", "$gte":
-		optr = GreaterEqual
+		return GreaterEqual, nil
 	case "<=", "lte", "$lte":
-		optr = LessEqual
+		return LessEqual, nil
 	case "in", "$in":
-		optr = In
+		return In, nil
 	case "nin", "!in", "$nin", "not in", "notin":
-		optr = NotIn
+		return NotIn, nil
 	case "anylike":
-		optr = AnyLike
+		return AnyLike, nil
 	case "like", "$like":
 		if isJSON {
-			q.errs = append(q.errs, fmt.Errorf("goloquent: invalid operator %q for json", op))
-			return q
+			return 0, fmt.Errorf("goloquent: invalid operator %q for json", op)
 		}
-		optr = Like
+		return Like, nil
 	case "nlike", "!like", "$nlike":
 		if isJSON {
-			q.errs = append(q.errs, fmt.Errorf("goloquent: invalid operator %q for json", op))
-			return q
+			return 0, fmt.Errorf("goloquent: invalid operator %q for json", op)
 		}
-		optr = NotLike
-	default:
-		if !isJSON {
-			q.errs = append(q.errs, fmt.Errorf("goloquent: invalid operator %q", op))
-			return q
+		return NotLike, nil
+	case "between", "$between":
+		if isJSON {
+			return 0, fmt.Errorf("goloquent: invalid operator %q for json", op)
 		}
-
-		switch op {
-		case "containany":
-			optr = ContainAny
-		case "istype":
-			optr = IsType
-		case "isobject":
-			optr = IsObject
-		case "isarray":
-			optr = IsArray
-		default:
-			q.errs = append(q.errs, fmt.Errorf("goloquent: invalid operator %q for json", op))
-			return q
+		return Between, nil
+	case "nbetween", "!between", "$nbetween", "not between", "notbetween":
+		if isJSON {
+			return 0, fmt.Errorf("goloquent: invalid operator %q for json", op)
 		}
+		return NotBetween, nil
+	}
+
+	if !isJSON {
+		return 0, fmt.Errorf("goloquent: invalid operator %q", op)
+	}
+
+	switch op {
+	case "containany":
+		return ContainAny, nil
+	case "istype":
+		return IsType, nil
+	case "isobject":
+		return IsObject, nil
+	case "isarray":
+		return IsArray, nil
+	}
+	return 0, fmt.Errorf("goloquent: invalid operator %q for json", op)
+}
+
+func (q *Query) where(field, op string, value interface{}, isJSON bool) *Query {
+	optr, err := parseOperator(op, isJSON)
+	if err != nil {
+		q.addError(err)
+		return q
 	}
 
-	q.filters = append(q.filters, Filter{
+	q.addFilter(Filter{
 		field:    field,
 		operator: optr,
 		value:    value,
@@ -361,6 +762,56 @@ func (q *Query) Where(field string, op string, value interface{}) *Query {
 	return q.where(field, op, value, false)
 }
 
+// OrWhere : same as `Where`, but the filter will be joined with the preceding filter using `OR`
+func (q *Query) OrWhere(field string, op string, value interface{}) *Query {
+	q = q.clone()
+	optr, err := parseOperator(op, false)
+	if err != nil {
+		q.addError(err)
+		return q
+	}
+
+	q.addFilter(Filter{
+		field:    field,
+		operator: optr,
+		value:    value,
+		or:       true,
+	})
+	return q
+}
+
+// WhereGroup : the filters within the callback will be wrapped in parentheses, they are joined by `AND`
+// unless they are added using `OrWhere`, and the group will be joined with the preceding filter using `AND`
+func (q *Query) WhereGroup(fn func(*Query)) *Query {
+	return q.whereGroup(fn, false)
+}
+
+// OrWhereGroup : same as `WhereGroup`, but the group will be joined with the preceding filter using `OR`
+func (q *Query) OrWhereGroup(fn func(*Query)) *Query {
+	return q.whereGroup(fn, true)
+}
+
+func (q *Query) whereGroup(fn func(*Query), or bool) *Query {
+	q = q.clone()
+	g := new(filterGroup)
+	fn(&Query{
+		db: q.db.clone(),
+		scope: scope{
+			table:  q.table,
+			limit:  -1,
+			offset: -1,
+			group:  g,
+		},
+	})
+	for _, err := range g.errs {
+		q.addError(err)
+	}
+	if len(g.filters) > 0 {
+		q.addFilter(Filter{group: g.filters, or: or})
+	}
+	return q
+}
+
 // WhereEqual :
 func (q *Query) WhereEqual(field string, v interface{}) *Query {
 	return q.Where(field, "=", v)
@@ -381,12 +832,22 @@ func (q *Query) WhereNotNull(field string) *Query {
 	return q.Where(field, "<>", nil)
 }
 
+// OrWhereNull : same as `WhereNull`, but joined with the preceding filter using `OR`
+func (q *Query) OrWhereNull(field string) *Query {
+	return q.OrWhere(field, "=", nil)
+}
+
+// OrWhereNotNull : same as `WhereNotNull`, but joined with the preceding filter using `OR`
+func (q *Query) OrWhereNotNull(field string) *Query {
+	return q.OrWhere(field, "<>", nil)
+}
+
 // WhereIn :
 func (q *Query) WhereIn(field string, v interface{}) *Query {
 	vv := reflect.Indirect(reflect.ValueOf(v))
 	t := vv.Type()
 	if !vv.IsValid() || (t.Kind() != reflect.Slice && t.Kind() != reflect.Array) {
-		q.errs = append(q.errs, fmt.Errorf(`goloquent: value must be either slice or array for "WhereIn"`))
+		q.addError(fmt.Errorf(`goloquent: value must be either slice or array for "WhereIn"`))
 		return q
 	}
 	return q.Where(field, "in", v)
@@ -397,7 +858,7 @@ func (q *Query) WhereNotIn(field string, v interface{}) *Query {
 	vv := reflect.Indirect(reflect.ValueOf(v))
 	t := vv.Type()
 	if !vv.IsValid() || (t.Kind() != reflect.Slice && t.Kind() != reflect.Array) {
-		q.errs = append(q.errs, fmt.Errorf(`goloquent: value must be either slice or array for "WhereNotIn"`))
+		q.addError(fmt.Errorf(`goloquent: value must be either slice or array for "WhereNotIn"`))
 		return q
 	}
 	return q.Where(field, "nin", v)
@@ -413,17 +874,74 @@ func (q *Query) WhereNotLike(field, v string) *Query {
 	return q.Where(field, "nlike", v)
 }
 
+// WhereLikeEscaped : same as `WhereLike`, but the `%` and `_` in the value are matched literally
+func (q *Query) WhereLikeEscaped(field, v string) *Query {
+	return q.whereLike(field, Like, "%s", v)
+}
+
+// WhereNotLikeEscaped : same as `WhereNotLike`, but the `%` and `_` in the value are matched literally
+func (q *Query) WhereNotLikeEscaped(field, v string) *Query {
+	return q.whereLike(field, NotLike, "%s", v)
+}
+
+// WhereContains : match the records which the field contains the value, the `%` and `_` in the value are matched literally
+func (q *Query) WhereContains(field, v string) *Query {
+	return q.whereLike(field, Like, "%%%s%%", v)
+}
+
+// WhereStartsWith : match the records which the field starts with the value, the `%` and `_` in the value are matched literally
+func (q *Query) WhereStartsWith(field, v string) *Query {
+	return q.whereLike(field, Like, "%s%%", v)
+}
+
+// WhereEndsWith : match the records which the field ends with the value, the `%` and `_` in the value are matched literally
+func (q *Query) WhereEndsWith(field, v string) *Query {
+	return q.whereLike(field, Like, "%%%s", v)
+}
+
+// whereLike will escape the value, and the `pattern` is the wildcards around the escaped value
+func (q *Query) whereLike(field string, op operator, pattern string, v interface{}) *Query {
+	q = q.clone()
+	q.addFilter(Filter{
+		field:    field,
+		operator: op,
+		value:    v,
+		escape:   pattern,
+	})
+	return q
+}
+
+// WhereBetween :
+func (q *Query) WhereBetween(field string, from, to interface{}) *Query {
+	return q.Where(field, "between", []interface{}{from, to})
+}
+
+// WhereNotBetween :
+func (q *Query) WhereNotBetween(field string, from, to interface{}) *Query {
+	return q.Where(field, "not between", []interface{}{from, to})
+}
+
 // WhereAnyLike :
 func (q *Query) WhereAnyLike(field string, v interface{}) *Query {
 	vv := reflect.Indirect(reflect.ValueOf(v))
 	t := vv.Type()
 	if !vv.IsValid() || (t.Kind() != reflect.Slice && t.Kind() != reflect.Array) {
-		q.errs = append(q.errs, fmt.Errorf(`goloquent: value must be either slice or array for "WhereAnyLike"`))
+		q.addError(fmt.Errorf(`goloquent: value must be either slice or array for "WhereAnyLike"`))
 		return q
 	}
 	return q.Where(field, "anylike", v)
 }
 
+// WhereAnyContains : match the records which the field contains any of the values, the `%` and `_`
+// in the values are matched literally, use `WhereAnyLike` for the wildcards
+func (q *Query) WhereAnyContains(field string, v []string) *Query {
+	if len(v) <= 0 {
+		q.addError(fmt.Errorf(`goloquent: value for "WhereAnyContains" cannot be empty`))
+		return q
+	}
+	return q.whereLike(field, AnyLike, "%%%s%%", v)
+}
+
 // WhereJSON :
 func (q *Query) WhereJSON(field, op string, v interface{}) *Query {
 	return q.where(field, op, v, true)
@@ -454,6 +972,145 @@ func (q *Query) WhereJSONContainAny(field string, v interface{}) *Query {
 	return q.WhereJSON(field, "containAny", v)
 }
 
+// WhereJSONContains : the json field must contain the value, the value will be marshalled as json document
+func (q *Query) WhereJSONContains(field string, v interface{}) *Query {
+	q = q.clone()
+	b, err := json.Marshal(v)
+	if err != nil {
+		q.addError(fmt.Errorf("goloquent: invalid value for \"WhereJSONContains\", %v", err))
+		return q
+	}
+	q.addFilter(Filter{
+		field:    field,
+		operator: JSONContains,
+		value:    json.RawMessage(b),
+		isJSON:   true,
+	})
+	return q
+}
+
+// WhereJSONLength : compare the length of the json field, such as `WhereJSONLength("Tags", ">=", 2)`
+func (q *Query) WhereJSONLength(field, op string, n int) *Query {
+	q = q.clone()
+	optr, err := parseOperator(op, true)
+	if err != nil {
+		q.addError(err)
+		return q
+	}
+	switch optr {
+	case Equal, NotEqual, GreaterThan, GreaterEqual, LessThan, LessEqual:
+	default:
+		q.addError(fmt.Errorf("goloquent: invalid operator %q for json length", op))
+		return q
+	}
+	q.addFilter(Filter{
+		field:    field,
+		operator: JSONLength,
+		value:    n,
+		isJSON:   true,
+		cmp:      optr,
+	})
+	return q
+}
+
+// WhereMatch : full-text search on the columns which are indexed by `fulltext`, it's using the natural language mode
+func (q *Query) WhereMatch(fields []string, against string) *Query {
+	q = q.clone()
+	if len(fields) <= 0 {
+		q.addError(fmt.Errorf(`goloquent: fields for "WhereMatch" cannot be empty`))
+		return q
+	}
+	cols := make([]string, len(fields))
+	for i, f := range fields {
+		f = strings.TrimSpace(f)
+		if f == "" {
+			q.addError(fmt.Errorf(`goloquent: field for "WhereMatch" cannot be empty`))
+			return q
+		}
+		cols[i] = f
+	}
+	q.addFilter(Filter{
+		operator: Match,
+		value:    against,
+		fields:   cols,
+	})
+	return q
+}
+
+// WhereColumn : compare the column with another column, such as `WhereColumn("UpdatedAt", ">", "CreatedAt")`
+func (q *Query) WhereColumn(field, op, column string) *Query {
+	q = q.clone()
+	optr, err := parseOperator(op, false)
+	if err != nil {
+		q.addError(err)
+		return q
+	}
+	if _, isOk := columnOperators[optr]; !isOk {
+		q.addError(fmt.Errorf(`goloquent: invalid operator %q for "WhereColumn"`, op))
+		return q
+	}
+	if strings.TrimSpace(field) == "" || strings.TrimSpace(column) == "" {
+		q.addError(fmt.Errorf(`goloquent: field for "WhereColumn" cannot be empty`))
+		return q
+	}
+	q.addFilter(Filter{
+		field:    field,
+		operator: optr,
+		column:   column,
+	})
+	return q
+}
+
+// Union : combine the records of the query with the other query, the duplicate records are removed,
+// the `Order`, `Limit` and `Offset` of the query are applied to the combined records
+func (q *Query) Union(other *Query) *Query {
+	return q.union(other, false)
+}
+
+// UnionAll : same as `Union`, but the duplicate records are kept
+func (q *Query) UnionAll(other *Query) *Query {
+	return q.union(other, true)
+}
+
+func (q *Query) union(other *Query, all bool) *Query {
+	q = q.clone()
+	if other == nil {
+		q.addError(fmt.Errorf("goloquent: union query cannot be nil"))
+		return q
+	}
+	if err := other.getError(); err != nil {
+		q.addError(err)
+		return q
+	}
+	if len(other.unions) > 0 {
+		q.addError(fmt.Errorf("goloquent: nested union is not supported"))
+		return q
+	}
+	if err := matchProjection(q.scope, other.scope); err != nil {
+		q.addError(err)
+		return q
+	}
+	q.unions = append(q.unions, union{all: all, query: other.scope})
+	return q
+}
+
+// matchProjection will check the columns selected by both queries, the records of the union
+// are combined by the position of the columns, so they must be the same
+func matchProjection(a, b scope) error {
+	if len(a.projection)+len(a.rawSelects) != len(b.projection)+len(b.rawSelects) {
+		return fmt.Errorf("goloquent: union queries must select the same number of columns")
+	}
+	if len(a.rawSelects) > 0 || len(b.rawSelects) > 0 {
+		return nil
+	}
+	for i := range a.projection {
+		if a.projection[i] != b.projection[i] {
+			return fmt.Errorf("goloquent: union queries must select the same columns, %q and %q", a.projection[i], b.projection[i])
+		}
+	}
+	return nil
+}
+
 // WhereJSONType :
 func (q *Query) WhereJSONType(field, typ string) *Query {
 	return q.WhereJSON(field, "isType", strings.TrimSpace(strings.ToLower(typ)))
@@ -471,7 +1128,14 @@ func (q *Query) WhereJSONIsArray(field string) *Query {
 
 // Lock :
 func (q *Query) Lock(mode locked) *Query {
-	q.lockMode = mode
+	switch mode {
+	case WriteLockSkipLocked:
+		q.lockMode, q.lockOption = WriteLock, lockSkipLocked
+	case WriteLockNoWait:
+		q.lockMode, q.lockOption = WriteLock, lockNoWait
+	default:
+		q.lockMode, q.lockOption = mode, 0
+	}
 	return q
 }
 
@@ -487,6 +1151,91 @@ func (q *Query) WLock() *Query {
 	return q
 }
 
+// LockForUpdate : same as `WLock`
+func (q *Query) LockForUpdate() *Query {
+	return q.WLock()
+}
+
+// SkipLocked : skip the rows which are locked by other transaction, it requires a lock mode
+func (q *Query) SkipLocked() *Query {
+	q = q.clone()
+	q.lockOption = lockSkipLocked
+	return q
+}
+
+// NoWait : fail immediately when the rows are locked by other transaction, it requires a lock mode
+func (q *Query) NoWait() *Query {
+	q = q.clone()
+	q.lockOption = lockNoWait
+	return q
+}
+
+// GroupBy :
+func (q *Query) GroupBy(fields ...string) *Query {
+	q = q.clone()
+	for _, f := range fields {
+		f = strings.TrimSpace(f)
+		if f == "" {
+			continue
+		}
+		if f == keyFieldName {
+			f = pkColumn
+		}
+		q.groupBy = append(q.groupBy, f)
+	}
+	return q
+}
+
+// Having : filter the grouped records, the field can be an aggregate expression, such as `COUNT(*)`
+func (q *Query) Having(field, op string, value interface{}) *Query {
+	q = q.clone()
+	optr, err := parseOperator(op, false)
+	if err != nil {
+		q.errs = append(q.errs, err)
+		return q
+	}
+	q.havings = append(q.havings, Filter{
+		field:    strings.TrimSpace(field),
+		operator: optr,
+		value:    value,
+	})
+	return q
+}
+
+// WithoutTimestamps : `CreatedAt` and `UpdatedAt` will not be maintained automatically
+func (q *Query) WithoutTimestamps() *Query {
+	q = q.clone()
+	q.noTimestamps = true
+	return q
+}
+
+// WithZeroValues : `Update` using struct will write every field even the field is zero value,
+// by default the zero value fields are skipped unless they are selected using `Select`
+func (q *Query) WithZeroValues() *Query {
+	q = q.clone()
+	q.withZeroValues = true
+	return q
+}
+
+// UsePrimary : read from the primary connection instead of replica, for read-after-write consistency
+func (q *Query) UsePrimary() *Query {
+	q = q.clone()
+	q.usePrimary = true
+	return q
+}
+
+// Timeout : every statement of the query will be cancelled when it exceeds the duration,
+// the error will be `*TimeoutError`
+func (q *Query) Timeout(d time.Duration) *Query {
+	q = q.clone()
+	if d < 0 {
+		q.errs = append(q.errs, fmt.Errorf("goloquent: timeout cannot be negative"))
+		return q
+	}
+	q.timeout = d
+	return q
+}
+
 // Order :
 func (q *Query) Order(fields ...string) *Query {
 	if len(fields) <= 0 {
@@ -522,9 +1271,18 @@ func (q *Query) Offset(offset int) *Query {
 	return q
 }
 
-// ReplaceInto :
-func (q *Query) ReplaceInto(table string) error {
-	return newBuilder(q).replaceInto(table)
+// ReplaceInto : copy the records of the query into the `destTable`, the existing records with the same primary key
+// are replaced. Only the selected columns are copied, or the columns exist in both tables if nothing is selected.
+// Soft deleted records are not copied unless the query is `Unscoped` or `WithTrashed`.
+func (q *Query) ReplaceInto(destTable string) error {
+	if err := q.getError(); err != nil {
+		return err
+	}
+	destTable = strings.TrimSpace(destTable)
+	if q.table == "" || destTable == "" {
+		return fmt.Errorf("goloquent: unable to perform replace without table name")
+	}
+	return newBuilder(q).replaceInto(destTable)
 }
 
 // InsertInto :
@@ -534,25 +1292,172 @@ func (q *Query) InsertInto(table string) error {
 
 // Update :
 func (q *Query) Update(v interface{}) error {
+	_, err := q.UpdateCount(v)
+	return err
+}
+
+// UpdateCount : same as `Update`, but it will return the number of affected records
+func (q *Query) UpdateCount(v interface{}) (int64, error) {
 	if err := q.getError(); err != nil {
-		return err
+		return 0, err
 	}
-	q = q.Order(pkColumn)
+	q = q.Order(keyFieldName)
 	return newBuilder(q).updateMulti(v)
 }
 
+// UpdateJSON : replace the value of the json path, such as `address.city`, of the json field
+// for the records which match the query, the other keys of the json are untouched
+func (q *Query) UpdateJSON(field string, path string, value interface{}) error {
+	if err := q.getError(); err != nil {
+		return err
+	}
+	return newBuilder(q).updateJSON(field, path, value)
+}
+
+// Increment : increase the value of the numeric field by delta for the records which match the query
+func (q *Query) Increment(field string, delta interface{}) error {
+	return q.IncrementMulti(map[string]interface{}{field: delta})
+}
+
+// IncrementMulti : increase the value of multiple numeric fields by their delta
+func (q *Query) IncrementMulti(fields map[string]interface{}) error {
+	if err := q.getError(); err != nil {
+		return err
+	}
+	return newBuilder(q).increment("+", fields)
+}
+
+// Decrement : decrease the value of the numeric field by delta for the records which match the query
+func (q *Query) Decrement(field string, delta interface{}) error {
+	return q.DecrementMulti(map[string]interface{}{field: delta})
+}
+
+// DecrementMulti : decrease the value of multiple numeric fields by their delta
+func (q *Query) DecrementMulti(fields map[string]interface{}) error {
+	if err := q.getError(); err != nil {
+		return err
+	}
+	return newBuilder(q).increment("-", fields)
+}
+
+// Restore : restore the soft deleted records which match the query
+func (q *Query) Restore() error {
+	if err := q.getError(); err != nil {
+		return err
+	}
+	return newBuilder(q).restoreByQuery()
+}
+
 // Flush :
 func (q *Query) Flush() error {
+	_, err := q.FlushCount()
+	return err
+}
+
+// FlushCount : same as `Flush`, but it will return the number of deleted records
+func (q *Query) FlushCount() (int64, error) {
 	if err := q.getError(); err != nil {
-		return err
+		return 0, err
 	}
 	if q.table == "" {
-		return fmt.Errorf("goloquent: unable to perform delete without table name")
+		return 0, fmt.Errorf("goloquent: unable to perform delete without table name")
 	}
 	return newBuilder(q).deleteByQuery()
 }
 
+// Count : count the records which match the query, soft deleted records will be excluded unless `Unscoped`.
+// The groups are counted instead when the query has `GroupBy` or `Having`
+func (q *Query) Count() (int64, error) {
+	if err := q.getError(); err != nil {
+		return 0, err
+	}
+	if q.table == "" {
+		return 0, fmt.Errorf("goloquent: unable to perform count without table name")
+	}
+	return newBuilder(q).count()
+}
+
+// CountDistinct : count the distinct values of the field of the records which match the query, null is not counted
+func (q *Query) CountDistinct(field string) (int64, error) {
+	if err := q.getError(); err != nil {
+		return 0, err
+	}
+	if q.table == "" {
+		return 0, fmt.Errorf("goloquent: unable to perform count without table name")
+	}
+	return newBuilder(q).countDistinct(field)
+}
+
+// Exists : check whether there is any record match the query, soft deleted records will be excluded unless `Unscoped`
+func (q *Query) Exists() (bool, error) {
+	if err := q.getError(); err != nil {
+		return false, err
+	}
+	return newBuilder(q).exists()
+}
+
+// Sum : sum the field of the records which match the query, zero will be returned if there is no record.
+// Same as the other aggregates, it's not supported with `GroupBy` or `Having`, use `Select` instead
+func (q *Query) Sum(field string) (float64, error) {
+	if err := q.getError(); err != nil {
+		return 0, err
+	}
+	return newBuilder(q).aggregateFloat("SUM", field)
+}
+
+// Avg : average the field of the records which match the query, zero will be returned if there is no record
+func (q *Query) Avg(field string) (float64, error) {
+	if err := q.getError(); err != nil {
+		return 0, err
+	}
+	return newBuilder(q).aggregateFloat("AVG", field)
+}
+
+// Min : get the minimum numeric value of the field, use `MinValue` for non-numeric field
+func (q *Query) Min(field string) (float64, error) {
+	if err := q.getError(); err != nil {
+		return 0, err
+	}
+	return newBuilder(q).aggregateFloat("MIN", field)
+}
+
+// Max : get the maximum numeric value of the field, use `MaxValue` for non-numeric field
+func (q *Query) Max(field string) (float64, error) {
+	if err := q.getError(); err != nil {
+		return 0, err
+	}
+	return newBuilder(q).aggregateFloat("MAX", field)
+}
+
+// MinValue : get the minimum value of the field as it scanned from the driver, nil will be returned if there is no record
+func (q *Query) MinValue(field string) (interface{}, error) {
+	if err := q.getError(); err != nil {
+		return nil, err
+	}
+	return newBuilder(q).aggregateValue("MIN", field)
+}
+
+// MaxValue : get the maximum value of the field as it scanned from the driver, nil will be returned if there is no record
+func (q *Query) MaxValue(field string) (interface{}, error) {
+	if err := q.getError(); err != nil {
+		return nil, err
+	}
+	return newBuilder(q).aggregateValue("MAX", field)
+}
+
 // Scan :
 func (q *Query) Scan(dest ...interface{}) error {
+	if err := q.getError(); err != nil {
+		return err
+	}
 	return newBuilder(q).scan(dest...)
 }
+
+// ScanStruct : scan the first record of the select into the struct by the column name,
+// the struct can be any struct which is not registered as entity, such as the projection of joined columns
+func (q *Query) ScanStruct(dest interface{}) error {
+	if err := q.getError(); err != nil {
+		return err
+	}
+	return newBuilder(q).scanStruct(dest)
+}
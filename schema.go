@@ -1,6 +1,10 @@
 package goloquent
 
-import "reflect"
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
 
 var (
 	utf8CharSet    = CharSet{"utf8", "utf8_unicode_ci"}
@@ -12,6 +16,9 @@ var (
 // OmitDefault :
 type OmitDefault interface{}
 
+// RawDefault : the default value is the raw sql expression, such as `CURRENT_TIMESTAMP`, which is not quoted
+type RawDefault string
+
 // CharSet :
 type CharSet struct {
 	Encoding  string
@@ -26,7 +33,61 @@ type Schema struct {
 	IsUnsigned   bool
 	IsNullable   bool
 	IsIndexed    bool
+	IsUnique     bool
+	IsFullText   bool
 	CharSet
+	ForeignKey *ForeignKey
+	Enum       []string
+}
+
+// ForeignKey : the column references the `Column` of the `Table`, `OnDelete` is the referential action,
+// such as `CASCADE` and `SET NULL`
+type ForeignKey struct {
+	Table    string
+	Column   string
+	OnDelete string
+}
+
+// references will return the `REFERENCES` clause of the foreign key
+func (fk ForeignKey) references(d Dialect) string {
+	clause := fmt.Sprintf("REFERENCES %s (%s)", d.GetTable(fk.Table), d.Quote(fk.Column))
+	if fk.OnDelete != "" {
+		clause += " ON DELETE " + fk.OnDelete
+	}
+	return clause
+}
+
+// foreignKeyName will return the constraint name of the foreign key of the column
+func foreignKeyName(table, column string) string {
+	return fmt.Sprintf("%s_%s_%s", table, column, "fk")
+}
+
+// foreignKeyConstraint will return the constraint of the foreign key of the schema
+func foreignKeyConstraint(d Dialect, table string, sc Schema) string {
+	return fmt.Sprintf("CONSTRAINT %s FOREIGN KEY (%s) %s",
+		d.Quote(foreignKeyName(table, sc.Name)), d.Quote(sc.Name), sc.ForeignKey.references(d))
+}
+
+// enumValues will return the quoted values of the enum, such as `'active','inactive'`
+func (s Schema) enumValues() string {
+	values := make([]string, len(s.Enum))
+	for i, v := range s.Enum {
+		values[i] = fmt.Sprintf("'%s'", escapeSingleQuote(v))
+	}
+	return strings.Join(values, ",")
+}
+
+// enumCheck will return the `CHECK` constraint of the enum for the dialect without native enum
+func enumCheck(d Dialect, sc Schema) string {
+	return fmt.Sprintf("CHECK (%s IN (%s))", d.Quote(sc.Name), sc.enumValues())
+}
+
+// defaultValue will return the default value of the schema using `toString`, except `RawDefault` which is returned as it is
+func (s Schema) defaultValue(toString func(interface{}) string) string {
+	if raw, isRaw := s.DefaultValue.(RawDefault); isRaw {
+		return string(raw)
+	}
+	return toString(s.DefaultValue)
 }
 
 // IsOmitEmpty :
@@ -43,6 +43,31 @@ func (s *Stmt) stopTrace() {
 	s.endTime = time.Now().UTC()
 }
 
+// crudOf will return the operation type of the statement by the leading keyword
+func crudOf(query string) string {
+	fields := strings.Fields(query)
+	if len(fields) <= 0 {
+		return ""
+	}
+	// the select of union is wrapped in parentheses
+	switch strings.ToUpper(strings.TrimLeft(fields[0], "(")) {
+	case "INSERT", "REPLACE":
+		return "create"
+	case "SELECT", "WITH":
+		return "read"
+	case "UPDATE":
+		return "update"
+	case "DELETE":
+		return "delete"
+	}
+	return ""
+}
+
+// CRUD : the operation type of the statement, it can be "create", "read", "update", "delete" or empty for the others
+func (s Stmt) CRUD() string {
+	return s.crud
+}
+
 // TimeElapse :
 func (s Stmt) TimeElapse() time.Duration {
 	return s.endTime.Sub(s.startTime)
@@ -0,0 +1,143 @@
+package goloquent
+
+import (
+	"bytes"
+	"database/sql"
+	"log"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStmtRaw(t *testing.T) {
+	newStmt := func(r replacer) *Stmt {
+		return &Stmt{
+			stmt: stmt{
+				statement: bytes.NewBufferString("SELECT * FROM User WHERE Name = ?? AND Age > ??;"),
+				arguments: []interface{}{"Joe", 10},
+			},
+			replacer: r,
+		}
+	}
+
+	if raw := newStmt(new(mysql)).Raw(); raw != "SELECT * FROM User WHERE Name = ? AND Age > ?;" {
+		t.Fatalf("Unexpected mysql raw statement, %q", raw)
+	}
+	if raw := newStmt(new(postgres)).Raw(); raw != "SELECT * FROM User WHERE Name = $1 AND Age > $2;" {
+		t.Fatalf("Unexpected postgres raw statement, %q", raw)
+	}
+
+	ss := &Stmt{
+		stmt: stmt{
+			statement: bytes.NewBufferString("SELECT * FROM User;"),
+		},
+		replacer: new(postgres),
+	}
+	if raw := ss.Raw(); raw != "SELECT * FROM User;" {
+		t.Fatalf("Unexpected raw statement without arguments, %q", raw)
+	}
+}
+
+func TestStmtLogger(t *testing.T) {
+	conn, err := sql.Open("goloquent_test", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	logs := make([]*Stmt, 0)
+	client := Client{
+		sqlCommon: conn,
+		dialect:   new(postgres),
+		logger: func(s *Stmt) {
+			logs = append(logs, s)
+		},
+	}
+	if err := client.execStmt(&stmt{
+		statement: bytes.NewBufferString("UPDATE User SET Name = ?? WHERE Age > ??;"),
+		arguments: []interface{}{"Joe", 10},
+	}); err != nil {
+		t.Fatalf("Unexpected error, %v", err)
+	}
+	if len(logs) != 1 {
+		t.Fatalf("Expected logger to be called once, but get %d", len(logs))
+	}
+	if raw := logs[0].Raw(); raw != "UPDATE User SET Name = $1 WHERE Age > $2;" {
+		t.Fatalf("Unexpected logged statement, %q", raw)
+	}
+	if logs[0].startTime.IsZero() || logs[0].TimeElapse() < 0 {
+		t.Fatal("Expected logged statement to be traced")
+	}
+	if logs[0].Result == nil {
+		t.Fatal("Expected logged statement to carry the result")
+	}
+
+	client.logger = nil
+	if err := client.execStmt(&stmt{
+		statement: bytes.NewBufferString("DELETE FROM User;"),
+	}); err != nil {
+		t.Fatalf("Unexpected error, %v", err)
+	}
+	if len(logs) != 1 {
+		t.Fatal("Expected nothing to be logged without logger")
+	}
+
+	buf := new(bytes.Buffer)
+	log.SetOutput(buf)
+	defer log.SetOutput(os.Stderr)
+	client.debug = true
+	if err := client.execStmt(&stmt{
+		statement: bytes.NewBufferString("DELETE FROM User WHERE Name = ??;"),
+		arguments: []interface{}{"Joe"},
+	}); err != nil {
+		t.Fatalf("Unexpected error, %v", err)
+	}
+	if !strings.Contains(buf.String(), "DELETE FROM User WHERE Name = 'Joe';") {
+		t.Fatalf("Unexpected debug output, %q", buf.String())
+	}
+}
+
+func TestStmtOnQuery(t *testing.T) {
+	conn, err := sql.Open("goloquent_test", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	cruds := make([]string, 0)
+	client := Client{
+		sqlCommon: conn,
+		dialect:   new(mysql),
+		onQuery: func(crud string, sql string, d time.Duration, err error) {
+			if err != nil {
+				t.Fatalf("Unexpected error, %v", err)
+			}
+			if d < 0 {
+				t.Fatalf("Unexpected duration, %v", d)
+			}
+			cruds = append(cruds, crud)
+		},
+	}
+	for _, query := range []string{
+		"INSERT INTO User VALUES (??);",
+		"UPDATE User SET Name = ??;",
+		"DELETE FROM User WHERE Name = ??;",
+		"CREATE INDEX idx ON User (??);",
+	} {
+		if err := client.execStmt(&stmt{
+			statement: bytes.NewBufferString(query),
+			arguments: []interface{}{"Joe"},
+		}); err != nil {
+			t.Fatalf("Unexpected error, %v", err)
+		}
+	}
+	if strings.Join(cruds, ",") != "create,update,delete," {
+		t.Fatalf("Unexpected crud, %v", cruds)
+	}
+	for _, query := range []string{"  select * FROM User;", "(SELECT * FROM A) UNION (SELECT * FROM B);"} {
+		if crud := crudOf(query); crud != "read" {
+			t.Fatalf("Unexpected crud of %q, %q", query, crud)
+		}
+	}
+}
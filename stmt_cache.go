@@ -0,0 +1,97 @@
+package goloquent
+
+import (
+	"container/list"
+	"database/sql"
+	"sync"
+)
+
+const defaultStmtCacheSize = 100
+
+// stmtCache is a LRU cache of the prepared statements, keyed by the sql text
+type stmtCache struct {
+	sync.Mutex
+	conn  sqlCommon
+	size  int
+	lru   *list.List
+	items map[string]*list.Element
+}
+
+type cachedStmt struct {
+	query   string
+	stmt    *sql.Stmt
+	refs    int
+	evicted bool
+}
+
+func newStmtCache(conn sqlCommon, size int) *stmtCache {
+	return &stmtCache{
+		conn:  conn,
+		size:  size,
+		lru:   list.New(),
+		items: make(map[string]*list.Element),
+	}
+}
+
+// acquire will return the cached statement or prepare a new one, the statement
+// must be released after used so it won't be closed while it's executing
+func (c *stmtCache) acquire(query string) (*cachedStmt, error) {
+	c.Lock()
+	defer c.Unlock()
+	if e, isOk := c.items[query]; isOk {
+		c.lru.MoveToFront(e)
+		cs := e.Value.(*cachedStmt)
+		cs.refs++
+		return cs, nil
+	}
+	st, err := c.conn.Prepare(query)
+	if err != nil {
+		return nil, err
+	}
+	cs := &cachedStmt{query: query, stmt: st, refs: 1}
+	c.items[query] = c.lru.PushFront(cs)
+	c.evict()
+	return cs, nil
+}
+
+func (c *stmtCache) release(cs *cachedStmt) {
+	c.Lock()
+	defer c.Unlock()
+	cs.refs--
+	if cs.evicted && cs.refs <= 0 {
+		cs.stmt.Close()
+	}
+}
+
+// resize will change the cache size, the least recently used statements will be closed
+func (c *stmtCache) resize(size int) {
+	c.Lock()
+	defer c.Unlock()
+	c.size = size
+	c.evict()
+}
+
+func (c *stmtCache) evict() {
+	for c.lru.Len() > 0 && c.lru.Len() > c.size {
+		e := c.lru.Back()
+		cs := e.Value.(*cachedStmt)
+		c.lru.Remove(e)
+		delete(c.items, cs.query)
+		cs.evicted = true
+		if cs.refs <= 0 {
+			cs.stmt.Close()
+		}
+	}
+}
+
+// enabled will return false when the cache size is zero
+func (c *stmtCache) enabled() bool {
+	c.Lock()
+	defer c.Unlock()
+	return c.size > 0
+}
+
+// close will close all the cached statements
+func (c *stmtCache) close() {
+	c.resize(0)
+}
@@ -0,0 +1,192 @@
+package goloquent
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+)
+
+type fakeDriver struct {
+	prepared, closed map[string]int
+	executed         []string
+	execErr          error
+	results          []*fakeRows
+}
+
+func (d *fakeDriver) Open(name string) (driver.Conn, error) {
+	return &fakeConn{d}, nil
+}
+
+type fakeConn struct {
+	d *fakeDriver
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	c.d.prepared[query]++
+	return &fakeStmt{c.d, query}, nil
+}
+
+func (c *fakeConn) Close() error {
+	return nil
+}
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	return c, nil
+}
+
+func (c *fakeConn) Commit() error {
+	return nil
+}
+
+func (c *fakeConn) Rollback() error {
+	return nil
+}
+
+type fakeStmt struct {
+	d     *fakeDriver
+	query string
+}
+
+func (s *fakeStmt) Close() error {
+	s.d.closed[s.query]++
+	return nil
+}
+
+func (s *fakeStmt) NumInput() int {
+	return -1
+}
+
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.d.executed = append(s.d.executed, s.query)
+	if s.d.execErr != nil {
+		return nil, s.d.execErr
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	if len(s.d.results) == 0 {
+		return nil, errors.New("not supported")
+	}
+	rows := s.d.results[0]
+	s.d.results = s.d.results[1:]
+	return rows, nil
+}
+
+type fakeRows struct {
+	cols []string
+	vals [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string {
+	return r.cols
+}
+
+func (r *fakeRows) Close() error {
+	return nil
+}
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.vals) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.vals[0])
+	r.vals = r.vals[1:]
+	return nil
+}
+
+var testDriver = &fakeDriver{
+	prepared: make(map[string]int),
+	closed:   make(map[string]int),
+}
+
+func init() {
+	sql.Register("goloquent_test", testDriver)
+}
+
+func TestStmtCache(t *testing.T) {
+	conn, err := sql.Open("goloquent_test", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.SetMaxOpenConns(1)
+	client := Client{sqlCommon: conn, stmts: newStmtCache(conn, 2)}
+
+	for _, q := range []string{"A", "A", "B", "A", "C"} {
+		if _, err := client.PrepareExec(q, 1); err != nil {
+			t.Fatalf("Unexpected error, %v", err)
+		}
+	}
+	if testDriver.prepared["A"] != 1 || testDriver.prepared["B"] != 1 {
+		t.Fatalf("Expected statement should only be prepared once, %v", testDriver.prepared)
+	}
+	if testDriver.closed["B"] != 1 || testDriver.closed["A"] != 0 {
+		t.Fatalf("Expected least recently used statement should be closed, %v", testDriver.closed)
+	}
+
+	tx, err := conn.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	txClient := client
+	txClient.sqlCommon = tx
+	if _, err := txClient.PrepareExec("A", 1); err != nil {
+		t.Fatalf("Unexpected error, %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.PrepareExec("A", 1); err != nil {
+		t.Fatalf("Unexpected error, %v", err)
+	}
+	if testDriver.closed["A"] != 0 {
+		t.Fatal("Expected cached statement should remain open after transaction")
+	}
+
+	client.stmts.close()
+	if testDriver.closed["A"] != 1 || testDriver.closed["C"] != 1 {
+		t.Fatalf("Expected all statements should be closed, %v", testDriver.closed)
+	}
+	if client.stmts.enabled() {
+		t.Fatal("Expected cache should be disabled after closed")
+	}
+	conn.Close()
+}
+
+type fakeDriverError struct {
+	Number uint16
+}
+
+func (e *fakeDriverError) Error() string {
+	return fmt.Sprintf("Error %d", e.Number)
+}
+
+func TestDriverErrorWrapped(t *testing.T) {
+	conn, err := sql.Open("goloquent_test", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	testDriver.execErr = &fakeDriverError{Number: 1062}
+	defer func() { testDriver.execErr = nil }()
+
+	for _, client := range []Client{
+		{sqlCommon: conn},
+		{sqlCommon: conn, stmts: newStmtCache(conn, 2)},
+	} {
+		_, err := client.PrepareExec("D", 1)
+		if err == nil {
+			t.Fatal("Expected driver error should be returned")
+		}
+		var dErr *fakeDriverError
+		if !errors.As(err, &dErr) || dErr.Number != 1062 {
+			t.Fatalf("Expected driver error should be unwrapped, got %v", err)
+		}
+		if client.stmts != nil {
+			client.stmts.close()
+		}
+	}
+}
@@ -105,6 +105,16 @@ func (sc *StructCodec) findField(name string) (*field, error) {
 	return nil, fmt.Errorf("goloquent: struct code cannot find field, %q", name)
 }
 
+// lookupField will return the field with the name, it's nil when there is no such field
+func lookupField(fields []field, name string) *field {
+	for i := range fields {
+		if fields[i].name == name {
+			return &fields[i]
+		}
+	}
+	return nil
+}
+
 func isValidFieldName(name string) bool {
 	if name == "" {
 		return false
@@ -172,6 +182,8 @@ type structScan struct {
 	field       *field
 	isPtrChild  bool
 	StructCodec *StructCodec
+	// prefix is the prefix of the column names of the embedded struct, which is declared by the `prefix` of the tag
+	prefix string
 }
 
 func getStructCodec(it interface{}) (*StructCodec, error) {
@@ -182,7 +194,7 @@ func getStructCodec(it interface{}) (*StructCodec, error) {
 	}
 
 	structs := newStructCodec(v)
-	structScans := append(make([]structScan, 0), structScan{[]int{}, []int{}, rt, nil, false, structs})
+	structScans := append(make([]structScan, 0), structScan{[]int{}, []int{}, rt, nil, false, structs, ""})
 	for len(structScans) > 0 {
 		first := structScans[0]
 		st := first.typeOf
@@ -199,6 +211,9 @@ func getStructCodec(it interface{}) (*StructCodec, error) {
 
 			ft := sf.Type
 			st := newTag(sf)
+			if first.prefix != "" && !st.isSkip() && !st.isPrimaryKey() {
+				st.name = first.prefix + st.name
+			}
 
 			switch {
 			case st.isSkip():
@@ -212,11 +227,26 @@ func getStructCodec(it interface{}) (*StructCodec, error) {
 			case isReserveFieldName(st.name):
 				return nil, fmt.Errorf("goloquent: struct tag has reserved field name: %q", st.name)
 			}
+			if _, err := st.foreignKey(); err != nil {
+				return nil, err
+			}
 
 			if ft == typeOfSoftDelete {
 				st.name = softDeleteColumn
 			}
 
+			// the field of the outer struct shadows the field of the embedded struct with the same name, same as
+			// the promoted field of Go, the embedded struct has the path and it's scanned after the outer struct,
+			// and the fields with the same name at the same depth are ambiguous
+			if len(first.path) > 0 {
+				if f := lookupField(fields, st.name); f != nil {
+					if len(f.paths) == len(first.path)+1 {
+						return nil, fmt.Errorf("goloquent: ambiguous field %q of the embedded struct %v", st.name, first.typeOf)
+					}
+					continue
+				}
+			}
+
 			seq := append(first.sequence, i)
 			k := ft.Kind()
 			if isBaseType(ft) {
@@ -250,7 +280,7 @@ func getStructCodec(it interface{}) (*StructCodec, error) {
 						sc := newStructCodec(reflect.New(ft))
 						f := newField(st, first.field, append(first.path, i), seq, sf.Type, first.isPtrChild, sc)
 						fields = append(fields, f)
-						structScans = append(structScans, structScan{[]int{}, seq, elem, &f, isPtr, sc})
+						structScans = append(structScans, structScan{[]int{}, seq, elem, &f, isPtr, sc, ""})
 						continue
 					}
 				}
@@ -269,11 +299,15 @@ func getStructCodec(it interface{}) (*StructCodec, error) {
 				}
 				fallthrough
 			case k == reflect.Struct:
+				// the fields of the embedded struct are flattened into the outer struct, the embedded struct
+				// of unexported type is skipped unless it's tagged with `flatten` or `prefix`, and it's not a pointer
+				// as it cannot be initialized
 				if sf.Anonymous {
-					if !isExported {
+					if !isExported && (isPtr || (!st.isFlatten() && st.Get("prefix") == "")) {
 						continue
 					}
-					structScans = append(structScans, structScan{append(first.path, i), seq, ft, first.field, isPtr, first.StructCodec})
+					structScans = append(structScans, structScan{append(first.path, i), seq, ft, first.field, isPtr,
+						first.StructCodec, first.prefix + st.Get("prefix")})
 					continue
 				}
 
@@ -282,7 +316,7 @@ func getStructCodec(it interface{}) (*StructCodec, error) {
 				fields = append(fields, f)
 				sc.parentField = &f
 				// reset the position when it's another struct
-				structScans = append(structScans, structScan{[]int{}, seq, ft, &f, isPtr, sc})
+				structScans = append(structScans, structScan{[]int{}, seq, ft, &f, isPtr, sc, ""})
 				continue
 			default:
 				return nil, fmt.Errorf("goloquent: invalid %q", ft.String())
@@ -323,13 +357,30 @@ func mustGetField(v reflect.Value, f field) reflect.Value {
 
 // FieldByIndex panic when the path has nil value in between (*type),
 // however getFieldByIndex will traverse Field by Field to check whether the value is valid
-// and it will return zero if the subsequent field is zero
+// and it will return zero if the subsequent field is zero, the field of the nil embedded struct
+// is the nil pointer of its type, so it's saved as null
 func getFieldByIndex(v reflect.Value, path []int) reflect.Value {
-	for _, p := range path {
+	for i, p := range path {
 		v = v.Field(p)
 		if v.Kind() == reflect.Ptr {
 			if v.IsNil() {
-				return reflect.Zero(v.Type())
+				if i == len(path)-1 {
+					return reflect.Zero(v.Type())
+				}
+				t := v.Type().Elem()
+				for _, pp := range path[i+1:] {
+					if t.Kind() == reflect.Ptr {
+						t = t.Elem()
+					}
+					t = t.Field(pp).Type
+				}
+				if t.Kind() != reflect.Ptr && isBaseType(t) && t != typeOfByte && t != typeOfJSONRawMessage {
+					t = reflect.PtrTo(t)
+				}
+				return reflect.Zero(t)
+			}
+			if i < len(path)-1 {
+				v = v.Elem()
 			}
 		}
 	}
@@ -116,3 +116,106 @@ func TestStructCodec(t *testing.T) {
 		log.Fatal("Expected error free, but instead err :", err)
 	}
 }
+
+type Audit struct {
+	CreatedBy string
+	UpdatedBy string
+}
+
+type Approval struct {
+	By   string
+	Note string
+}
+
+type owner struct {
+	Owner string
+}
+
+func TestStructCodecEmbedded(t *testing.T) {
+	type document struct {
+		Key *datastore.Key `goloquent:"__key__"`
+		Audit
+		*Approval `goloquent:",prefix=approval_"`
+		owner     `goloquent:",flatten"`
+		Title     string
+		UpdatedBy string // shadows `Audit.UpdatedBy`
+	}
+
+	e, err := newEntity(new(document))
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := make([]string, 0)
+	for _, c := range e.columns {
+		names = append(names, c.Name())
+	}
+	expected := []string{"__key__", "CreatedBy", "approval_By", "approval_Note", "Owner", "Title", "UpdatedBy"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Fatalf("Unexpected columns, %v", names)
+	}
+	stmts, err := new(sqlite).CreateTable("document", e.columns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stmts[0] != `CREATE TABLE IF NOT EXISTS "document" ("$Key" TEXT NOT NULL,"CreatedBy" TEXT NOT NULL DEFAULT '',`+
+		`"approval_By" TEXT,"approval_Note" TEXT,"Owner" TEXT NOT NULL DEFAULT '',"Title" TEXT NOT NULL DEFAULT '',`+
+		`"UpdatedBy" TEXT NOT NULL DEFAULT '',PRIMARY KEY ("$Key"));` {
+		t.Fatalf("Unexpected statement, %s", stmts[0])
+	}
+
+	doc := &document{
+		Audit:     Audit{CreatedBy: "joe", UpdatedBy: "ann"},
+		Approval:  &Approval{By: "ken"},
+		Title:     "hello",
+		UpdatedBy: "may",
+	}
+	doc.Owner = "sam"
+	props, err := SaveStruct(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	it := &Iterator{results: []map[string][]byte{{}}}
+	for _, name := range expected[1:] {
+		it.results[0][name] = []byte(fmt.Sprintf("%v", props[name].Value))
+	}
+	o := new(document)
+	if err := it.Scan(o); err != nil {
+		t.Fatal(err)
+	}
+	if o.CreatedBy != "joe" || o.Audit.UpdatedBy != "" || o.UpdatedBy != "may" || o.Owner != "sam" ||
+		o.Title != "hello" || o.Approval == nil || o.By != "ken" {
+		t.Fatalf("Unexpected result, %+v", o)
+	}
+
+	// the columns of the nil embedded struct are null
+	props, err = SaveStruct(&document{Title: "hello"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := reflect.ValueOf(props["approval_By"].Value); v.Kind() != reflect.Ptr || !v.IsNil() {
+		t.Fatalf("Unexpected value of nil embedded struct, %v", props["approval_By"].Value)
+	}
+	// the embedded struct of unexported type is skipped unless it's tagged
+	type note struct {
+		Key *datastore.Key `goloquent:"__key__"`
+		owner
+		Body string
+	}
+	e, err = newEntity(new(note))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cols := e.Columns(); !reflect.DeepEqual(cols, []string{pkColumn, "Body"}) {
+		t.Fatalf("Unexpected columns, %v", cols)
+	}
+
+	// the fields with the same name at the same depth are ambiguous
+	type ambiguous struct {
+		Key *datastore.Key `goloquent:"__key__"`
+		Audit
+		Approval `goloquent:",prefix=Created"`
+	}
+	if _, err := newEntity(new(ambiguous)); err == nil {
+		t.Fatal("Expected error on ambiguous field of the embedded structs")
+	}
+}
@@ -1,6 +1,7 @@
 package goloquent
 
 import (
+	"fmt"
 	"reflect"
 	"regexp"
 	"strings"
@@ -14,35 +15,89 @@ type tag struct {
 
 // TODO: Eager loading tag
 
+// newTag will parse the `goloquent` struct tag, it accepts either the comma
+// separated form `name,index,charset=latin1,references=User($Key),ondelete=cascade`
+// or the semicolon separated form `column:name;index;unique;fulltext;charset:latin1;references:User`,
+// the fields with the same named unique group, such as `unique=email_tenant`, share one unique index,
+// and `oldname=legacy_col` renames the existing column `legacy_col` to the column of the field on migration,
+// `type=decimal(18,2)` is the alias of `datatype=decimal(18,2)`, and `enum=active|inactive` restricts the values of the column,
+// `default=CURRENT_TIMESTAMP` is the default value of the column, which is the raw sql expression unless it's quoted,
+// and `prefix=audit_` is the prefix of the column names of the embedded struct
 func newTag(sf reflect.StructField) tag {
 	name := sf.Name
 
 	t := strings.TrimSpace(sf.Tag.Get("goloquent"))
-	paths := strings.Split(t, ",")
-	if strings.TrimSpace(paths[0]) != "" {
-		name = paths[0]
-	}
-
 	options := map[string]bool{
 		"index":     false,
+		"unique":    false,
+		"fulltext":  false,
 		"flatten":   false,
 		"omitempty": false,
 		"unsigned":  false,
 		"longtext":  false,
 	}
-
 	others := make(map[string]string)
+
+	if strings.Contains(t, ";") || strings.HasPrefix(strings.ToLower(t), "column:") {
+		for _, k := range splitTag(t, ';') {
+			k = strings.TrimSpace(k)
+			kv := strings.SplitN(k, ":", 2)
+			if len(kv) < 2 {
+				k = strings.ToLower(k)
+				if _, isValid := options[k]; isValid {
+					options[k] = true
+				} else if k == "-" {
+					name = k
+				}
+				continue
+			}
+			key, value := strings.ToLower(strings.TrimSpace(kv[0])), strings.TrimSpace(kv[1])
+			if key == "type" {
+				key = "datatype"
+			}
+			switch key {
+			case "column":
+				if value != "" {
+					name = value
+				}
+			case "datatype", "charset", "collate", "ondelete", "unique":
+				others[key] = strings.ToLower(value)
+			case "references", "oldname", "enum", "default", "prefix":
+				others[key] = value
+			}
+		}
+		return tag{
+			name:    name,
+			options: options,
+			others:  others,
+		}
+	}
+
+	paths := splitTag(t, ',')
+	if strings.TrimSpace(paths[0]) != "" {
+		name = paths[0]
+	}
+
 	paths = paths[1:]
 	for _, k := range paths {
-		k = strings.ToLower(k)
-		if _, isValid := options[k]; isValid {
-			options[k] = true
+		if _, isValid := options[strings.ToLower(k)]; isValid {
+			options[strings.ToLower(k)] = true
 		} else {
-			rgx := regexp.MustCompile(`(datatype|charset|collate)\=.+`)
+			rgx := regexp.MustCompile(`(?i)(datatype|type|charset|collate|references|ondelete|unique|oldname|enum|default|prefix)\=.+`)
 			if rgx.MatchString(k) {
 				rgx = regexp.MustCompile(`(\w+)=(.+)`)
 				result := rgx.FindStringSubmatch(k)
-				others[result[1]] = result[2]
+				key, value := strings.ToLower(result[1]), result[2]
+				if key == "type" {
+					key = "datatype"
+				}
+				// the referenced table name, the old column name, the enum values, the default and the prefix are case sensitive
+				switch key {
+				case "references", "oldname", "enum", "default", "prefix":
+				default:
+					value = strings.ToLower(value)
+				}
+				others[key] = value
 			}
 		}
 	}
@@ -54,10 +109,113 @@ func newTag(sf reflect.StructField) tag {
 	}
 }
 
+// splitTag will split the tag by the separator, the separator within the parentheses or the single quotes,
+// such as `datatype=decimal(18,2)` or `default='a,b'`, isn't a separator
+func splitTag(t string, sep rune) []string {
+	paths := make([]string, 0)
+	depth, start, quoted := 0, 0, false
+	for i, r := range t {
+		switch {
+		case r == '\'':
+			quoted = !quoted
+		case quoted:
+		case r == '(':
+			depth++
+		case r == ')':
+			if depth > 0 {
+				depth--
+			}
+		case r == sep:
+			if depth == 0 {
+				paths = append(paths, t[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(paths, t[start:])
+}
+
+// blankFieldTags will return the values of the `key` declared on the blank fields `_ struct{}`
+// of the struct, such as `id` of the tag `goloquent:"primaryKey:id"`
+func blankFieldTags(t reflect.Type, key string) []string {
+	values := make([]string, 0)
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.Name != "_" {
+			continue
+		}
+		kv := strings.SplitN(strings.TrimSpace(sf.Tag.Get("goloquent")), ":", 2)
+		if len(kv) < 2 || strings.ToLower(strings.TrimSpace(kv[0])) != strings.ToLower(key) {
+			continue
+		}
+		values = append(values, kv[1])
+	}
+	return values
+}
+
+// uniqueTogether will return the composite unique indexes declared on the struct using
+// the blank field, such as the field `_ struct{}` with the tag `goloquent:"uniqueTogether:Email,Username"`
+func uniqueTogether(t reflect.Type) [][]string {
+	idxs := make([][]string, 0)
+	for _, v := range blankFieldTags(t, "uniqueTogether") {
+		fields := make([]string, 0)
+		for _, f := range strings.Split(v, ",") {
+			if f = strings.TrimSpace(f); f != "" {
+				fields = append(fields, f)
+			}
+		}
+		idxs = append(idxs, fields)
+	}
+	return idxs
+}
+
 func (t tag) Get(k string) string {
 	return t.others[k]
 }
 
+// defaultValue will return the default value declared by the tag, it's nil when it's not declared. The value
+// quoted by the single quotes, such as `default='N/A'`, is the literal string, otherwise it's the raw sql expression
+func (t tag) defaultValue() interface{} {
+	def := t.Get("default")
+	switch {
+	case def == "":
+		return nil
+	case len(def) >= 2 && strings.HasPrefix(def, "'") && strings.HasSuffix(def, "'"):
+		return strings.Replace(def[1:len(def)-1], "''", "'", -1)
+	}
+	return RawDefault(def)
+}
+
+var referencesRegexp = regexp.MustCompile(`^([^\s()]+)\s*(?:\(\s*([^\s()]+)\s*\))?$`)
+
+// foreignKey will return the foreign key declared using the `references` and `ondelete` of the tag,
+// it's nil when there is no `references`, and the referenced column is `$Key` unless it's specified
+func (t tag) foreignKey() (*ForeignKey, error) {
+	ref := strings.TrimSpace(t.Get("references"))
+	if ref == "" {
+		if t.Get("ondelete") != "" {
+			return nil, fmt.Errorf("goloquent: ondelete of field %q requires references", t.name)
+		}
+		return nil, nil
+	}
+	result := referencesRegexp.FindStringSubmatch(ref)
+	if result == nil {
+		return nil, fmt.Errorf("goloquent: invalid references %q of field %q", ref, t.name)
+	}
+	fk := &ForeignKey{Table: result[1], Column: result[2]}
+	if fk.Column == "" {
+		fk.Column = pkColumn
+	}
+	switch action := strings.Join(strings.Fields(t.Get("ondelete")), " "); action {
+	case "":
+	case "cascade", "set null", "restrict", "no action":
+		fk.OnDelete = strings.ToUpper(action)
+	default:
+		return nil, fmt.Errorf("goloquent: invalid ondelete %q of field %q", action, t.name)
+	}
+	return fk, nil
+}
+
 func (t tag) isPrimaryKey() bool {
 	return t.name == keyFieldName
 }
@@ -74,6 +232,14 @@ func (t tag) IsIndex() bool {
 	return t.options["index"]
 }
 
+func (t tag) IsUnique() bool {
+	return t.options["unique"]
+}
+
+func (t tag) IsFullText() bool {
+	return t.options["fulltext"]
+}
+
 func (t tag) IsOmitEmpty() bool {
 	return t.options["omitempty"]
 }
@@ -85,3 +251,21 @@ func (t tag) IsUnsigned() bool {
 func (t tag) IsLongText() bool {
 	return t.options["longtext"]
 }
+
+// enum will return the values of the enum declared by the tag, such as `enum=active|inactive`
+func (t tag) enum() []string {
+	values := make([]string, 0)
+	for _, v := range strings.Split(t.Get("enum"), "|") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+var decimalRegexp = regexp.MustCompile(`^(decimal|numeric)\b`)
+
+// IsDecimal : the data type declared by the tag is `decimal` or `numeric`, such as `decimal(18,2)`
+func (t tag) IsDecimal() bool {
+	return decimalRegexp.MatchString(strings.TrimSpace(t.Get("datatype")))
+}
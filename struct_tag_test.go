@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"reflect"
 	"testing"
+
+	"cloud.google.com/go/datastore"
 )
 
 func TestStructTagWithSkip(t *testing.T) {
@@ -42,3 +44,174 @@ func TestStructTagWithIndex(t *testing.T) {
 		t.Fatal("Expected tag have index, but end up with noindex")
 	}
 }
+
+func TestStructTagWithColumn(t *testing.T) {
+	type model struct {
+		Email    string `goloquent:"column:email_address;index;unique"`
+		Username string `goloquent:"column:user_name;charset:latin1"`
+		Secret   string `goloquent:"-;index"`
+		Status   string `goloquent:"index;unique"`
+	}
+
+	vt := reflect.TypeOf(model{})
+	tag := newTag(vt.Field(0))
+	if tag.name != "email_address" || !tag.IsIndex() || !tag.IsUnique() {
+		t.Fatal(fmt.Sprintf("Unexpected tag, %+v", tag))
+	}
+	tag = newTag(vt.Field(1))
+	if tag.name != "user_name" || tag.Get("charset") != "latin1" || tag.IsUnique() {
+		t.Fatal(fmt.Sprintf("Unexpected tag, %+v", tag))
+	}
+	if tag = newTag(vt.Field(2)); !tag.isSkip() {
+		t.Fatal("Expected tag have skip, but end up with no skip")
+	}
+	tag = newTag(vt.Field(3))
+	if tag.name != "Status" || !tag.IsUnique() {
+		t.Fatal(fmt.Sprintf("Unexpected tag, %+v", tag))
+	}
+
+	codec, err := getStructCodec(new(model))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cols := getColumns(nil, codec)
+	if len(cols) != 3 || cols[0].Name() != "email_address" || cols[1].Name() != "user_name" {
+		t.Fatal(fmt.Sprintf("Unexpected columns, %v", cols))
+	}
+	sc := new(mysql).GetSchema(cols[0])[0]
+	if !sc.IsUnique {
+		t.Fatal("Expected schema to be unique")
+	}
+}
+
+func TestStructTagUniqueTogether(t *testing.T) {
+	type model struct {
+		_        struct{} `goloquent:"uniqueTogether:Email, Username"`
+		_        struct{} `goloquent:"uniqueTogether:Status"`
+		_        struct{} `goloquent:"index"`
+		Email    string
+		Username string
+		Status   string
+	}
+
+	idxs := uniqueTogether(reflect.TypeOf(model{}))
+	if len(idxs) != 2 {
+		t.Fatal(fmt.Sprintf("Expected 2 unique indexes, but end up with %v", idxs))
+	}
+	if !reflect.DeepEqual(idxs[0], []string{"Email", "Username"}) || !reflect.DeepEqual(idxs[1], []string{"Status"}) {
+		t.Fatal(fmt.Sprintf("Unexpected unique indexes, %v", idxs))
+	}
+}
+
+func TestStructTagUniqueGroup(t *testing.T) {
+	type model struct {
+		Key      *datastore.Key `goloquent:"__key__"`
+		Email    string         `goloquent:",unique=email_tenant"`
+		Username string         `goloquent:"index;unique:username_tenant"`
+		Tenant   string         `goloquent:",index,unique=email_tenant"`
+		Team     string         `goloquent:"index;unique:username_tenant"`
+		Phone    string         `goloquent:",unique"`
+	}
+
+	e, err := newEntity(new(model))
+	if err != nil {
+		t.Fatal(err)
+	}
+	idxs := [][]string{{"Email", "Tenant"}, {"Username", "Team"}}
+	if !reflect.DeepEqual(e.uniques, idxs) {
+		t.Fatal(fmt.Sprintf("Unexpected unique indexes, %v", e.uniques))
+	}
+	for _, c := range e.columns {
+		sc := new(mysql).GetSchema(c)[0]
+		if sc.IsUnique != (c.Name() == "Phone") {
+			t.Fatal(fmt.Sprintf("Unexpected unique option on column %q", c.Name()))
+		}
+		if c.Name() == "Tenant" && !sc.IsIndexed {
+			t.Fatal("Expected column `Tenant` to be indexed")
+		}
+	}
+}
+
+func TestStructTagOldName(t *testing.T) {
+	type model struct {
+		FullName string `goloquent:",oldname=Legacy_Name"`
+		Nickname string `goloquent:"column:nick;oldname:Alias"`
+		Email    string
+	}
+
+	vt := reflect.TypeOf(model{})
+	for i, name := range []string{"Legacy_Name", "Alias", ""} {
+		if tag := newTag(vt.Field(i)); tag.Get("oldname") != name {
+			t.Fatal(fmt.Sprintf("Unexpected old name, %+v", tag))
+		}
+	}
+}
+
+func TestStructTagWithFullText(t *testing.T) {
+	type model struct {
+		Title   string `goloquent:";fulltext"`
+		Content string `goloquent:",longtext,fulltext"`
+		Summary string `goloquent:"column:summary;fulltext"`
+		Keyword string `goloquent:"fulltext"`
+	}
+
+	vt := reflect.TypeOf(model{})
+	for i, name := range []string{"Title", "Content", "summary"} {
+		tag := newTag(vt.Field(i))
+		if tag.name != name || !tag.IsFullText() {
+			t.Fatal(fmt.Sprintf("Unexpected tag, %+v", tag))
+		}
+	}
+	// a single word is the column name, even it's the name of an option
+	if tag := newTag(vt.Field(3)); tag.name != "fulltext" || tag.IsFullText() {
+		t.Fatal(fmt.Sprintf("Unexpected tag, %+v", tag))
+	}
+
+	codec, err := getStructCodec(new(model))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sc := new(mysql).GetSchema(getColumns(nil, codec)[1])[0]
+	if !sc.IsFullText || sc.IsIndexed {
+		t.Fatal(fmt.Sprintf("Unexpected schema, %+v", sc))
+	}
+}
+
+func TestStructTagForeignKey(t *testing.T) {
+	type comment struct {
+		Key    *datastore.Key `goloquent:"__key__"`
+		Post   *datastore.Key `goloquent:"PostKey,references=Post,ondelete=cascade"`
+		Author *datastore.Key `goloquent:"column:AuthorKey;references:User($Key);onDelete:set null"`
+		Body   string
+	}
+
+	codec, err := getStructCodec(new(comment))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []*ForeignKey{
+		nil,
+		{Table: "Post", Column: pkColumn, OnDelete: "CASCADE"},
+		{Table: "User", Column: pkColumn, OnDelete: "SET NULL"},
+		nil,
+	}
+	for i, c := range getColumns(nil, codec) {
+		if sc := new(mysql).GetSchema(c)[0]; !reflect.DeepEqual(sc.ForeignKey, expected[i]) {
+			t.Fatal(fmt.Sprintf("Unexpected foreign key of %q, %+v", c.Name(), sc.ForeignKey))
+		}
+	}
+
+	type invalidRef struct {
+		Key  *datastore.Key `goloquent:"__key__"`
+		Post *datastore.Key `goloquent:",references=Post($Key"`
+	}
+	type invalidAction struct {
+		Key  *datastore.Key `goloquent:"__key__"`
+		Post *datastore.Key `goloquent:",references=Post,ondelete=drop"`
+	}
+	for _, model := range []interface{}{new(invalidRef), new(invalidAction)} {
+		if _, err := getStructCodec(model); err == nil {
+			t.Fatal(fmt.Sprintf("Expected error on invalid foreign key of %T", model))
+		}
+	}
+}
@@ -1,6 +1,9 @@
 package goloquent
 
 import (
+	"database/sql"
+	"time"
+
 	"cloud.google.com/go/datastore"
 )
 
@@ -18,6 +21,12 @@ func (t *Table) newQuery() *Query {
 
 // Create :
 func (t *Table) Create(model interface{}, parentKey ...*datastore.Key) error {
+	_, err := t.CreateWithResult(model, parentKey...)
+	return err
+}
+
+// CreateWithResult :
+func (t *Table) CreateWithResult(model interface{}, parentKey ...*datastore.Key) (sql.Result, error) {
 	return newBuilder(t.newQuery()).put(model, parentKey)
 }
 
@@ -31,6 +40,11 @@ func (t *Table) Migrate(model interface{}) error {
 	return newBuilder(t.newQuery()).migrate(model)
 }
 
+// MigrateDryRun :
+func (t *Table) MigrateDryRun(model interface{}) ([]string, error) {
+	return newBuilder(t.newQuery()).migrateStmts(model)
+}
+
 // Exists :
 func (t *Table) Exists() bool {
 	return t.db.dialect.HasTable(t.name)
@@ -41,6 +55,21 @@ func (t *Table) DropIfExists() error {
 	return newBuilder(t.newQuery()).dropTableIfExists(t.name)
 }
 
+// HasColumn :
+func (t *Table) HasColumn(column string) (bool, error) {
+	return newBuilder(t.newQuery()).hasColumn(t.name, column)
+}
+
+// DropColumn :
+func (t *Table) DropColumn(column string) error {
+	return newBuilder(t.newQuery()).dropColumn(t.name, column)
+}
+
+// RenameColumn :
+func (t *Table) RenameColumn(from, to string) error {
+	return newBuilder(t.newQuery()).renameColumn(t.name, from, to)
+}
+
 // Truncate :
 func (t *Table) Truncate() error {
 	return newBuilder(t.newQuery()).truncate(t.name)
@@ -66,6 +95,11 @@ func (t *Table) Select(fields ...string) *Query {
 	return t.newQuery().Select(fields...)
 }
 
+// SelectRaw :
+func (t *Table) SelectRaw(expr string, args ...interface{}) *Query {
+	return t.newQuery().SelectRaw(expr, args...)
+}
+
 // DistinctOn :
 func (t *Table) DistinctOn(fields ...string) *Query {
 	return t.newQuery().DistinctOn(fields...)
@@ -81,26 +115,76 @@ func (t *Table) Unscoped() *Query {
 	return t.newQuery().Unscoped()
 }
 
+// WithTrashed :
+func (t *Table) WithTrashed() *Query {
+	return t.newQuery().WithTrashed()
+}
+
+// OnlyTrashed :
+func (t *Table) OnlyTrashed() *Query {
+	return t.newQuery().OnlyTrashed()
+}
+
 // Find :
 func (t *Table) Find(key *datastore.Key, model interface{}) error {
 	return t.newQuery().Find(key, model)
 }
 
+// FindMulti :
+func (t *Table) FindMulti(keys []*datastore.Key, model interface{}) error {
+	return t.newQuery().FindMulti(keys, model)
+}
+
 // First :
 func (t *Table) First(model interface{}) error {
 	return t.newQuery().First(model)
 }
 
+// FirstOrCreate :
+func (t *Table) FirstOrCreate(model interface{}, attrs map[string]interface{}) error {
+	return t.newQuery().FirstOrCreate(model, attrs)
+}
+
+// UpdateOrCreate :
+func (t *Table) UpdateOrCreate(attrs, values map[string]interface{}) (*datastore.Key, error) {
+	return t.newQuery().UpdateOrCreate(attrs, values)
+}
+
 // Get :
 func (t *Table) Get(model interface{}) error {
 	return t.newQuery().Get(model)
 }
 
+// GetMaps :
+func (t *Table) GetMaps(dest *[]map[string]interface{}) error {
+	return t.newQuery().GetMaps(dest)
+}
+
+// Each :
+func (t *Table) Each(model interface{}, fn func(model interface{}) error) error {
+	return t.newQuery().Each(model, fn)
+}
+
+// Chunk :
+func (t *Table) Chunk(model interface{}, size int, fn func(model interface{}) error) error {
+	return t.newQuery().Chunk(model, size, fn)
+}
+
 // Paginate :
 func (t *Table) Paginate(p *Pagination, model interface{}) error {
 	return t.newQuery().Paginate(p, model)
 }
 
+// Join :
+func (t *Table) Join(table, localCol, foreignCol string) *Query {
+	return t.newQuery().Join(table, localCol, foreignCol)
+}
+
+// LeftJoin :
+func (t *Table) LeftJoin(table, localCol, foreignCol string) *Query {
+	return t.newQuery().LeftJoin(table, localCol, foreignCol)
+}
+
 // AnyOfAncestor :
 func (t *Table) AnyOfAncestor(ancestors ...*datastore.Key) *Query {
 	return t.newQuery().AnyOfAncestor(ancestors...)
@@ -116,6 +200,11 @@ func (t *Table) Where(field, op string, value interface{}) *Query {
 	return t.newQuery().Where(field, op, value)
 }
 
+// WhereGroup :
+func (t *Table) WhereGroup(fn func(*Query)) *Query {
+	return t.newQuery().WhereGroup(fn)
+}
+
 // WhereEqual :
 func (t *Table) WhereEqual(field string, v interface{}) *Query {
 	return t.newQuery().WhereEqual(field, v)
@@ -126,6 +215,21 @@ func (t *Table) WhereNotEqual(field string, v interface{}) *Query {
 	return t.newQuery().WhereNotEqual(field, v)
 }
 
+// Union :
+func (t *Table) Union(other *Query) *Query {
+	return t.newQuery().Union(other)
+}
+
+// UnionAll :
+func (t *Table) UnionAll(other *Query) *Query {
+	return t.newQuery().UnionAll(other)
+}
+
+// WhereColumn :
+func (t *Table) WhereColumn(field, op, column string) *Query {
+	return t.newQuery().WhereColumn(field, op, column)
+}
+
 // WhereNull :
 func (t *Table) WhereNull(field string) *Query {
 	return t.newQuery().WhereNull(field)
@@ -156,6 +260,46 @@ func (t *Table) WhereNotLike(field, v string) *Query {
 	return t.newQuery().WhereNotLike(field, v)
 }
 
+// WhereLikeEscaped :
+func (t *Table) WhereLikeEscaped(field, v string) *Query {
+	return t.newQuery().WhereLikeEscaped(field, v)
+}
+
+// WhereNotLikeEscaped :
+func (t *Table) WhereNotLikeEscaped(field, v string) *Query {
+	return t.newQuery().WhereNotLikeEscaped(field, v)
+}
+
+// WhereContains :
+func (t *Table) WhereContains(field, v string) *Query {
+	return t.newQuery().WhereContains(field, v)
+}
+
+// WhereStartsWith :
+func (t *Table) WhereStartsWith(field, v string) *Query {
+	return t.newQuery().WhereStartsWith(field, v)
+}
+
+// WhereEndsWith :
+func (t *Table) WhereEndsWith(field, v string) *Query {
+	return t.newQuery().WhereEndsWith(field, v)
+}
+
+// WhereAnyContains :
+func (t *Table) WhereAnyContains(field string, v []string) *Query {
+	return t.newQuery().WhereAnyContains(field, v)
+}
+
+// WhereBetween :
+func (t *Table) WhereBetween(field string, from, to interface{}) *Query {
+	return t.newQuery().WhereBetween(field, from, to)
+}
+
+// WhereNotBetween :
+func (t *Table) WhereNotBetween(field string, from, to interface{}) *Query {
+	return t.newQuery().WhereNotBetween(field, from, to)
+}
+
 // WhereJSONEqual :
 func (t *Table) WhereJSONEqual(field string, v interface{}) *Query {
 	return t.newQuery().WhereJSONEqual(field, v)
@@ -176,6 +320,36 @@ func (t *Table) RLock() *Query {
 	return t.newQuery().RLock()
 }
 
+// GroupBy :
+func (t *Table) GroupBy(fields ...string) *Query {
+	return t.newQuery().GroupBy(fields...)
+}
+
+// Having :
+func (t *Table) Having(field, op string, value interface{}) *Query {
+	return t.newQuery().Having(field, op, value)
+}
+
+// WithoutTimestamps :
+func (t *Table) WithoutTimestamps() *Query {
+	return t.newQuery().WithoutTimestamps()
+}
+
+// WithZeroValues :
+func (t *Table) WithZeroValues() *Query {
+	return t.newQuery().WithZeroValues()
+}
+
+// UsePrimary :
+func (t *Table) UsePrimary() *Query {
+	return t.newQuery().UsePrimary()
+}
+
+// Timeout :
+func (t *Table) Timeout(d time.Duration) *Query {
+	return t.newQuery().Timeout(d)
+}
+
 // Order :
 func (t *Table) Order(fields ...string) *Query {
 	return t.newQuery().Order(fields...)
@@ -206,12 +380,87 @@ func (t *Table) Update(v interface{}) error {
 	return t.newQuery().Update(v)
 }
 
+// UpdateCount :
+func (t *Table) UpdateCount(v interface{}) (int64, error) {
+	return t.newQuery().UpdateCount(v)
+}
+
+// UpdateJSON :
+func (t *Table) UpdateJSON(field string, path string, value interface{}) error {
+	return t.newQuery().UpdateJSON(field, path, value)
+}
+
+// Increment :
+func (t *Table) Increment(field string, delta interface{}) error {
+	return t.newQuery().Increment(field, delta)
+}
+
+// IncrementMulti :
+func (t *Table) IncrementMulti(fields map[string]interface{}) error {
+	return t.newQuery().IncrementMulti(fields)
+}
+
+// Decrement :
+func (t *Table) Decrement(field string, delta interface{}) error {
+	return t.newQuery().Decrement(field, delta)
+}
+
+// DecrementMulti :
+func (t *Table) DecrementMulti(fields map[string]interface{}) error {
+	return t.newQuery().DecrementMulti(fields)
+}
+
 // Save :
 func (t *Table) Save(model interface{}) error {
 	return newBuilder(t.newQuery()).save(model)
 }
 
+// Count :
+func (t *Table) Count() (int64, error) {
+	return t.newQuery().Count()
+}
+
+// CountDistinct :
+func (t *Table) CountDistinct(field string) (int64, error) {
+	return t.newQuery().CountDistinct(field)
+}
+
+// Sum :
+func (t *Table) Sum(field string) (float64, error) {
+	return t.newQuery().Sum(field)
+}
+
+// Avg :
+func (t *Table) Avg(field string) (float64, error) {
+	return t.newQuery().Avg(field)
+}
+
+// Min :
+func (t *Table) Min(field string) (float64, error) {
+	return t.newQuery().Min(field)
+}
+
+// Max :
+func (t *Table) Max(field string) (float64, error) {
+	return t.newQuery().Max(field)
+}
+
+// MinValue :
+func (t *Table) MinValue(field string) (interface{}, error) {
+	return t.newQuery().MinValue(field)
+}
+
+// MaxValue :
+func (t *Table) MaxValue(field string) (interface{}, error) {
+	return t.newQuery().MaxValue(field)
+}
+
 // Scan :
 func (t *Table) Scan(dest ...interface{}) error {
 	return t.newQuery().Scan(dest...)
 }
+
+// ScanStruct :
+func (t *Table) ScanStruct(dest interface{}) error {
+	return t.newQuery().ScanStruct(dest)
+}
@@ -74,3 +74,44 @@ func getFakeUser() *User {
 	u.Status = "ACTIVE"
 	return u
 }
+
+// HookUser :
+type HookUser struct {
+	User
+	events []string
+}
+
+// BeforeCreate :
+func (u *HookUser) BeforeCreate() error {
+	u.events = append(u.events, "BeforeCreate")
+	return nil
+}
+
+// AfterCreate :
+func (u *HookUser) AfterCreate() error {
+	if u.Key == nil {
+		return fmt.Errorf("missing primary key after create")
+	}
+	u.events = append(u.events, "AfterCreate")
+	return nil
+}
+
+// BeforeUpdate :
+func (u *HookUser) BeforeUpdate() error {
+	u.events = append(u.events, "BeforeUpdate")
+	return nil
+}
+
+// AfterUpdate :
+func (u *HookUser) AfterUpdate() error {
+	u.events = append(u.events, "AfterUpdate")
+	return nil
+}
+
+// Post :
+type Post struct {
+	Key       *datastore.Key `goloquent:"__key__"`
+	Title     string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
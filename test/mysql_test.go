@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"strings"
 	"testing"
 	"time"
 
@@ -152,6 +153,22 @@ func TestMySQLSave(t *testing.T) {
 	if err := my.Save(&u); err != nil {
 		t.Fatal(err)
 	}
+
+	users := []User{u, u}
+	users[1].Key = nil
+	if err := my.Save(&users); err == nil {
+		t.Fatal(errors.New("incomplete key suppose not allow in `Save` func"))
+	}
+	users = make([]User, 0)
+	if err := my.NewQuery().Limit(2).Get(&users); err != nil {
+		t.Fatal(err)
+	}
+	for i := range users {
+		users[i].Name = "Bulk"
+	}
+	if err := my.Save(&users); err != nil {
+		t.Fatal(err)
+	}
 }
 
 func TestMySQLSelect(t *testing.T) {
@@ -251,6 +268,17 @@ func TestMySQLGet(t *testing.T) {
 		t.Fatal(err)
 	}
 
+	missingKey := datastore.NameKey("User", "missing-key", nil)
+	found := new([]*User)
+	err := my.FindMulti([]*datastore.Key{u2.Key, missingKey, u.Key}, found)
+	if missing, isOk := err.(goloquent.NotFoundError); !isOk || len(missing) != 1 || missing[1] != missingKey {
+		t.Fatal(fmt.Sprintf("Expected missing key error, but end up with %v", err))
+	}
+	if len(*found) != 3 || (*found)[1] != nil ||
+		(*found)[0].Key.String() != u2.Key.String() || (*found)[2].Key.String() != u.Key.String() {
+		t.Fatal(fmt.Sprintf("Unexpected records, %v", *found))
+	}
+
 	if err := my.Where("$Key", "=", u2.Key).First(u); err != nil {
 		t.Fatal(err)
 	}
@@ -287,6 +315,13 @@ func TestMySQLAncestor(t *testing.T) {
 	if len(*users) <= 0 {
 		t.Fatal(`Unexpected result from filter "Ancestor" using name key with symbol`)
 	}
+
+	if err := my.Ancestor(idKey).AncestorDepth(1).Get(users); err != nil {
+		t.Fatal(err)
+	}
+	if len(*users) <= 0 {
+		t.Fatal(`Unexpected result from filter "Ancestor" with depth`)
+	}
 }
 
 func TestMySQLWhereFilter(t *testing.T) {
@@ -737,6 +772,971 @@ func TestMySQLScan(t *testing.T) {
 	log.Println("Count :", count, ", Sum :", sum)
 }
 
+func TestMySQLTimeout(t *testing.T) {
+	var v int
+	err := my.Table("User").
+		Timeout(50 * time.Millisecond).
+		SelectRaw("SLEEP(1)").
+		Limit(1).
+		Scan(&v)
+	if _, isOk := err.(*goloquent.TimeoutError); !isOk {
+		t.Fatal(fmt.Errorf("expected timeout error, but get %v", err))
+	}
+	if err := my.Table("User").
+		Timeout(time.Minute).
+		SelectRaw("COUNT(*)").
+		Scan(&v); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMySQLScanStruct(t *testing.T) {
+	var result struct {
+		Status string
+		Total  int64
+	}
+	if err := my.Table("User").
+		Select("Status").
+		SelectRaw("COUNT(*) AS Total").
+		GroupBy("Status").
+		Order("-Total").
+		ScanStruct(&result); err != nil {
+		t.Fatal(err)
+	}
+	if result.Total <= 0 {
+		t.Fatal(fmt.Errorf("unexpected total, %d", result.Total))
+	}
+	if err := my.Table("User").ScanStruct(result); err == nil {
+		t.Fatal("Expected `ScanStruct` must addressable")
+	}
+}
+
+func TestMySQLCount(t *testing.T) {
+	if _, err := my.NewQuery().Count(); err == nil {
+		t.Fatal("Expected `Count` without table name should return error")
+	}
+
+	total, err := my.Table("User").Unscoped().Count()
+	if err != nil {
+		t.Fatal(err)
+	}
+	count, err := my.Table("User").Count()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count <= 0 || count > total {
+		t.Fatal(fmt.Errorf("unexpected count result, %d versus %d", count, total))
+	}
+
+	children, err := my.Table("User").
+		Ancestor(nameKey).
+		Where("Age", ">=", 0).
+		Count()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if children <= 0 {
+		t.Fatal(`Unexpected result from "Count" using ancestor`)
+	}
+
+	distinct, err := my.Table("User").DistinctOn("Age").Count()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if distinct <= 0 || distinct > total {
+		t.Fatal(fmt.Errorf("unexpected distinct count result, %d versus %d", distinct, total))
+	}
+	if n, err := my.Table("User").CountDistinct("Age"); err != nil || n != distinct {
+		t.Fatal(fmt.Errorf("unexpected count distinct result, %d versus %d, %v", n, distinct, err))
+	}
+
+	for _, model := range []interface{}{new(User), User{}, "User"} {
+		live, err := my.Count(model)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if live != count {
+			t.Fatal(fmt.Errorf("unexpected count of model %T, %d versus %d", model, live, count))
+		}
+	}
+}
+
+func TestMySQLExists(t *testing.T) {
+	isExist, err := my.Table("User").Where("Age", ">=", 0).Exists()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !isExist {
+		t.Fatal("Expected record should be exists")
+	}
+
+	isExist, err = my.Table("User").Where("Age", "<", 0).Exists()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if isExist {
+		t.Fatal("Unexpected record exists")
+	}
+}
+
+func TestMySQLAggregate(t *testing.T) {
+	query := my.Table("User").Where("Age", ">=", 0)
+	sum, err := query.Sum("Age")
+	if err != nil {
+		t.Fatal(err)
+	}
+	avg, err := query.Avg("Age")
+	if err != nil {
+		t.Fatal(err)
+	}
+	min, err := query.Min("Age")
+	if err != nil {
+		t.Fatal(err)
+	}
+	max, err := query.Max("Age")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if min > avg || avg > max || max > sum {
+		t.Fatal(fmt.Errorf("unexpected aggregate result, sum %v, avg %v, min %v, max %v", sum, avg, min, max))
+	}
+
+	empty := my.Table("User").Where("Age", "<", 0)
+	sum, err = empty.Sum("Age")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sum != 0 {
+		t.Fatal(fmt.Errorf("expected zero sum on empty result, but get %v", sum))
+	}
+
+	total, err := my.Table("User").Sum("Age")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total < avg {
+		t.Fatal(fmt.Errorf("unexpected sum result, %v versus %v", total, avg))
+	}
+
+	max, err = my.Table("User").Ancestor(nameKey).Max("Age")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if max < 0 {
+		t.Fatal(`Unexpected result from "Max" using ancestor`)
+	}
+
+	v, err := query.MaxValue("UpdatedDateTime")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v == nil {
+		t.Fatal(`Unexpected nil value from "MaxValue"`)
+	}
+	v, err = empty.MinValue("UpdatedDateTime")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != nil {
+		t.Fatal(fmt.Errorf("expected nil value on empty result, but get %v", v))
+	}
+}
+
+func TestMySQLGroupBy(t *testing.T) {
+	var (
+		status string
+		count  int64
+	)
+	if err := my.Table("User").
+		Select("Status", "COUNT(*)").
+		Where("Age", ">=", 0).
+		GroupBy("Status").
+		Having("COUNT(*)", ">", 0).
+		Order("Status").
+		Limit(1).
+		Scan(&status, &count); err != nil {
+		t.Fatal(err)
+	}
+	if count <= 0 {
+		t.Fatal(fmt.Errorf("unexpected group count, %d", count))
+	}
+
+	if err := my.Table("User").
+		Select("Status").
+		GroupBy("Status").
+		Having("COUNT(*)", "?", 0).
+		Scan(&status); err == nil {
+		t.Fatal("Expected error on invalid having operator")
+	}
+}
+
+func TestMySQLHooks(t *testing.T) {
+	u := &HookUser{User: *getFakeUser()}
+	if err := my.Table("User").Create(u); err != nil {
+		t.Fatal(err)
+	}
+	u.Name = "Hook"
+	if err := my.Table("User").Save(u); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprintf("%v", u.events) != "[BeforeCreate AfterCreate BeforeUpdate AfterUpdate]" {
+		t.Fatal(fmt.Errorf("unexpected hook events, %v", u.events))
+	}
+
+	uu := []HookUser{{User: *getFakeUser()}, {User: *getFakeUser()}}
+	if err := my.Table("User").Create(&uu); err != nil {
+		t.Fatal(err)
+	}
+	for _, u := range uu {
+		if len(u.events) != 2 {
+			t.Fatal(fmt.Errorf("unexpected hook events, %v", u.events))
+		}
+	}
+}
+
+func TestMySQLBatchCreate(t *testing.T) {
+	before, err := my.Table("User").Unscoped().Count()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	my.SetBatchSize(2)
+	defer my.SetBatchSize(0)
+	users := []*User{getFakeUser(), getFakeUser(), getFakeUser(), getFakeUser(), getFakeUser()}
+	if err := my.Create(&users); err != nil {
+		t.Fatal(err)
+	}
+	for _, u := range users {
+		if u.Key == nil {
+			t.Fatal("Expected primary key should be populated")
+		}
+	}
+
+	after, err := my.Table("User").Unscoped().Count()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after-before != int64(len(users)) {
+		t.Fatal(fmt.Errorf("expected %d records inserted, but get %d", len(users), after-before))
+	}
+}
+
+func TestMySQLTrashed(t *testing.T) {
+	u := getFakeUser()
+	if err := my.Create(u); err != nil {
+		t.Fatal(err)
+	}
+	if err := my.Delete(u); err != nil {
+		t.Fatal(err)
+	}
+
+	total, err := my.Table("User").WithTrashed().Count()
+	if err != nil {
+		t.Fatal(err)
+	}
+	trashed, err := my.Table("User").OnlyTrashed().Count()
+	if err != nil {
+		t.Fatal(err)
+	}
+	count, err := my.Table("User").Count()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if trashed <= 0 || trashed+count != total {
+		t.Fatal(fmt.Errorf("unexpected trashed count, %d + %d versus %d", trashed, count, total))
+	}
+
+	users := new([]User)
+	if err := my.OnlyTrashed().
+		WhereEqual("$Key", u.Key).
+		Get(users); err != nil {
+		t.Fatal(err)
+	}
+	if len(*users) != 1 {
+		t.Fatal(`Unexpected result from "OnlyTrashed"`)
+	}
+}
+
+func TestMySQLRestore(t *testing.T) {
+	users := []*User{getFakeUser(), getFakeUser()}
+	if err := my.Create(&users); err != nil {
+		t.Fatal(err)
+	}
+	if err := my.Delete(&users); err != nil {
+		t.Fatal(err)
+	}
+	if err := my.Restore(&users); err != nil {
+		t.Fatal(err)
+	}
+	for _, u := range users {
+		if err := my.Find(u.Key, new(User)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := my.Delete(users[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err := my.Table("User").
+		WhereEqual("$Key", users[0].Key).
+		Restore(); err != nil {
+		t.Fatal(err)
+	}
+	if err := my.Find(users[0].Key, new(User)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := my.Restore(&Address{}); err == nil {
+		t.Fatal("Expected error on entity without soft delete column")
+	}
+}
+
+func TestMySQLTimestamps(t *testing.T) {
+	if err := my.Table("Post").DropIfExists(); err != nil {
+		t.Fatal(err)
+	}
+	if err := my.Migrate(new(Post)); err != nil {
+		t.Fatal(err)
+	}
+
+	p := &Post{Title: "Hello World"}
+	if err := my.Create(p); err != nil {
+		t.Fatal(err)
+	}
+	if p.CreatedAt.IsZero() || p.UpdatedAt.IsZero() {
+		t.Fatal("Expected `CreatedAt` and `UpdatedAt` should be set on create")
+	}
+
+	time.Sleep(time.Second)
+	if err := my.Table("Post").WhereEqual("$Key", p.Key).
+		Update(map[string]interface{}{
+			"Title": "Hello Goloquent",
+		}); err != nil {
+		t.Fatal(err)
+	}
+
+	pp := new(Post)
+	if err := my.Find(p.Key, pp); err != nil {
+		t.Fatal(err)
+	}
+	if !pp.UpdatedAt.After(pp.CreatedAt) {
+		t.Fatal(fmt.Errorf("Expected `UpdatedAt` should be refreshed on update, %v", pp.UpdatedAt))
+	}
+}
+
+func TestMySQLFirstOrCreate(t *testing.T) {
+	username := fmt.Sprintf("%d", time.Now().UnixNano())
+	u := getFakeUser()
+	if err := my.Table("User").WhereEqual("Username", username).
+		FirstOrCreate(u, nil); err != nil {
+		t.Fatal(err)
+	}
+	if u.Key == nil || u.Username != username {
+		t.Fatal(fmt.Errorf("Expected record to be created with the filter values, %v", u))
+	}
+
+	uu := new(User)
+	if err := my.Table("User").
+		FirstOrCreate(uu, map[string]interface{}{"Username": username}); err != nil {
+		t.Fatal(err)
+	}
+	if !uu.Key.Equal(u.Key) {
+		t.Fatal(fmt.Errorf("Expected existing record %v, but get %v", u.Key, uu.Key))
+	}
+
+	if err := my.Delete(uu); err != nil {
+		t.Fatal(err)
+	}
+	uu = getFakeUser()
+	if err := my.Table("User").
+		FirstOrCreate(uu, map[string]interface{}{"Username": username}); err != nil {
+		t.Fatal(err)
+	}
+	if uu.Key.Equal(u.Key) || uu.Username != username {
+		t.Fatal(fmt.Errorf("Expected trashed record %v should not be matched", u.Key))
+	}
+}
+
+func TestMySQLUpdateOrCreate(t *testing.T) {
+	title := fmt.Sprintf("%d", time.Now().UnixNano())
+	key, err := my.Table("Post").UpdateOrCreate(
+		map[string]interface{}{"Title": title},
+		map[string]interface{}{"CreatedAt": time.Now().UTC()},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := new(Post)
+	if err := my.Table("Post").Find(key, p); err != nil {
+		t.Fatal(err)
+	}
+	if p.Title != title || p.UpdatedAt.IsZero() {
+		t.Fatal(fmt.Errorf("Expected record to be created with the attributes, %v", p))
+	}
+
+	kk, err := my.Table("Post").UpdateOrCreate(
+		map[string]interface{}{"Title": title},
+		map[string]interface{}{"Title": title + "-updated"},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !kk.Equal(key) {
+		t.Fatal(fmt.Errorf("Expected existing record %v, but get %v", key, kk))
+	}
+	if err := my.Table("Post").Find(key, p); err != nil {
+		t.Fatal(err)
+	}
+	if p.Title != title+"-updated" {
+		t.Fatal(fmt.Errorf("Expected existing record to be updated, %v", p))
+	}
+}
+
+func TestMySQLIncrement(t *testing.T) {
+	u := getFakeUser()
+	u.Age = 10
+	u.CreditLimit = 100
+	if err := my.Create(u); err != nil {
+		t.Fatal(err)
+	}
+
+	q := my.Table("User").WhereEqual("$Key", u.Key)
+	if err := q.IncrementMulti(map[string]interface{}{
+		"Age":         2,
+		"CreditLimit": 50.5,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Decrement("Age", 1); err != nil {
+		t.Fatal(err)
+	}
+
+	uu := new(User)
+	if err := my.Find(u.Key, uu); err != nil {
+		t.Fatal(err)
+	}
+	if uu.Age != 11 || uu.CreditLimit != 150.5 {
+		t.Fatal(fmt.Errorf("Unexpected values after increment, %d, %v", uu.Age, uu.CreditLimit))
+	}
+
+	if err := q.Increment("Name", 1); err == nil {
+		t.Fatal("Expected error on increment non numeric column")
+	}
+}
+
+func TestMySQLChunk(t *testing.T) {
+	total, err := my.Table("User").Count()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	count, keys := int64(0), make(map[string]bool)
+	users := new([]User)
+	if err := my.Table("User").Chunk(users, 3, func(model interface{}) error {
+		uu := *model.(*[]User)
+		if len(uu) > 3 {
+			return fmt.Errorf("unexpected chunk size %d", len(uu))
+		}
+		for _, u := range uu {
+			if keys[u.Key.String()] {
+				return fmt.Errorf("duplicate record %v in chunks", u.Key)
+			}
+			keys[u.Key.String()] = true
+		}
+		count += int64(len(uu))
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if count != total {
+		t.Fatal(fmt.Errorf("expected %d records, but get %d", total, count))
+	}
+
+	all := new([]User)
+	if err := my.Table("User").Order("-Age", "$Key").Get(all); err != nil {
+		t.Fatal(err)
+	}
+	ordered := make([]User, 0)
+	if err := my.Table("User").Order("-Age").Chunk(new([]User), 4, func(model interface{}) error {
+		ordered = append(ordered, *model.(*[]User)...)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(ordered) != len(*all) {
+		t.Fatal(fmt.Errorf("expected %d records, but get %d", len(*all), len(ordered)))
+	}
+	for i := range ordered {
+		if !ordered[i].Key.Equal((*all)[i].Key) {
+			t.Fatal(fmt.Errorf("unexpected record %v at position %d", ordered[i].Key, i))
+		}
+	}
+
+	limited := make([]User, 0)
+	if err := my.Table("User").Order("-Age").Offset(1).Limit(5).Chunk(new([]User), 2, func(model interface{}) error {
+		limited = append(limited, *model.(*[]User)...)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	expected := len(*all) - 1
+	if expected > 5 {
+		expected = 5
+	}
+	if len(limited) != expected {
+		t.Fatal(fmt.Errorf("expected %d records, but get %d", expected, len(limited)))
+	}
+	for i := range limited {
+		if !limited[i].Key.Equal((*all)[i+1].Key) {
+			t.Fatal(fmt.Errorf("unexpected record %v at position %d", limited[i].Key, i))
+		}
+	}
+
+	stop := errors.New("stop")
+	batches := 0
+	if err := my.Table("User").Order("-Age").Chunk(new([]*User), 2, func(model interface{}) error {
+		batches++
+		return stop
+	}); err != stop || batches != 1 {
+		t.Fatal(fmt.Errorf("expected chunk to stop on error, %v", err))
+	}
+}
+
+func TestMySQLEach(t *testing.T) {
+	users := new([]User)
+	if err := my.Table("User").Order("Age").Limit(5).Get(users); err != nil {
+		t.Fatal(err)
+	}
+
+	i := 0
+	if err := my.Table("User").Order("Age").Limit(5).Each(new(User), func(model interface{}) error {
+		u := model.(*User)
+		if !u.Key.Equal((*users)[i].Key) {
+			return fmt.Errorf("unexpected record %v at position %d", u.Key, i)
+		}
+		i++
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if i != len(*users) {
+		t.Fatal(fmt.Errorf("expected %d records, but get %d", len(*users), i))
+	}
+
+	stop, i := errors.New("stop"), 0
+	if err := my.Table("User").Each(new(User), func(model interface{}) error {
+		i++
+		return stop
+	}); err != stop || i != 1 {
+		t.Fatal(fmt.Errorf("expected iteration to stop on error, %v", err))
+	}
+}
+
+func TestMySQLGetMaps(t *testing.T) {
+	users := new([]User)
+	if err := my.Table("User").Order("Age").Limit(3).Get(users); err != nil {
+		t.Fatal(err)
+	}
+
+	results := make([]map[string]interface{}, 0)
+	if err := my.Table("User").
+		Select("$Key", "Username", "Nickname").
+		Order("Age").
+		Limit(3).
+		GetMaps(&results); err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != len(*users) {
+		t.Fatal(fmt.Errorf("expected %d records, but get %d", len(*users), len(results)))
+	}
+	for i, u := range *users {
+		if results[i]["Username"] != u.Username {
+			t.Fatal(fmt.Errorf("unexpected username %v", results[i]["Username"]))
+		}
+		if _, isOk := results[i]["$Key"].(string); !isOk {
+			t.Fatal(fmt.Errorf("expected key to be string, but get %T", results[i]["$Key"]))
+		}
+		if u.Nickname == nil && results[i]["Nickname"] != nil {
+			t.Fatal(fmt.Errorf("expected null to be nil, but get %v", results[i]["Nickname"]))
+		}
+	}
+
+	if err := my.NewQuery().GetMaps(&results); err == nil {
+		t.Fatal(fmt.Errorf("expected error on missing table name"))
+	}
+}
+
+func TestMySQLAffectedCount(t *testing.T) {
+	u := getFakeUser()
+	if err := my.Create(u); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := my.DeleteCount(u); err != nil || n != 1 {
+		t.Fatalf("Unexpected result, %d, %v", n, err)
+	}
+	if n, err := my.DestroyCount(u); err != nil || n != 1 {
+		t.Fatalf("Unexpected result, %d, %v", n, err)
+	}
+	if n, err := my.DestroyCount(u); err != nil || n != 0 {
+		t.Fatalf("Expected no record is deleted, but get %d, %v", n, err)
+	}
+}
+
+func TestMySQLCreateWithResult(t *testing.T) {
+	users := []*User{getFakeUser(), getFakeUser()}
+	result, err := my.CreateWithResult(&users)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, err := result.RowsAffected(); err != nil || n != 2 {
+		t.Fatalf("Unexpected rows affected, %d, %v", n, err)
+	}
+	if _, err := result.LastInsertId(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMySQLAlterTableDrop(t *testing.T) {
+	type before struct {
+		Key  *datastore.Key `goloquent:"__key__"`
+		Name string
+		Age  int `goloquent:",index"`
+	}
+	type after struct {
+		Key  *datastore.Key `goloquent:"__key__"`
+		Name string
+	}
+
+	table := my.Table("AlterTemp")
+	if err := table.Migrate(new(before)); err != nil {
+		t.Fatal(err)
+	}
+	// the column and index are kept without drop
+	if err := my.WithoutDrop().Table("AlterTemp").Migrate(new(after)); err != nil {
+		t.Fatal(err)
+	}
+	if !hasColumn(t, table, "Age") {
+		t.Fatal("Expected the column is kept without drop")
+	}
+	stmts, err := table.MigrateDryRun(new(after))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stmts) != 1 || !strings.Contains(stmts[0], "DROP COLUMN `Age`") {
+		t.Fatal(fmt.Sprintf("Unexpected statements, %v", stmts))
+	}
+	if !hasColumn(t, table, "Age") {
+		t.Fatal("Expected dry run doesn't alter the table")
+	}
+	// drop both the column and the index, the alter statement must be valid
+	if err := table.Migrate(new(after)); err != nil {
+		t.Fatal(err)
+	}
+	if err := table.DropIfExists(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMySQLUnion(t *testing.T) {
+	users := new([]User)
+	if err := my.NewQuery().
+		Where("Age", ">", 60).
+		Union(my.NewQuery().Where("Age", "<", 18)).
+		Order("Age").
+		Limit(10).
+		Get(users); err != nil {
+		t.Fatal(err)
+	}
+	for _, u := range *users {
+		if u.Age >= 18 && u.Age <= 60 {
+			t.Fatalf("Unexpected age %d", u.Age)
+		}
+	}
+}
+
+// hasColumn will fail the test when the column cannot be looked up
+func hasColumn(t *testing.T, table *goloquent.Table, column string) bool {
+	isExist, err := table.HasColumn(column)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return isExist
+}
+
+func TestMySQLColumnMigration(t *testing.T) {
+	type ColumnTemp struct {
+		Key     *datastore.Key `goloquent:"__key__"`
+		Name    string
+		Remark  string
+		Address string
+	}
+
+	if err := my.Migrate(new(ColumnTemp)); err != nil {
+		t.Fatal(err)
+	}
+	if err := my.RenameColumn("ColumnTemp", "Remark", "Note"); err != nil {
+		t.Fatal(err)
+	}
+	// rename again should do nothing
+	if err := my.RenameColumn("ColumnTemp", "Remark", "Note"); err != nil {
+		t.Fatal(err)
+	}
+	if err := my.RenameColumn("ColumnTemp", "Name", "Note"); err == nil {
+		t.Fatal("Expected error when the new column is exists")
+	}
+	for i := 0; i < 2; i++ {
+		if err := my.DropColumn("ColumnTemp", "Address"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	table := my.Table("ColumnTemp")
+	if !hasColumn(t, table, "Note") || hasColumn(t, table, "Remark") || hasColumn(t, table, "Address") {
+		t.Fatal("Unexpected columns after migration")
+	}
+	if err := table.DropIfExists(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMySQLForeignKey(t *testing.T) {
+	type FkParent struct {
+		Key  *datastore.Key `goloquent:"__key__"`
+		Code string         `goloquent:",unique"`
+	}
+	type FkChild struct {
+		Key        *datastore.Key `goloquent:"__key__"`
+		ParentCode string         `goloquent:",references=FkParent(Code),ondelete=cascade"`
+	}
+
+	// migrate twice, so the constraint is kept by the alter table
+	for i := 0; i < 2; i++ {
+		if err := my.Migrate(new(FkParent), new(FkChild)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	parent := &FkParent{Code: "P001"}
+	if err := my.Create(parent); err != nil {
+		t.Fatal(err)
+	}
+	if err := my.Create(&FkChild{ParentCode: parent.Code}); err != nil {
+		t.Fatal(err)
+	}
+	if err := my.Create(&FkChild{ParentCode: "P002"}); err == nil {
+		t.Fatal("Expected error on missing parent")
+	}
+	if err := my.Delete(parent); err != nil {
+		t.Fatal(err)
+	}
+	if count, err := my.Count(new(FkChild)); err != nil || count != 0 {
+		t.Fatalf("Expected children to be deleted by cascade, %d, %v", count, err)
+	}
+	for _, table := range []string{"FkChild", "FkParent"} {
+		if err := my.Table(table).DropIfExists(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestMySQLKeyColumn(t *testing.T) {
+	type KeyNote struct {
+		Key     *datastore.Key `goloquent:"__key__"`
+		Body    string
+		Deleted goloquent.SoftDelete
+	}
+
+	my.SetKeyColumn("id")
+	my.SetDeletedColumn("deleted_at")
+	defer func() {
+		my.SetKeyColumn("")
+		my.SetDeletedColumn("")
+	}()
+
+	table := my.Table("KeyNote")
+	if err := table.DropIfExists(); err != nil {
+		t.Fatal(err)
+	}
+	defer table.DropIfExists()
+	if err := table.Migrate(new(KeyNote)); err != nil {
+		t.Fatal(err)
+	}
+	if !hasColumn(t, table, "id") || !hasColumn(t, table, "deleted_at") {
+		t.Fatal("Expected the table using the configured key and soft delete column")
+	}
+	n := &KeyNote{Key: datastore.NameKey("KeyNote", "a", nil), Body: "hello"}
+	if err := table.Create(n); err != nil {
+		t.Fatal(err)
+	}
+	if err := my.Delete(n); err != nil {
+		t.Fatal(err)
+	}
+	if err := table.Find(n.Key, new(KeyNote)); err != goloquent.ErrNoSuchEntity {
+		t.Fatal(fmt.Sprintf("Expected soft deleted record is excluded, but end up with %v", err))
+	}
+	notes := new([]KeyNote)
+	if err := table.OnlyTrashed().Get(notes); err != nil {
+		t.Fatal(err)
+	}
+	if len(*notes) != 1 || (*notes)[0].Key.String() != n.Key.String() || (*notes)[0].Deleted == nil {
+		t.Fatal(fmt.Sprintf("Unexpected records, %v", *notes))
+	}
+}
+
+func TestMySQLDecimal(t *testing.T) {
+	type Invoice struct {
+		Key    *datastore.Key `goloquent:"__key__"`
+		Amount float64        `goloquent:",type=decimal(18,2)"`
+		Total  string         `goloquent:",type=decimal(20,4)"`
+	}
+
+	table := my.Table("Invoice")
+	if err := table.DropIfExists(); err != nil {
+		t.Fatal(err)
+	}
+	defer table.DropIfExists()
+	if err := table.Migrate(new(Invoice)); err != nil {
+		t.Fatal(err)
+	}
+	i := &Invoice{Key: datastore.IDKey("Invoice", 1, nil), Amount: 10.25, Total: "1234567890123456.1234"}
+	if err := table.Create(i); err != nil {
+		t.Fatal(err)
+	}
+	o := new(Invoice)
+	if err := table.Find(i.Key, o); err != nil {
+		t.Fatal(err)
+	}
+	if o.Amount != i.Amount || o.Total != i.Total {
+		t.Fatal(fmt.Sprintf("Unexpected decimal values, %v and %q", o.Amount, o.Total))
+	}
+}
+
+func TestMySQLEnum(t *testing.T) {
+	type Member struct {
+		Key    *datastore.Key `goloquent:"__key__"`
+		Status string         `goloquent:",enum=active|inactive|banned"`
+	}
+
+	table := my.Table("Member")
+	if err := table.DropIfExists(); err != nil {
+		t.Fatal(err)
+	}
+	defer table.DropIfExists()
+	if err := table.Migrate(new(Member)); err != nil {
+		t.Fatal(err)
+	}
+	m := &Member{Key: datastore.IDKey("Member", 1, nil), Status: "banned"}
+	if err := table.Create(m); err != nil {
+		t.Fatal(err)
+	}
+	if err := table.Create(&Member{Key: datastore.IDKey("Member", 2, nil), Status: "unknown"}); err == nil {
+		t.Fatal("Expected error on the value which is not in the enum")
+	}
+	o := new(Member)
+	if err := table.Find(m.Key, o); err != nil {
+		t.Fatal(err)
+	}
+	if o.Status != m.Status {
+		t.Fatal(fmt.Sprintf("Unexpected enum value, %q", o.Status))
+	}
+}
+
+func TestMySQLReplaceIntoFiltered(t *testing.T) {
+	type Stock struct {
+		Key      *datastore.Key `goloquent:"__key__"`
+		Name     string
+		Quantity int
+	}
+	type StockArchive struct {
+		Key      *datastore.Key `goloquent:"__key__"`
+		Quantity int
+		Name     string
+		Note     string
+	}
+
+	for _, table := range []string{"Stock", "StockArchive"} {
+		if err := my.Table(table).DropIfExists(); err != nil {
+			t.Fatal(err)
+		}
+		defer my.Table(table).DropIfExists()
+	}
+	if err := my.Migrate(new(Stock), new(StockArchive)); err != nil {
+		t.Fatal(err)
+	}
+	stocks := []Stock{
+		{Key: datastore.IDKey("Stock", 1, nil), Name: "apple", Quantity: 0},
+		{Key: datastore.IDKey("Stock", 2, nil), Name: "banana", Quantity: 5},
+		{Key: datastore.IDKey("Stock", 3, nil), Name: "cherry", Quantity: 10},
+	}
+	if err := my.Create(&stocks); err != nil {
+		t.Fatal(err)
+	}
+	old := &StockArchive{Key: datastore.IDKey("StockArchive", 2, nil), Name: "old banana", Quantity: 1}
+	if err := my.Create(old); err != nil {
+		t.Fatal(err)
+	}
+
+	// the columns are mapped by name, though they are in different order
+	if err := my.Table("Stock").
+		Where("Quantity", ">", 0).
+		ReplaceInto("StockArchive"); err != nil {
+		t.Fatal(err)
+	}
+	archives := new([]StockArchive)
+	if err := my.Table("StockArchive").Order("Name").Get(archives); err != nil {
+		t.Fatal(err)
+	}
+	if len(*archives) != 2 ||
+		(*archives)[0].Name != "banana" || (*archives)[0].Quantity != 5 ||
+		(*archives)[1].Name != "cherry" || (*archives)[1].Quantity != 10 {
+		t.Fatal(fmt.Sprintf("Unexpected records, %v", *archives))
+	}
+}
+
+func TestMySQLEmbeddedStruct(t *testing.T) {
+	type Audit struct {
+		CreatedBy string
+		UpdatedBy string
+	}
+	type Reviewer struct {
+		Name string
+	}
+	type Article struct {
+		Key *datastore.Key `goloquent:"__key__"`
+		Audit
+		*Reviewer `goloquent:",prefix=review_"`
+		Title     string
+	}
+
+	table := my.Table("Article")
+	if err := table.DropIfExists(); err != nil {
+		t.Fatal(err)
+	}
+	defer table.DropIfExists()
+	if err := table.Migrate(new(Article)); err != nil {
+		t.Fatal(err)
+	}
+	for _, col := range []string{"CreatedBy", "UpdatedBy", "review_Name"} {
+		if !hasColumn(t, table, col) {
+			t.Fatal(fmt.Sprintf("Expected column %q of the embedded struct", col))
+		}
+	}
+	a := &Article{
+		Key:      datastore.IDKey("Article", 1, nil),
+		Audit:    Audit{CreatedBy: "joe", UpdatedBy: "ann"},
+		Reviewer: &Reviewer{Name: "ken"},
+		Title:    "hello",
+	}
+	if err := table.Create(a); err != nil {
+		t.Fatal(err)
+	}
+	o := new(Article)
+	if err := table.Find(a.Key, o); err != nil {
+		t.Fatal(err)
+	}
+	if o.CreatedBy != "joe" || o.UpdatedBy != "ann" || o.Reviewer == nil || o.Name != "ken" || o.Title != "hello" {
+		t.Fatal(fmt.Sprintf("Unexpected record, %+v", o))
+	}
+}
+
 func TestMySQLClose(t *testing.T) {
 	defer my.Close()
 }
@@ -1,9 +1,11 @@
 package goloquent
 
 import (
+	"errors"
 	"fmt"
 	"math/rand"
 	"net/url"
+	"reflect"
 	"strconv"
 	"strings"
 	"time"
@@ -214,7 +216,7 @@ func stringPk(k *datastore.Key) string {
 
 // compareVersion: is compare using semantic versioning
 // if a > b, result will be -1
-// if b < a, result will be 1
+// if a < b, result will be 1
 // if a = b, result will be 0
 func compareVersion(a, b string) (ret int) {
 	as := strings.Split(a, ".")
@@ -248,3 +250,51 @@ func compareVersion(a, b string) (ret int) {
 func escapeSingleQuote(v string) string {
 	return strings.Replace(v, `'`, `''`, -1)
 }
+
+// likeEscape is the escape character of the `LIKE` pattern
+const likeEscape = `\`
+
+var likeReplacer = strings.NewReplacer(likeEscape, likeEscape+likeEscape, "%", likeEscape+"%", "_", likeEscape+"_")
+
+// quoteColumns will quote the columns using `quote` and join them with the comma
+func quoteColumns(quote func(string) string, cols []string) string {
+	quoted := make([]string, len(cols))
+	for i, c := range cols {
+		quoted[i] = quote(c)
+	}
+	return strings.Join(quoted, ",")
+}
+
+// escapeLike will escape the wildcards and the escape character, so they are matched literally
+func escapeLike(v string) string {
+	return likeReplacer.Replace(v)
+}
+
+// mysqlErrorNumber will return the error number of the mysql driver error in the chain,
+// the driver is not imported so the error is recognised by its type name and `Number` field
+func mysqlErrorNumber(err error) (uint16, bool) {
+	for ; err != nil; err = errors.Unwrap(err) {
+		v := reflect.Indirect(reflect.ValueOf(err))
+		if v.Kind() != reflect.Struct || v.Type().Name() != "MySQLError" {
+			continue
+		}
+		if f := v.FieldByName("Number"); f.IsValid() && f.Kind() == reflect.Uint16 {
+			return uint16(f.Uint()), true
+		}
+	}
+	return 0, false
+}
+
+// sqlState will return the SQLSTATE code of the postgres driver error in the chain,
+// pgx exposes it with `SQLState()` and lib/pq with the `C` field of `Get`
+func sqlState(err error) string {
+	for ; err != nil; err = errors.Unwrap(err) {
+		switch vi := err.(type) {
+		case interface{ SQLState() string }:
+			return vi.SQLState()
+		case interface{ Get(byte) string }:
+			return vi.Get('C')
+		}
+	}
+	return ""
+}
@@ -45,3 +45,23 @@ func TestEscapeSingleQuote(t *testing.T) {
 		t.Fatal(`Unexpected error occur in "escapeSingleQuote"`)
 	}
 }
+
+func TestModelTable(t *testing.T) {
+	type User struct {
+		Name string
+	}
+	for _, model := range []interface{}{new(User), User{}, "User", " User "} {
+		table, err := modelTable(model)
+		if err != nil {
+			t.Fatalf("Unexpected error, %v", err)
+		}
+		if table != "User" {
+			t.Fatalf("Unexpected table name of %T, %q", model, table)
+		}
+	}
+	for _, model := range []interface{}{nil, "", []User{}, 1} {
+		if _, err := modelTable(model); err == nil {
+			t.Fatalf("Expected error on model %#v", model)
+		}
+	}
+}